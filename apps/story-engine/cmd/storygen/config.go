@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+// runConfig inspects the configuration. It runs before the config is
+// loaded so that a config which fails to load can still be shown.
+//
+//	storygen [--profile P] [--set key=value] config show [--check] [--json]
+func runConfig(path string, opts config.Options, args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		log.Fatal("Usage: storygen config show [--check] [--json]")
+	}
+
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	check := fs.Bool("check", false, "validate the config and exit 1 if it is invalid")
+	asJSON := fs.Bool("json", false, "print the settings as JSON")
+	fs.Parse(args[1:])
+
+	ins, err := config.Inspect(path, opts)
+	if err != nil {
+		log.Fatalf("Failed to read config: %v", err)
+	}
+
+	if *asJSON {
+		printJSON(ins.Settings)
+	} else {
+		width := 0
+		for _, s := range ins.Settings {
+			width = max(width, len(s.Key))
+		}
+		for _, s := range ins.Settings {
+			value, _ := json.Marshal(s.Value)
+			source := s.Source
+			if s.From != "" {
+				source += " " + s.From
+			}
+			fmt.Printf("%-*s  %s  (%s)\n", width, s.Key, value, source)
+		}
+	}
+
+	if !*check {
+		return
+	}
+	if err := ins.Check(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "Config OK.")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/costs"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/email"
+)
+
+// runCosts summarizes recorded spend by agent.
+//
+//	storygen costs [--since 30d] [--json]
+func runCosts(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("costs", flag.ExitOnError)
+	since := fs.String("since", "30d", "how far back to report: 30d, 2w, 12h or a date like 2026-01-02")
+	asJSON := fs.Bool("json", false, "print the summary as JSON")
+	fs.Parse(args)
+
+	loc, err := cfg.GetTimezone()
+	if err != nil {
+		log.Fatalf("Failed to load timezone: %v", err)
+	}
+	start, err := costs.ParseSince(*since, time.Now(), loc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	entries, err := costs.Load(cfg.Monitoring.CostTracking.LogFile)
+	if err != nil {
+		log.Fatalf("Failed to load cost log: %v", err)
+	}
+	summary := costs.Summarize(costs.Since(entries, start))
+
+	if *asJSON {
+		printJSON(summary)
+		return
+	}
+
+	fmt.Printf("Spend since %s\n\n", start.In(loc).Format("2006-01-02 15:04"))
+	fmt.Printf("%-24s %6s %10s %10s %10s %10s %10s %6s %10s\n",
+		"AGENT", "CALLS", "INPUT", "OUTPUT", "THINKING", "CACHE_W", "CACHE_R", "IMAGES", "COST")
+	for _, a := range summary.Agents {
+		fmt.Printf("%-24s %6d %10d %10d %10d %10d %10d %6d %10s\n",
+			a.Agent, a.Calls, a.InputTokens, a.OutputTokens, a.ThinkingTokens,
+			a.CacheWriteTokens, a.CacheReadTokens, a.Images, fmt.Sprintf("$%.4f", a.CostUSD))
+	}
+	fmt.Printf("\n%-24s %79s\n", "TOTAL", fmt.Sprintf("$%.4f", summary.TotalUSD))
+	if summary.CacheReadTokens > 0 {
+		fmt.Printf("Prompt cache: %d tokens read from cache, %.1f%% of prompt tokens\n",
+			summary.CacheReadTokens, summary.CacheHitRate*100)
+	}
+}
+
+// newClient creates the LLM client for the configured providers. When cost tracking is enabled
+// every call is recorded in the cost ledger and checked against the budget
+// first; the returned guard is nil otherwise.
+func newClient(cfg *config.Config) (*agents.Client, *costs.Guard) {
+	client := agents.NewClient(cfg)
+	client.SetIncludeThinking(cfg.Logging.IncludeThinking)
+	ledger := newLedger(cfg)
+	if ledger == nil {
+		return client, nil
+	}
+	client.SetRecorder(ledger)
+
+	guard := costs.NewGuard(cfg.Monitoring.CostTracking, cfg.Anthropic.FastModel, ledger)
+	client.SetBudget(guard)
+	return client, guard
+}
+
+// newLedger returns the cost ledger, or nil if cost tracking is disabled.
+func newLedger(cfg *config.Config) *costs.Ledger {
+	if !cfg.Monitoring.CostTracking.Enabled {
+		return nil
+	}
+	loc, err := cfg.GetTimezone()
+	if err != nil {
+		log.Fatalf("Failed to load timezone: %v", err)
+	}
+	return costs.NewLedger(cfg.Monitoring.CostTracking, loc, email.NewMailer(cfg.Email))
+}
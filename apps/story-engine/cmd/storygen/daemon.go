@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/health"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/prompts"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/scheduler"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+// runDaemon runs the pipeline at each time matching pipeline.schedule until
+// interrupted. With monitoring.http enabled it also serves the health and
+// status endpoints. Edits to the config files are picked up for the next
+// run; an edit that fails validation or breaks a prompt template is logged
+// and the old config kept.
+// monitoring.http itself is only read at start-up.
+//
+// With stories registered, each story is scheduled on its own schedule,
+// or only story when it is given. Stories added or removed by a config
+// edit are started or stopped; a removed story's run in progress finishes.
+// Runs of different stories that fall due together run at the same time,
+// each logging through its own run log.
+//
+//	storygen daemon [--run-now]
+func runDaemon(cfg *config.Config, opts config.Options, story string, args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	runNow := fs.Bool("run-now", false, "run the pipeline once at start-up before waiting for the schedule")
+	fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// A health server that can't serve stops the daemon in the main loop
+	status := health.NewServer(cfg)
+	var serveErr chan error
+	if cfg.Monitoring.HTTP.Enabled {
+		serveErr = make(chan error, 1)
+		go func() {
+			serveErr <- status.ListenAndServe(ctx)
+		}()
+	}
+
+	// Each reload closes reloaded and replaces it, waking every loop
+	var mu sync.Mutex
+	reloaded := make(chan struct{})
+	changes := func() <-chan struct{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return reloaded
+	}
+
+	reloader := config.NewReloader(configPath, opts, cfg)
+	reloader.Check = func(cfg *config.Config) error {
+		return checkDaemonPrompts(cfg, story)
+	}
+	status.SetConfig(reloader.Current())
+	go func() {
+		current := reloader.Current()
+		err := reloader.Watch(ctx, func(snapshot *config.Snapshot, err error) {
+			if err != nil {
+				log.Printf("Config change rejected, keeping version %d: %v", reloader.Current().Version, err)
+				status.ReloadFailed(err)
+				return
+			}
+			status.SetConfig(snapshot)
+			if snapshot == current {
+				return
+			}
+			current = snapshot
+			log.Printf("Config reloaded: version %d (%s)", snapshot.Version, snapshot.Hash)
+			mu.Lock()
+			close(reloaded)
+			reloaded = make(chan struct{})
+			mu.Unlock()
+		})
+		if err != nil {
+			log.Printf("Config hot-reload disabled: %v", err)
+		}
+	}()
+
+	runOnce := func(id string, loc *time.Location) {
+		cfg := storyConfig(reloader.Current().Config, id)
+		if cfg == nil {
+			return
+		}
+
+		unlock, err := storage.New(cfg.Paths).Lock()
+		if err != nil {
+			if errors.Is(err, storage.ErrLocked) {
+				log.Printf("Skipping run%s: %v", storyLabel(id), err)
+			} else {
+				log.Printf("Pipeline failed%s: %v", storyLabel(id), err)
+			}
+			return
+		}
+		defer unlock()
+
+		p, run := newPipeline(cfg, id, "", time.Now().In(loc))
+		runLog := startRunLog(cfg, run)
+		defer runLog.Close()
+		pinger := health.NewPinger(cfg.Monitoring.Healthchecks)
+		status.RunStarted(run)
+		pinger.Start(ctx)
+		err = p.Execute(ctx, run)
+		status.RunFinished(run, err)
+		if err != nil {
+			pinger.Fail(ctx, err)
+			log.Printf("Pipeline failed%s: %v", storyLabel(id), err)
+			return
+		}
+		pinger.Success(ctx)
+		log.Printf("Run %s completed%s", run.ID, storyLabel(id))
+	}
+
+	// scheduleStory runs one story on its schedule until stopped
+	scheduleStory := func(stopped context.Context, id string, runFirst bool) {
+		for {
+			// Read the schedule each time round: it may have been edited
+			wait := changes()
+			cfg := storyConfig(reloader.Current().Config, id)
+			if cfg == nil {
+				return
+			}
+			var spend health.SpendSource
+			if ledger := newLedger(cfg); ledger != nil {
+				spend = ledger
+			}
+			status.SetSpend(id, spend)
+
+			cron, err := scheduler.Parse(cfg.Pipeline.Schedule)
+			if err != nil {
+				log.Fatalf("Failed to parse schedule%s: %v", storyLabel(id), err)
+			}
+			loc, err := cfg.GetTimezone()
+			if err != nil {
+				log.Fatalf("Failed to load timezone%s: %v", storyLabel(id), err)
+			}
+
+			if runFirst {
+				runOnce(id, loc)
+				runFirst = false
+			}
+
+			next := cron.Next(time.Now().In(loc))
+			if next.IsZero() {
+				log.Fatalf("Schedule %q never fires%s", cfg.Pipeline.Schedule, storyLabel(id))
+			}
+			status.SetNextRun(id, next)
+			log.Printf("Next run%s at %s", storyLabel(id), next.Format(time.RFC3339))
+
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-stopped.Done():
+				timer.Stop()
+				return
+			case <-wait:
+				timer.Stop()
+				continue
+			case <-timer.C:
+			}
+			runOnce(id, loc)
+		}
+	}
+
+	// Start a loop for each story and stop those of removed stories
+	var wg sync.WaitGroup
+	loops := make(map[string]context.CancelFunc)
+	first := true
+	for {
+		wait := changes()
+		ids := daemonStories(reloader.Current().Config, story)
+		for id, cancel := range loops {
+			if !slices.Contains(ids, id) {
+				log.Printf("Stopping the schedule%s: it is no longer configured", storyLabel(id))
+				cancel()
+				delete(loops, id)
+				status.RemoveStory(id)
+			}
+		}
+		for _, id := range ids {
+			if _, ok := loops[id]; ok {
+				continue
+			}
+			stopped, cancel := context.WithCancel(ctx)
+			loops[id] = cancel
+			wg.Add(1)
+			go func(id string, runFirst bool) {
+				defer wg.Done()
+				scheduleStory(stopped, id, runFirst)
+			}(id, first && *runNow)
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			log.Println("Daemon stopped")
+			return
+		case err := <-serveErr:
+			if err == nil {
+				// Only returned on shutdown, which ctx.Done handles
+				serveErr = nil
+				continue
+			}
+			log.Printf("Stopping the daemon: %v", err)
+			stop()
+			wg.Wait()
+			log.Fatal("Daemon stopped")
+		case <-wait:
+		}
+	}
+}
+
+// daemonStories returns the stories the daemon schedules: only if it is
+// given and still registered, otherwise every registered story, or the
+// single story, with id "", when none are registered.
+func daemonStories(cfg *config.Config, only string) []string {
+	ids := cfg.StoryIDs()
+	switch {
+	case len(ids) == 0:
+		return []string{""}
+	case only != "":
+		if slices.Contains(ids, only) {
+			return []string{only}
+		}
+		return nil
+	}
+	return ids
+}
+
+// checkDaemonPrompts renders the prompts of every story the daemon
+// schedules, at start-up and before a reloaded config is swapped in.
+func checkDaemonPrompts(cfg *config.Config, only string) error {
+	for _, id := range daemonStories(cfg, only) {
+		if err := prompts.Check(storyConfig(cfg, id)); err != nil {
+			return fmt.Errorf("failed to load prompts%s: %w", storyLabel(id), err)
+		}
+	}
+	return nil
+}
+
+// storyConfig returns the config of story id, cfg itself for id "" while
+// no stories are registered, or nil if the story is no longer configured.
+func storyConfig(cfg *config.Config, id string) *config.Config {
+	if id == "" {
+		if len(cfg.Stories) > 0 {
+			return nil
+		}
+		return cfg
+	}
+	story, err := cfg.ForStory(id)
+	if err != nil {
+		return nil
+	}
+	return story
+}
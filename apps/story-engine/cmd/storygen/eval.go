@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/eval"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+// runEval runs agents over past runs with the current prompts and reports
+// their output next to what those runs produced. comment_filter can't be
+// evaluated yet: there is no comment filter agent and runs don't record
+// the comments they read.
+//
+//	storygen eval [--agents story_writer,prose_polisher,hashtag_generator]
+//	              [--runs 2026-01-02,...] [--limit 5] [--model M]
+//	              [--format markdown|html|json] [--out report.md]
+func runEval(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	agentList := fs.String("agents", strings.Join(eval.Agents(), ","), "comma-separated agents to run (comment_filter can't be evaluated yet)")
+	runList := fs.String("runs", "", "comma-separated run dates to use as fixtures (default: the latest --limit runs)")
+	limit := fs.Int("limit", 5, "how many recent runs to use when --runs is not given")
+	model := fs.String("model", "", "run every agent on this model instead of the one each run recorded")
+	format := fs.String("format", "markdown", "report format: markdown, html or json")
+	out := fs.String("out", "", "write the report to this file instead of stdout")
+	fs.Parse(args)
+
+	agentNames := splitList(*agentList)
+	if err := eval.CheckAgents(agentNames); err != nil {
+		log.Fatal(err)
+	}
+	if *model != "" {
+		if _, _, err := config.ParseModel(*model); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *format != "markdown" && *format != "html" && *format != "json" {
+		log.Fatalf("Unknown report format %q, want markdown, html or json", *format)
+	}
+
+	fixtures, err := eval.LoadFixtures(cfg, splitList(*runList), *limit)
+	if err != nil {
+		log.Fatalf("Failed to load fixtures: %v", err)
+	}
+	bible, err := storage.New(cfg.Paths).LoadStoryBible()
+	if err != nil {
+		log.Fatalf("Failed to load story bible: %v", err)
+	}
+
+	client, _ := newClient(cfg)
+	runner := eval.NewRunner(cfg, client, bible, *model)
+	log.Printf("Evaluating %s on %d runs", strings.Join(agentNames, ", "), len(fixtures))
+	report, err := runner.Run(context.Background(), fixtures, agentNames)
+	if err != nil {
+		log.Fatalf("Failed to run eval: %v", err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create report: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "html":
+		err = eval.WriteHTML(w, report)
+	case "json":
+		err = writeJSON(w, report)
+	default:
+		err = eval.WriteMarkdown(w, report)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	if *out != "" {
+		log.Printf("Wrote eval report to %s", *out)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+// runFmt rewrites hand-edited data files as canonical JSON. With no paths
+// it formats every .json file under the data directory except the
+// *.template.json files, whose comments document the fields and would be
+// dropped; --include-templates formats those too. With --check it only
+// lists files that need formatting. It exits non-zero if any file needs
+// formatting under --check or fails to parse.
+//
+//	storygen fmt [--check] [--include-templates] [path ...]
+func runFmt(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := fs.Bool("check", false, "list files that are not canonical without changing them")
+	includeTemplates := fs.Bool("include-templates", false, "also format *.template.json files, dropping their comments")
+	fs.Parse(args)
+
+	files := fs.Args()
+	if len(files) == 0 {
+		all, err := storage.New(cfg.Paths).JSONFiles()
+		if err != nil {
+			log.Fatalf("Failed to list data files: %v", err)
+		}
+		for _, path := range all {
+			if *includeTemplates || !storage.IsTemplate(path) {
+				files = append(files, path)
+			}
+		}
+	}
+
+	failed, changed := false, 0
+	for _, path := range files {
+		needed, err := storage.FormatFile(path, !*check)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			failed = true
+			continue
+		}
+		if needed {
+			fmt.Println(path)
+			changed++
+		}
+	}
+
+	if failed || (*check && changed > 0) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/bootstrap"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+// runInit creates a new story from the data templates. Any detail not given
+// as a flag is asked for interactively when stdin is a terminal.
+//
+//	storygen init [--title T] [--tagline T] [--genre a,b] [--tone a,b]
+//	              [--universe U] [--origin O] [--draft-premise] [--no-input] [--force]
+func runInit(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	title := fs.String("title", "", "story title")
+	tagline := fs.String("tagline", "", "one-line hook")
+	genre := fs.String("genre", "", "comma-separated genres")
+	tone := fs.String("tone", "", "comma-separated tone keywords")
+	universe := fs.String("universe", "", "name of the world")
+	origin := fs.String("origin", "", "name of the starting location")
+	draftPremise := fs.Bool("draft-premise", false, "draft the premise with the premise drafter agent")
+	noInput := fs.Bool("no-input", false, "never prompt; use flags only")
+	force := fs.Bool("force", false, "overwrite an existing story")
+	fs.Parse(args)
+
+	store := storage.New(cfg.Paths)
+
+	// Check before prompting so nobody fills in the wizard for nothing
+	if !*force {
+		path, exists, err := bootstrap.Existing(store)
+		if err != nil {
+			log.Fatalf("Failed to check for an existing story: %v", err)
+		}
+		if exists {
+			log.Fatalf("A story already exists (%s). Use --force to overwrite it.", path)
+		}
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if !*noInput && isTerminal(os.Stdin) {
+		in := bufio.NewReader(os.Stdin)
+		ask(in, "Story title", title)
+		ask(in, "Tagline", tagline)
+		ask(in, "Genres (comma-separated)", genre)
+		ask(in, "Tone (comma-separated)", tone)
+		ask(in, "Universe name", universe)
+		ask(in, "Starting location", origin)
+		if !explicit["draft-premise"] {
+			*draftPremise = confirm(in, "Draft a premise with the premise drafter?")
+		}
+	}
+
+	opts := bootstrap.Options{
+		Title:    *title,
+		Tagline:  *tagline,
+		Genre:    splitList(*genre),
+		Tone:     splitList(*tone),
+		Universe: *universe,
+		Origin:   *origin,
+		Force:    *force,
+	}
+	if opts.Title == "" || opts.Origin == "" {
+		log.Fatal("A story title and starting location are required (--title, --origin)")
+	}
+
+	if *draftPremise {
+		log.Println("Drafting premise...")
+		client, _ := newClient(cfg)
+		premise, err := agents.DraftPremise(context.Background(), client, cfg, agents.PremiseSeed{
+			StoryTitle: opts.Title,
+			Tagline:    opts.Tagline,
+			Genre:      opts.Genre,
+			Tone:       opts.Tone,
+			Universe:   opts.Universe,
+			Origin:     opts.Origin,
+		})
+		if err != nil {
+			log.Fatalf("Failed to draft premise: %v", err)
+		}
+		opts.Premise = premise
+	}
+
+	res, err := bootstrap.Init(store, opts)
+	if err != nil {
+		log.Fatalf("Failed to initialise story: %v", err)
+	}
+
+	for _, path := range res.Written {
+		fmt.Println("wrote", path)
+	}
+	for path, err := range res.Skipped {
+		fmt.Printf("skipped %s: %v\n", path, err)
+	}
+	fmt.Println("Story initialised. Edit the story bible and entity files, then run storygen validate.")
+}
+
+// ask prompts for a value unless one was already given.
+func ask(in *bufio.Reader, label string, value *string) {
+	if *value != "" {
+		return
+	}
+	fmt.Printf("%s: ", label)
+	line, _ := in.ReadString('\n')
+	*value = strings.TrimSpace(line)
+}
+
+func confirm(in *bufio.Reader, label string) bool {
+	fmt.Printf("%s [y/N]: ", label)
+	line, _ := in.ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// splitList splits a comma-separated flag value, returning nil when empty
+// so the template's value is kept.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
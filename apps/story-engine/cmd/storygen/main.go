@@ -4,40 +4,294 @@
 //
 // DEPLOYMENT TYPE
 // cloud run job
+//
+// USAGE
+// storygen [--profile P] [--story S] [--set key=value ...] [command] [flags]
+//
+// Config layers, later wins: config/pipeline.yaml, config/pipeline.<P>.yaml,
+// STORYGEN_<KEY> environment variables (anthropic.max_tokens is
+// STORYGEN_ANTHROPIC_MAX_TOKENS), then --set. STORYGEN_PROFILE selects the
+// profile when --profile is not given.
+//
+// With several stories registered under stories:, --story (or
+// STORYGEN_STORY) picks the one a command works on; the daemon schedules
+// them all unless one is given.
+//
+// Commands:
+//   run      run the story pipeline once (default); --chapter gives a drafted chapter
+//   init     create a new story from the data templates
+//   map      query the world map and rebuild its derived sections
+//   validate check entity files, world state and world map agree
+//   fmt      rewrite data files as canonical JSON
+//   costs    summarize recorded API spend by agent
+//   prompts  show which prompts and agent settings changed between chapters
+//   eval     run agents over past runs and report their output next to the originals
+//            (not yet the comment filter)
+//   runs     list past runs from the runs index, or show one run's manifest
+//   daemon   run the pipeline on pipeline.schedule and serve health endpoints
+//   config   show the effective config and where each value came from
 
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/email"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/health"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/pipeline"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/prompts"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
 	"github.com/joho/godotenv"
 )
 
-func main() {
-	fmt.Println("Job initialising...")
+const configPath = "./config/pipeline.yaml"
 
+func main() {
 	// load .env file incase in local development, otherwise ignore error
 	if err := godotenv.Load("./config/dev.env"); err != nil {
 		log.Println("No dev.env file found, defaulting to environment variables")
 	}
 
+	global := flag.NewFlagSet("storygen", flag.ExitOnError)
+	profile := global.String("profile", os.Getenv(config.ProfileEnv),
+		"config profile: merges config/pipeline.<profile>.yaml over pipeline.yaml")
+	story := global.String("story", os.Getenv(config.StoryEnv),
+		"story to work on when several are registered under stories:")
+	var overrides stringList
+	global.Var(&overrides, "set", "override a config key, e.g. --set anthropic.max_tokens=8192 (repeatable)")
+	global.Parse(os.Args[1:])
+	opts := config.Options{Profile: *profile, Overrides: overrides}
+
+	command, args := "run", global.Args()
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	// Inspecting the config must work even when it fails to load
+	if command == "config" {
+		runConfig(configPath, opts, args)
+		return
+	}
+
 	// Load config
-	cfg, err := config.Load("./config/pipeline.yaml")
+	cfg, err := config.LoadWithOptions(configPath, opts)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	logging.Setup(cfg)
+
+	// The daemon schedules every story; other commands work on one
+	var storyID string
+	if command == "daemon" {
+		storyID = *story
+		if storyID != "" {
+			if _, err := cfg.ForStory(storyID); err != nil {
+				log.Fatalf("Failed to select story: %v", err)
+			}
+		}
+	} else if cfg, storyID, err = selectStory(cfg, *story); err != nil {
+		log.Fatalf("Failed to select story: %v", err)
+	}
+
+	// Render every prompt up front so a broken template fails before a run
+	switch command {
+	case "run", "eval":
+		if err := prompts.Check(cfg); err != nil {
+			log.Fatalf("Failed to load prompts: %v", err)
+		}
+	case "daemon":
+		if err := checkDaemonPrompts(cfg, storyID); err != nil {
+			log.Fatalf("Failed to start daemon: %v", err)
+		}
+	}
+
+	switch command {
+	case "run":
+		runPipeline(cfg, storyID, args)
+	case "init":
+		runInit(cfg, args)
+	case "map":
+		runMap(cfg, args)
+	case "validate":
+		runValidate(cfg, args)
+	case "fmt":
+		runFmt(cfg, args)
+	case "costs":
+		runCosts(cfg, args)
+	case "prompts":
+		runPrompts(cfg, args)
+	case "eval":
+		runEval(cfg, args)
+	case "runs":
+		runRuns(cfg, args)
+	case "daemon":
+		runDaemon(cfg, opts, storyID, args)
+	default:
+		log.Fatalf("Unknown command %q", command)
+	}
+}
+
+// selectStory returns the config of the story named by id. With no stories
+// registered it returns cfg; with one, id may be empty.
+func selectStory(cfg *config.Config, id string) (*config.Config, string, error) {
+	ids := cfg.StoryIDs()
+	switch {
+	case len(ids) == 0 && id == "":
+		return cfg, "", nil
+	case len(ids) == 0:
+		return nil, "", fmt.Errorf("--story %s given but no stories are registered", id)
+	case id == "" && len(ids) > 1:
+		return nil, "", fmt.Errorf("%d stories are registered (%s), choose one with --story or %s", len(ids), strings.Join(ids, ", "), config.StoryEnv)
+	case id == "":
+		id = ids[0]
+	}
+	story, err := cfg.ForStory(id)
+	if err != nil {
+		return nil, "", err
+	}
+	return story, id, nil
+}
+
+// storyLabel names a story in log messages; it is empty in a single-story
+// deployment.
+func storyLabel(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " for story " + id
+}
+
+// runPipeline runs the daily story pipeline once.
+//
+//	storygen run [--chapter drafted.json]
+func runPipeline(cfg *config.Config, story string, args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	chapter := flags.String("chapter", "", "drafted chapter to run the chapter stages on, in the story writer's JSON output format")
+	flags.Parse(args)
+
+	fmt.Println("Job initialising...")
 
 	// test using config
 	log.Println("Testing config")
 	fmt.Println(cfg.Anthropic.PrimaryModel)
 	fmt.Println(cfg.GetTimezone())
-	fmt.Println(cfg.GetModelForAgent("story_planner"))
+	fmt.Println(cfg.AgentModel(cfg.Agents["story_planner"]))
 
 	// Start app
 	fmt.Println("Starting story pipeline...")
-	// TODO: Implement story pipeline
+	ctx := context.Background()
+	unlock, err := storage.New(cfg.Paths).Lock()
+	if err != nil {
+		log.Fatalf("Failed to start run: %v", err)
+	}
+	defer unlock()
+	pinger := health.NewPinger(cfg.Monitoring.Healthchecks)
+	p, run := newPipeline(cfg, story, *chapter, time.Now())
+	runLog := startRunLog(cfg, run)
+	defer runLog.Close()
+	pinger.Start(ctx)
+	if err := p.Execute(ctx, run); err != nil {
+		pinger.Fail(ctx, err)
+		log.Fatalf("Pipeline failed: %v", err)
+	}
+	pinger.Success(ctx)
 
 	fmt.Println("Job completed successfully.")
 }
+
+// newPipeline builds the pipeline stages and the state for a run of story
+// at date. Each run gets its own client so the per-run budget starts from
+// zero.
+//
+// The planner and writer stages are not built yet: the chapter is read
+// from chapterPath, and without one the run warns and the chapter stages
+// skip; see pipeline.DraftStage.
+func newPipeline(cfg *config.Config, story, chapterPath string, date time.Time) (*pipeline.Pipeline, *pipeline.Run) {
+	client, budget := newClient(cfg)
+	stages := []pipeline.Stage{pipeline.DraftStage{Path: chapterPath}}
+	if cfg.Pipeline.Validation.CanonCheckEnabled {
+		checker, err := agents.NewCanonChecker(client, cfg)
+		if err != nil {
+			log.Fatalf("Failed to create canon checker: %v", err)
+		}
+		writer, err := agents.NewStoryWriter(client, cfg)
+		if err != nil {
+			log.Fatalf("Failed to create story writer: %v", err)
+		}
+		stages = append(stages,
+			pipeline.CanonStage{Checker: checker, Writer: writer},
+			pipeline.ContinuityStage{},
+		)
+	}
+
+	hashtagGenerator, err := agents.NewHashtagGenerator(client, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create hashtag generator: %v", err)
+	}
+	imagePromptGenerator, err := agents.NewImagePromptGenerator(client, cfg)
+	if err != nil {
+		log.Fatalf("Failed to create image prompt generator: %v", err)
+	}
+	stages = append(stages,
+		pipeline.HashtagStage{Source: hashtagGenerator},
+		pipeline.ImagePromptStage{Writer: imagePromptGenerator},
+		pipeline.ArchiveStage{},
+		pipeline.ReportStage{Reporter: email.NewMailer(cfg.Email)},
+	)
+
+	provenance, err := prompts.Provenance(cfg)
+	if err != nil {
+		log.Fatalf("Failed to record prompt provenance: %v", err)
+	}
+
+	store := storage.New(cfg.Paths)
+	run := pipeline.NewRun(cfg, store, date)
+	if story != "" {
+		// Tell apart the logs of stories running on the same day
+		run.Story = story
+		run.CorrelationID = story + "-" + run.CorrelationID
+		run.Logger = slog.Default().With("run_id", run.CorrelationID)
+	}
+	run.Budget = budget
+	run.Provenance = provenance
+	return pipeline.New(stages...), run
+}
+
+// startRunLog tags the run's logs with its correlation id and, with
+// logging.file enabled, also writes them to the run's log file. If the file
+// can't be opened the run logs to stdout only.
+func startRunLog(cfg *config.Config, run *pipeline.Run) *logging.RunLog {
+	runLog, err := logging.StartRun(cfg, run.Dir, run.CorrelationID)
+	if err != nil {
+		log.Printf("Failed to start run log, logging to stdout only: %v", err)
+		return nil
+	}
+	run.Logger = runLog.Logger
+	if run.Budget != nil {
+		run.Budget.SetLogger(run.Logger)
+	}
+	if runLog.Path != "" {
+		run.AddArtifact(runLog.Path)
+	}
+	return runLog
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
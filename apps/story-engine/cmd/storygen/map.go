@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/world"
+)
+
+// runMap handles the map subcommands:
+//
+//	storygen map rebuild            regenerate distance_matrix and cardinal_directions
+//	storygen map from <loc>         list routes and travel times from a location
+//	storygen map route <from> <to>  show the quickest route between two locations
+//	storygen map proximity [--write] predict encounters and optionally save proximity_alerts
+//	storygen map planner            print the spatial context given to the story planner
+func runMap(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal("Usage: storygen map rebuild | from <loc> | route <from> <to> | proximity [--write] | planner")
+	}
+
+	store := storage.New(cfg.Paths)
+	worldMap, err := store.LoadWorldMap()
+	if err != nil {
+		log.Fatalf("Failed to load world map: %v", err)
+	}
+	graph := world.NewGraph(worldMap)
+
+	switch args[0] {
+	case "rebuild":
+		world.Rebuild(worldMap)
+		if err := store.SaveWorldMapDerived(worldMap); err != nil {
+			log.Fatalf("Failed to save world map: %v", err)
+		}
+		log.Printf("Rebuilt distance matrix and cardinal directions for %d locations", len(worldMap.Locations))
+
+	case "from":
+		if len(args) != 2 {
+			log.Fatal("Usage: storygen map from <loc>")
+		}
+		if worldMap.LocationByID(args[1]) == nil {
+			log.Fatalf("Location %q is not on the map", args[1])
+		}
+		printJSON(graph.RouteOptions(args[1]))
+
+	case "route":
+		if len(args) != 3 {
+			log.Fatal("Usage: storygen map route <from> <to>")
+		}
+		path, ok := graph.ShortestPath(args[1], args[2])
+		if !ok {
+			log.Fatalf("No known route from %s to %s", args[1], args[2])
+		}
+		printJSON(path)
+
+	case "proximity":
+		fs := flag.NewFlagSet("map proximity", flag.ExitOnError)
+		write := fs.Bool("write", false, "save the predicted alerts to world_state.json")
+		fs.Parse(args[1:])
+
+		state, err := store.LoadWorldState()
+		if err != nil {
+			log.Fatalf("Failed to load world state: %v", err)
+		}
+		alerts := world.NewProximityEngine(worldMap, cfg.Pipeline.Context.ProximityHorizonChapters).Predict(state)
+		if *write {
+			if err := store.SaveProximityAlerts(alerts); err != nil {
+				log.Fatalf("Failed to save proximity alerts: %v", err)
+			}
+		}
+		printJSON(alerts)
+
+	case "planner":
+		state, err := store.LoadWorldState()
+		if err != nil {
+			log.Fatalf("Failed to load world state: %v", err)
+		}
+		printJSON(world.BuildPlannerContext(worldMap, state, cfg.Pipeline.Context.ProximityHorizonChapters))
+
+	default:
+		log.Fatalf("Unknown map command %q", args[0])
+	}
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) {
+	if err := writeJSON(os.Stdout, v); err != nil {
+		log.Fatalf("Failed to encode output: %v", err)
+	}
+}
+
+// writeJSON writes v to w as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/prompts"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// chapterDiff is what changed between two archived chapters.
+type chapterDiff struct {
+	From    int              `json:"from"`
+	To      int              `json:"to"`
+	Changes []prompts.Change `json:"changes"`
+}
+
+// runPrompts handles the prompts subcommands:
+//
+//	storygen prompts diff --chapters 14..18 [--json]
+//
+// diff compares the provenance recorded in the archive records of each
+// pair of consecutive chapters in the range. Chapters without a record or
+// without provenance are skipped.
+func runPrompts(cfg *config.Config, args []string) {
+	if len(args) == 0 || args[0] != "diff" {
+		log.Fatal("Usage: storygen prompts diff --chapters 14..18 [--json]")
+	}
+
+	flags := flag.NewFlagSet("prompts diff", flag.ExitOnError)
+	chapters := flags.String("chapters", "", "chapter range to compare, e.g. 14..18")
+	asJSON := flags.Bool("json", false, "print the changes as JSON")
+	flags.Parse(args[1:])
+
+	first, last, err := parseChapterRange(*chapters)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store := storage.New(cfg.Paths)
+	var numbers []int
+	var records []*models.ChapterRecord
+	for n := first; n <= last; n++ {
+		record, err := store.LoadChapterRecord(n)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			log.Printf("Chapter %d is not in the archive, skipping it", n)
+			continue
+		case err != nil:
+			log.Fatalf("Failed to load chapter %d: %v", n, err)
+		case record.Provenance == nil:
+			log.Printf("Chapter %d has no recorded provenance, skipping it", n)
+			continue
+		}
+		numbers = append(numbers, n)
+		records = append(records, record)
+	}
+	if len(records) < 2 {
+		log.Fatalf("Need at least two chapters with provenance in %d..%d to compare, found %d", first, last, len(records))
+	}
+
+	diffs := make([]chapterDiff, 0, len(records)-1)
+	for i := 1; i < len(records); i++ {
+		diffs = append(diffs, chapterDiff{
+			From:    numbers[i-1],
+			To:      numbers[i],
+			Changes: prompts.Diff(records[i-1].Provenance, records[i].Provenance),
+		})
+	}
+
+	if *asJSON {
+		printJSON(diffs)
+		return
+	}
+	for _, d := range diffs {
+		if len(d.Changes) == 0 {
+			fmt.Printf("Chapter %d -> %d: no changes\n", d.From, d.To)
+			continue
+		}
+		fmt.Printf("Chapter %d -> %d:\n", d.From, d.To)
+		for _, c := range d.Changes {
+			fmt.Printf("  %s\n", c)
+		}
+	}
+}
+
+// parseChapterRange parses "14..18" into its first and last chapter.
+func parseChapterRange(s string) (int, int, error) {
+	from, to, ok := strings.Cut(s, "..")
+	first, err1 := strconv.Atoi(from)
+	last, err2 := strconv.Atoi(to)
+	if !ok || err1 != nil || err2 != nil || first < 1 || last <= first {
+		return 0, 0, fmt.Errorf("invalid --chapters %q, want a range such as 14..18", s)
+	}
+	return first, last, nil
+}
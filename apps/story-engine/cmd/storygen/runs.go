@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/costs"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/pipeline"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+const runsUsage = "Usage: storygen runs list [--since 30d] [--failed] [--json] [--rebuild] | storygen runs show <date|correlation-id> [--json]"
+
+// runRuns handles the runs subcommands:
+//
+//	storygen runs list [--since 30d] [--failed] [--json] [--rebuild]
+//	storygen runs show <date|correlation-id> [--json]
+//
+// list reads the runs index, which each run updates as it ends; --rebuild
+// rebuilds it from the run manifests first. A date run more than once is
+// listed once per attempt. show prints the manifest of a date's latest
+// attempt, or of the attempt with a correlation id.
+func runRuns(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		log.Fatal(runsUsage)
+	}
+	runsDir := storage.New(cfg.Paths).DataPath(cfg.Paths.RunsDir)
+	switch args[0] {
+	case "list":
+		listRuns(cfg, runsDir, args[1:])
+	case "show":
+		showRun(runsDir, args[1:])
+	default:
+		log.Fatal(runsUsage)
+	}
+}
+
+func listRuns(cfg *config.Config, runsDir string, args []string) {
+	flags := flag.NewFlagSet("runs list", flag.ExitOnError)
+	since := flags.String("since", "30d", "how far back to list: 30d, 2w, 12h or a date like 2026-01-02")
+	failed := flags.Bool("failed", false, "list failed runs only")
+	asJSON := flags.Bool("json", false, "print the runs as JSON")
+	rebuild := flags.Bool("rebuild", false, "rebuild the index from the run manifests first")
+	flags.Parse(args)
+
+	loc, err := cfg.GetTimezone()
+	if err != nil {
+		log.Fatalf("Failed to load timezone: %v", err)
+	}
+	start, err := costs.ParseSince(*since, time.Now(), loc)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var entries []pipeline.IndexEntry
+	if *rebuild {
+		entries, err = pipeline.RebuildIndex(runsDir)
+	} else {
+		entries, err = pipeline.LoadIndex(runsDir)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load runs: %v", err)
+	}
+
+	list := []pipeline.IndexEntry{}
+	for _, e := range entries {
+		if runTime(e, loc).Before(start) || (*failed && e.Status != pipeline.StatusFailed) {
+			continue
+		}
+		list = append(list, e)
+	}
+
+	if *asJSON {
+		printJSON(list)
+		return
+	}
+	if len(list) == 0 {
+		fmt.Printf("No runs since %s\n", start.In(loc).Format("2006-01-02 15:04"))
+		return
+	}
+	fmt.Printf("%-10s %-20s %-9s %7s %9s %9s %7s  %s\n", "DATE", "ATTEMPT", "STATUS", "CHAPTER", "DURATION", "COST", "RETRIES", "FAILED STAGE: ERROR")
+	for _, e := range list {
+		chapter := "-"
+		if e.ChapterNumber > 0 {
+			chapter = fmt.Sprint(e.ChapterNumber)
+		}
+		failure := e.Error
+		if e.FailedStage != "" {
+			failure = e.FailedStage + ": " + failure
+		}
+		failure = oneLine(failure, 100)
+		attempt := e.CorrelationID
+		if attempt == "" {
+			attempt = "-"
+		}
+		fmt.Printf("%-10s %-20s %-9s %7s %9s %9s %7d  %s\n", e.RunID, attempt, orUnknown(e.Status), chapter,
+			e.Duration.Round(time.Second), fmt.Sprintf("$%.4f", e.CostUSD), e.Retries, failure)
+	}
+}
+
+func showRun(runsDir string, args []string) {
+	flags := flag.NewFlagSet("runs show", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the manifest as JSON")
+	// Allow the flag after the run as well as before it
+	var run string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		run, args = args[0], args[1:]
+	}
+	flags.Parse(args)
+	if run == "" && flags.NArg() == 1 {
+		run = flags.Arg(0)
+	}
+
+	// The date names a directory, so it must be one. A correlation id
+	// holds its run's date: [story-]2026-01-02-1a2b3c4d
+	date, attempt := run, ""
+	if _, err := time.Parse(pipeline.RunDateFormat, date); err != nil {
+		date, attempt = runDateIn(run), run
+		if date == "" {
+			log.Fatalf("Invalid run %q, want a date like 2026-01-02 or a correlation id\n%s", run, runsUsage)
+		}
+	}
+
+	var m *pipeline.Manifest
+	var err error
+	if attempt == "" {
+		m, err = pipeline.LoadManifest(filepath.Join(runsDir, date))
+	} else {
+		m, err = pipeline.LoadAttemptManifest(filepath.Join(runsDir, date), attempt)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("No manifest for run %s", run)
+	}
+	if err != nil {
+		log.Fatalf("Failed to load run %s: %v", run, err)
+	}
+
+	if *asJSON {
+		printJSON(m)
+		return
+	}
+	fmt.Printf("Run %s", m.RunID)
+	if m.Story != "" {
+		fmt.Printf(" (story %s)", m.Story)
+	}
+	fmt.Printf(": %s\n", orUnknown(m.Status))
+	if m.CorrelationID != "" {
+		fmt.Printf("  Correlation id: %s\n", m.CorrelationID)
+	}
+	if m.ChapterNumber > 0 {
+		fmt.Printf("  Chapter:        %d\n", m.ChapterNumber)
+	}
+	if !m.Started.IsZero() {
+		fmt.Printf("  Started:        %s\n", m.Started.Format(time.RFC3339))
+		fmt.Printf("  Finished:       %s (%s)\n", m.Finished.Format(time.RFC3339), m.Duration.Round(time.Millisecond))
+	}
+	fmt.Printf("  Cost:           $%.4f\n", m.CostUSD)
+	if len(m.Models) > 0 {
+		fmt.Printf("  Models:         %s\n", strings.Join(m.Models, ", "))
+	}
+	if m.Error != "" {
+		fmt.Printf("  Error:          %s\n", m.Error)
+	}
+
+	fmt.Printf("\n  %-16s %-9s %9s %5s %7s %9s  %s\n", "STAGE", "STATUS", "DURATION", "CALLS", "RETRIES", "COST", "MODELS")
+	for _, s := range m.Stages {
+		fmt.Printf("  %-16s %-9s %9s %5d %7d %9s  %s\n", s.Name, orUnknown(s.Status), s.Duration.Round(time.Millisecond),
+			s.Calls, s.Retries, fmt.Sprintf("$%.4f", s.CostUSD), strings.Join(s.Models, ", "))
+	}
+	for _, name := range m.NotRun {
+		fmt.Printf("  %-16s %-9s\n", name, "not run")
+	}
+
+	if len(m.Artifacts) > 0 {
+		fmt.Println("\n  Artifacts:")
+		for _, a := range m.Artifacts {
+			fmt.Printf("    %s\n", a)
+		}
+	}
+	if len(m.Warnings) > 0 {
+		fmt.Println("\n  Warnings:")
+		for _, w := range m.Warnings {
+			fmt.Printf("    %s\n", w)
+		}
+	}
+}
+
+// runDateIn returns the run date a correlation id holds, or "" if it holds
+// none.
+func runDateIn(correlationID string) string {
+	for i := 0; i+len(pipeline.RunDateFormat) <= len(correlationID); i++ {
+		date := correlationID[i : i+len(pipeline.RunDateFormat)]
+		rest := correlationID[i+len(date):]
+		if _, err := time.Parse(pipeline.RunDateFormat, date); err == nil && strings.HasPrefix(rest, "-") {
+			return date
+		}
+	}
+	return ""
+}
+
+// runTime is when a run started, or for runs recorded before start times
+// were, the start of its date.
+func runTime(e pipeline.IndexEntry, loc *time.Location) time.Time {
+	if !e.Started.IsZero() {
+		return e.Started
+	}
+	t, _ := time.ParseInLocation(pipeline.RunDateFormat, e.RunID, loc)
+	return t
+}
+
+func orUnknown(status string) string {
+	if status == "" {
+		return "unknown"
+	}
+	return status
+}
+
+// oneLine collapses s to a single line of at most n characters.
+func oneLine(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/continuity"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/prompts"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+// runValidate cross-checks entity files, world_state and world_map and
+// prints every mismatch, then renders every agent prompt. It exits non-zero
+// if any error-level issue is found or a prompt fails to render.
+//
+//	storygen validate [--include-templates] [--json]
+func runValidate(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	includeTemplates := fs.Bool("include-templates", false, "also check *.template.json entity files")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Parse(args)
+
+	in, err := continuity.LoadInput(storage.New(cfg.Paths), *includeTemplates)
+	if err != nil {
+		log.Fatalf("Failed to load story data: %v", err)
+	}
+	report := continuity.Validate(in)
+
+	if *asJSON {
+		printJSON(report)
+	} else if len(report.Issues) == 0 {
+		fmt.Println("No continuity issues found.")
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Println(issue)
+		}
+	}
+
+	failed := report.HasErrors()
+	if err := prompts.Check(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Prompt templates:\n%v\n", err)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Agent tiers, set in agents.<name>.tier, pick the model an agent uses when
+// it has no model of its own.
+const (
+	TierFast    = "fast"
+	TierPrimary = "primary"
+)
+
+// AgentsConfig is the agent registry: each agent's configuration keyed by
+// agent name.
+type AgentsConfig map[string]AgentConfig
+
+// AgentConfig is one agent's entry in the registry.
+type AgentConfig struct {
+	// Tier is the default model: fast_model or primary_model.
+	Tier string `mapstructure:"tier"`
+	// Prompt is the system prompt file, relative to paths.prompts_dir.
+	Prompt string `mapstructure:"prompt"`
+	// Model overrides the tier's model when set.
+	Model          string  `mapstructure:"model"`
+	UseThinking    bool    `mapstructure:"use_thinking"`
+	ThinkingBudget int     `mapstructure:"thinking_budget"`
+	Temperature    float64 `mapstructure:"temperature"`
+	// Params are the agent's own settings; see knownAgents.
+	Params map[string]any `mapstructure:"params"`
+}
+
+type paramKind int
+
+const (
+	paramBool paramKind = iota
+	paramInt
+)
+
+func (k paramKind) String() string {
+	if k == paramBool {
+		return "a boolean"
+	}
+	return "an integer"
+}
+
+// agentSpec describes an agent the engine knows.
+type agentSpec struct {
+	// required agents are run by the engine and must be configured.
+	required bool
+	// params are the parameters the agent reads and their kinds.
+	params map[string]paramKind
+}
+
+// knownAgents are the agents that may appear under agents:.
+var knownAgents = map[string]agentSpec{
+	"premise_drafter":  {required: true},
+	"story_planner":    {},
+	"story_writer":     {required: true},
+	"prose_polisher":   {},
+	"chapter_examiner": {},
+	"canon_checker":    {required: true},
+	"entity_extractor": {},
+	"entity_updater":   {},
+	"entity_creator":   {},
+	"position_updater": {},
+	"comment_filter": {params: map[string]paramKind{
+		"max_suggestions":  paramInt,
+		"max_banked_ideas": paramInt,
+	}},
+	"hashtag_generator": {required: true, params: map[string]paramKind{
+		"include_story_tags":   paramBool,
+		"include_genre_tags":   paramBool,
+		"include_general_tags": paramBool,
+	}},
+	"image_prompt_generator": {required: true, params: map[string]paramKind{
+		"include_character_refs": paramBool,
+		"max_prompt_length":      paramInt,
+	}},
+}
+
+// KnownAgents returns the names of the agents the engine knows, sorted.
+func KnownAgents() []string {
+	names := make([]string, 0, len(knownAgents))
+	for name := range knownAgents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Agent returns the configuration of the named agent.
+func (c *Config) Agent(name string) (AgentConfig, error) {
+	agent, ok := c.Agents[name]
+	if !ok {
+		return AgentConfig{}, fmt.Errorf("agent %s is not configured under agents", name)
+	}
+	return agent, nil
+}
+
+// AgentModel returns the model for an agent: its own model, or the model of
+// its tier.
+func (c *Config) AgentModel(agent AgentConfig) string {
+	if agent.Model != "" {
+		return agent.Model
+	}
+	if agent.Tier == TierFast {
+		return c.Anthropic.FastModel
+	}
+	return c.Anthropic.PrimaryModel
+}
+
+// Bool returns a boolean parameter, false if it is not set.
+func (a AgentConfig) Bool(name string) bool {
+	b, _ := boolParam(a.Params[name])
+	return b
+}
+
+// Int returns an integer parameter, 0 if it is not set.
+func (a AgentConfig) Int(name string) int {
+	n, _ := intParam(a.Params[name])
+	return n
+}
+
+// boolParam and intParam convert a parameter as decoded from YAML, or as a
+// string from an environment or --set override.
+func boolParam(v any) (bool, bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+func intParam(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), v == float64(int(v))
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// validateAgents validates the agent registry.
+func (c *Config) validateAgents() []string {
+	var errs []string
+
+	names := make([]string, 0, len(c.Agents))
+	for name := range c.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec, ok := knownAgents[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("agents.%s is not a known agent (known: %s)", name, strings.Join(KnownAgents(), ", ")))
+			continue
+		}
+		errs = append(errs, c.validateAgentConfig(name, c.Agents[name], spec)...)
+	}
+	for _, name := range KnownAgents() {
+		if _, ok := c.Agents[name]; !ok && knownAgents[name].required {
+			errs = append(errs, fmt.Sprintf("agents.%s is required", name))
+		}
+	}
+
+	if h, ok := c.Agents["hashtag_generator"]; ok &&
+		!h.Bool("include_story_tags") && !h.Bool("include_genre_tags") && !h.Bool("include_general_tags") {
+		errs = append(errs, "agents.hashtag_generator must include at least one of story, genre or general tags")
+	}
+	if max := c.Agents["image_prompt_generator"].Int("max_prompt_length"); max > 0 {
+		// Style anchors are appended to every prompt and must leave room for the scene
+		if anchors := len(strings.Join(c.ImageGeneration.StyleAnchors, ", ")); anchors+100 > max {
+			errs = append(errs, fmt.Sprintf("image_generation.style_anchors (%d characters) leave less than 100 characters of agents.image_prompt_generator.params.max_prompt_length (%d)", anchors, max))
+		}
+	}
+
+	return errs
+}
+
+// validateAgentConfig validates a single agent configuration.
+func (c *Config) validateAgentConfig(agentName string, agent AgentConfig, spec agentSpec) []string {
+	var errs []string
+
+	if agent.Tier != TierFast && agent.Tier != TierPrimary {
+		errs = append(errs, fmt.Sprintf("agents.%s.tier must be %s or %s", agentName, TierFast, TierPrimary))
+	}
+
+	// The prompt must be a file inside the prompts directory
+	switch {
+	case agent.Prompt == "":
+		errs = append(errs, fmt.Sprintf("agents.%s.prompt is required", agentName))
+	case !filepath.IsLocal(agent.Prompt):
+		errs = append(errs, fmt.Sprintf("agents.%s.prompt must be a path inside paths.prompts_dir", agentName))
+	case c.Paths.PromptsDir != "":
+		if _, err := os.Stat(c.Paths.PromptPath(agent.Prompt)); err != nil {
+			errs = append(errs, fmt.Sprintf("agents.%s.prompt %s not found in %s", agentName, agent.Prompt, c.Paths.PromptsDir))
+		}
+	}
+
+	// Validate thinking budget if thinking is enabled
+	if agent.UseThinking {
+		if agent.ThinkingBudget < 1024 {
+			errs = append(errs, fmt.Sprintf("agents.%s.thinking_budget must be at least 1024 when use_thinking is enabled", agentName))
+		}
+		if agent.ThinkingBudget > 64000 {
+			errs = append(errs, fmt.Sprintf("agents.%s.thinking_budget must not exceed 64000", agentName))
+		}
+	}
+
+	// Validate temperature range (0.0 to 1.0)
+	if agent.Temperature < 0.0 || agent.Temperature > 1.0 {
+		errs = append(errs, fmt.Sprintf("agents.%s.temperature must be between 0.0 and 1.0", agentName))
+	}
+
+	// Parameters must be ones the agent reads, of the right kind; counts
+	// and lengths must not be negative
+	params := make([]string, 0, len(agent.Params))
+	for name := range agent.Params {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		kind, ok := spec.params[name]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("agents.%s.params.%s is not a parameter of this agent", agentName, name))
+			continue
+		}
+		value := agent.Params[name]
+		switch kind {
+		case paramBool:
+			if _, ok := boolParam(value); !ok {
+				errs = append(errs, fmt.Sprintf("agents.%s.params.%s must be %s", agentName, name, kind))
+			}
+		case paramInt:
+			n, ok := intParam(value)
+			if !ok {
+				errs = append(errs, fmt.Sprintf("agents.%s.params.%s must be %s", agentName, name, kind))
+			} else if n < 0 {
+				errs = append(errs, fmt.Sprintf("agents.%s.params.%s must not be negative", agentName, name))
+			}
+		}
+	}
+
+	return errs
+}
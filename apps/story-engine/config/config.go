@@ -12,33 +12,40 @@
 //
 // Config functions:
 // fmt.Println(cfg.GetTimezone())
-// fmt.Println(cfg.GetModelForAgent("story_planner"))
+// fmt.Println(cfg.AgentModel(cfg.Agents["story_planner"]))
 
 package config
 
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/scheduler"
 	"github.com/spf13/viper"
 )
 
 // Config is the root configuration struct for the story engine.
-// WARNING: Do not log this struct or its nested fields directly.
+// WARNING: Do not log nested fields of this struct directly.
 // Several fields contain secrets (API keys, tokens, passwords) loaded from
 // environment variables that must not appear in logs or error messages.
+// Config itself is safe to print, log and encode: String, LogValue and
+// MarshalJSON mask every field tagged secret:"true".
 type Config struct {
 	Anthropic       AnthropicConfig       `mapstructure:"anthropic"`
 	Instagram       InstagramConfig       `mapstructure:"instagram"`
 	ImageGeneration ImageGenerationConfig `mapstructure:"image_generation"`
 	Email           EmailConfig           `mapstructure:"email"`
 	Pipeline        PipelineConfig        `mapstructure:"pipeline"`
+	Providers       ProvidersConfig       `mapstructure:"providers"`
 	Agents          AgentsConfig          `mapstructure:"agents"`
 	Paths           PathsConfig           `mapstructure:"paths"`
+	Stories         StoriesConfig         `mapstructure:"stories"`
 	Monitoring      MonitoringConfig      `mapstructure:"monitoring"`
 	Logging         LoggingConfig         `mapstructure:"logging"`
+	SecretSource    SecretSourceConfig    `mapstructure:"secret_source"`
 }
 
 type AnthropicConfig struct {
@@ -47,7 +54,17 @@ type AnthropicConfig struct {
 	MaxTokens    int            `mapstructure:"max_tokens"`
 	Thinking     ThinkingConfig `mapstructure:"thinking"`
 	Retry        RetryConfig    `mapstructure:"retry"`
-	APIKey       string         // Loaded from env only
+	// PromptCaching caches the stable start of each request: the system
+	// prompt and the story context that doesn't change between calls.
+	PromptCaching PromptCachingConfig `mapstructure:"prompt_caching"`
+	APIKey        string              `json:"api_key" env:"ANTHROPIC_API_KEY" secret:"true"` // Loaded from env only
+}
+
+type PromptCachingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// TTL is how long a cached prefix lives: "5m" or "1h". 1h writes cost
+	// more but survive between closely spaced runs.
+	TTL string `mapstructure:"ttl"`
 }
 
 type ThinkingConfig struct {
@@ -67,8 +84,8 @@ type InstagramConfig struct {
 	MinLikesThreshold       int             `mapstructure:"min_likes_threshold"`
 	TopCommentsForFiltering int             `mapstructure:"top_comments_for_filtering"`
 	RateLimit               RateLimitConfig `mapstructure:"rate_limit"`
-	AccountID               string          // Loaded from env only
-	AccessToken             string          // Loaded from env only
+	AccountID               string          `json:"account_id" env:"INSTAGRAM_ACCOUNT_ID"`                   // Loaded from env only
+	AccessToken             string          `json:"access_token" env:"INSTAGRAM_ACCESS_TOKEN" secret:"true"` // Loaded from env only
 }
 
 type RateLimitConfig struct {
@@ -87,8 +104,8 @@ type ImageGenerationConfig struct {
 	StyleAnchors      []string         `mapstructure:"style_anchors"`
 	TimeoutSeconds    int              `mapstructure:"timeout_seconds"`
 	Retry             ImageRetryConfig `mapstructure:"retry"`
-	BFLAPIKey         string           // Loaded from env only
-	ReplicateToken    string           // Loaded from env only
+	BFLAPIKey         string           `json:"bfl_api_key" env:"BFL_API_KEY" secret:"true"`             // Loaded from env only
+	ReplicateToken    string           `json:"replicate_token" env:"REPLICATE_API_TOKEN" secret:"true"` // Loaded from env only
 }
 
 type ImageRetryConfig struct {
@@ -101,7 +118,7 @@ type EmailConfig struct {
 	Provider    string           `mapstructure:"provider"`
 	SMTP        SMTPConfig       `mapstructure:"smtp"`
 	SendGrid    SendGridConfig   `mapstructure:"sendgrid"`
-	Recipients  RecipientsConfig // Loaded from env only
+	Recipients  RecipientsConfig `json:"recipients"` // Loaded from env only
 	FromAddress string           `mapstructure:"from_address"`
 	FromName    string           `mapstructure:"from_name"`
 }
@@ -109,18 +126,18 @@ type EmailConfig struct {
 type SMTPConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
-	User     string // Loaded from env only
-	Password string // Loaded from env only
+	User     string `json:"user" env:"SMTP_USER"`                       // Loaded from env only
+	Password string `json:"password" env:"SMTP_PASSWORD" secret:"true"` // Loaded from env only
 }
 
 type SendGridConfig struct {
 	FromName string `mapstructure:"from_name"`
-	APIKey   string // Loaded from env only
+	APIKey   string `json:"api_key" env:"SENDGRID_API_KEY" secret:"true"` // Loaded from env only
 }
 
 type RecipientsConfig struct {
-	DailyReport string   // Loaded from env only
-	ErrorAlerts []string // Loaded from env only
+	DailyReport string   `json:"daily_report" env:"EMAIL_RECIPIENT_DAILY_REPORT"` // Loaded from env only
+	ErrorAlerts []string `json:"error_alerts" env:"EMAIL_RECIPIENT_ERROR_ALERTS"` // Loaded from env only
 }
 
 type PipelineConfig struct {
@@ -141,21 +158,29 @@ type StoryConfig struct {
 }
 
 type HashtagsConfig struct {
-	CountMin           int `mapstructure:"count_min"`
-	CountMax           int `mapstructure:"count_max"`
-	MaxTotalCharacters int `mapstructure:"max_total_characters"`
+	CountMin           int    `mapstructure:"count_min"`
+	CountMax           int    `mapstructure:"count_max"`
+	MaxTotalCharacters int    `mapstructure:"max_total_characters"`
+	BrandTag           string `mapstructure:"brand_tag"`
 }
 
 type ContextConfig struct {
-	RecentChaptersCount int `mapstructure:"recent_chapters_count"`
-	FullTextChapters    int `mapstructure:"full_text_chapters"`
-	MaxEntities         int `mapstructure:"max_entities"`
+	RecentChaptersCount      int `mapstructure:"recent_chapters_count"`
+	FullTextChapters         int `mapstructure:"full_text_chapters"`
+	MaxEntities              int `mapstructure:"max_entities"`
+	ProximityHorizonChapters int `mapstructure:"proximity_horizon_chapters"`
 }
 
 type ValidationConfig struct {
-	StrictLengthCheck   bool `mapstructure:"strict_length_check"`
-	LengthRetryAttempts int  `mapstructure:"length_retry_attempts"`
-	CanonCheckEnabled   bool `mapstructure:"canon_check_enabled"`
+	StrictLengthCheck   bool   `mapstructure:"strict_length_check"`
+	LengthRetryAttempts int    `mapstructure:"length_retry_attempts"`
+	CanonCheckEnabled   bool   `mapstructure:"canon_check_enabled"`
+	CanonCheckAction    string `mapstructure:"canon_check_action"`
+	CanonRetryAttempts  int    `mapstructure:"canon_retry_attempts"`
+	// Anachronisms are terms the canon checker flags without asking the
+	// model, keyed by era. A story is held to the terms of every era its
+	// universe.rules.technology_level names.
+	Anachronisms map[string][]string `mapstructure:"anachronisms"`
 }
 
 type CheckpointsConfig struct {
@@ -163,36 +188,30 @@ type CheckpointsConfig struct {
 	RetentionDays int  `mapstructure:"retention_days"`
 }
 
-type AgentsConfig struct {
-	CommentFilter        AgentConfig `mapstructure:"comment_filter"`
-	StoryPlanner         AgentConfig `mapstructure:"story_planner"`
-	StoryWriter          AgentConfig `mapstructure:"story_writer"`
-	HashtagGenerator     AgentConfig `mapstructure:"hashtag_generator"`
-	ImagePromptGenerator AgentConfig `mapstructure:"image_prompt_generator"`
-}
-
-type AgentConfig struct {
-	Model                string  `mapstructure:"model"`
-	UseThinking          bool    `mapstructure:"use_thinking"`
-	ThinkingBudget       int     `mapstructure:"thinking_budget"`
-	Temperature          float64 `mapstructure:"temperature"`
-	MaxSuggestions       int     `mapstructure:"max_suggestions"`
-	MaxBankedIdeas       int     `mapstructure:"max_banked_ideas"`
-	IncludeStoryTags     bool    `mapstructure:"include_story_tags"`
-	IncludeGenreTags     bool    `mapstructure:"include_genre_tags"`
-	IncludeGeneralTags   bool    `mapstructure:"include_general_tags"`
-	IncludeCharacterRefs bool    `mapstructure:"include_character_refs"`
-	MaxPromptLength      int     `mapstructure:"max_prompt_length"`
-}
-
 type PathsConfig struct {
-	DataDir      string `mapstructure:"data_dir"`
-	StoryBible   string `mapstructure:"story_bible"`
-	EntitiesDir  string `mapstructure:"entities_dir"`
-	ChaptersDir  string `mapstructure:"chapters_dir"`
-	RunsDir      string `mapstructure:"runs_dir"`
-	PromptsDir   string `mapstructure:"prompts_dir"`
-	TemplatesDir string `mapstructure:"templates_dir"`
+	DataDir     string `mapstructure:"data_dir"`
+	StoryBible  string `mapstructure:"story_bible"`
+	EntitiesDir string `mapstructure:"entities_dir"`
+	ChaptersDir string `mapstructure:"chapters_dir"`
+	WorldDir    string `mapstructure:"world_dir"`
+	RunsDir     string `mapstructure:"runs_dir"`
+	PromptsDir  string `mapstructure:"prompts_dir"`
+	// PromptsOverrideDir holds prompt files that replace those of the
+	// same name in PromptsDir. Stories set it to their own prompts_dir.
+	PromptsOverrideDir string `mapstructure:"prompts_override_dir"`
+	TemplatesDir       string `mapstructure:"templates_dir"`
+}
+
+// PromptPath returns the path of a prompt file: the override if there is
+// one, otherwise the file in PromptsDir.
+func (p PathsConfig) PromptPath(name string) string {
+	if p.PromptsOverrideDir != "" {
+		path := filepath.Join(p.PromptsOverrideDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(p.PromptsDir, name)
 }
 
 type MonitoringConfig struct {
@@ -204,18 +223,40 @@ type MonitoringConfig struct {
 type HealthchecksConfig struct {
 	Enabled        bool   `mapstructure:"enabled"`
 	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
-	PingURL        string // Loaded from env only
+	PingURL        string `json:"ping_url" env:"HEALTHCHECKS_PING_URL" secret:"true"` // Loaded from env only
 }
 
 type HTTPConfig struct {
 	Enabled bool `mapstructure:"enabled"`
-	Port    int  `mapstructure:"port"`
+	// Host is the address to bind, 127.0.0.1 if empty; 0.0.0.0 serves on
+	// every interface, as a container needs.
+	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port"`
 }
 
 type CostTrackingConfig struct {
-	Enabled             bool    `mapstructure:"enabled"`
-	DailyAlertThreshold float64 `mapstructure:"daily_alert_threshold"`
-	LogFile             string  `mapstructure:"log_file"`
+	Enabled             bool                  `mapstructure:"enabled"`
+	DailyAlertThreshold float64               `mapstructure:"daily_alert_threshold"`
+	LogFile             string                `mapstructure:"log_file"`
+	ModelPrices         map[string]ModelPrice `mapstructure:"model_prices"`
+	ImagePrices         map[string]float64    `mapstructure:"image_prices"`
+	Budget              BudgetConfig          `mapstructure:"budget"`
+}
+
+// BudgetConfig caps spend before it happens. A cap of 0 means no cap.
+type BudgetConfig struct {
+	PerRunCap float64 `mapstructure:"per_run_cap"`
+	PerDayCap float64 `mapstructure:"per_day_cap"`
+	// OnExceed is "downgrade" (fast model, fewer images, then stop) or "abort".
+	OnExceed string `mapstructure:"on_exceed"`
+}
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `mapstructure:"input"`
+	Output     float64 `mapstructure:"output"`
+	CacheWrite float64 `mapstructure:"cache_write"`
+	CacheRead  float64 `mapstructure:"cache_read"`
 }
 
 type LoggingConfig struct {
@@ -230,28 +271,73 @@ type FileConfig struct {
 	FilenamePattern string `mapstructure:"filename_pattern"`
 }
 
+// SecretSourceConfig selects where env-only values (API keys, tokens,
+// recipients) are read from. See SecretSource.
+type SecretSourceConfig struct {
+	// Type is "env" (default), "dotenv", "gcp_secret_manager" or "file".
+	Type           string `mapstructure:"type"`
+	DotenvFile     string `mapstructure:"dotenv_file"`
+	File           string `mapstructure:"file"`
+	GCPProjectID   string `mapstructure:"gcp_project_id"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+}
+
 // Load reads configuration from the pipeline.yaml and .env files
-// Environment variables take precedence over pipeline.yaml values with the same name
+// STORYGEN_ environment variables take precedence over pipeline.yaml values
 // path traversal risk: this function expects a trusted configPath input
 func Load(configPath string) (*Config, error) {
-	v := viper.New()
+	return LoadWithOptions(configPath, Options{})
+}
 
-	// Set config file
-	v.SetConfigFile(configPath)
-	v.SetConfigType("yaml")
+// LoadWithOptions reads configuration like Load with a profile and command
+// line overrides layered on top; see Options.
+func LoadWithOptions(configPath string, opts Options) (*Config, error) {
+	state, err := load(configPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return state.cfg, nil
+}
 
-	// Read the config file
-	if err := v.ReadInConfig(); err != nil {
-		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			return nil, fmt.Errorf("config file not found: %w", err)
-		} else {
-			return nil, fmt.Errorf("failed to read config file: %w", err)
-		}
+// load reads the config and validates it.
+func load(configPath string, opts Options) (*loadState, error) {
+	state, err := read(configPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check for environment variable errors
+	if len(state.envErrs) > 0 {
+		return nil, fmt.Errorf("environment variable errors:\n  - %s", strings.Join(state.envErrs, "\n  - "))
 	}
 
-	// Enable environment variable overrides
-	v.AutomaticEnv()
-	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	// Validate logical value constraints
+	if err := state.cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	return state, nil
+}
+
+// loadState is the unvalidated result of reading the config.
+type loadState struct {
+	cfg     *Config
+	secrets *secretValues
+	// sources records which layer set each key
+	sources map[string]string
+	// envErrs are required secrets that are missing.
+	envErrs []string
+}
+
+// read loads the config file and secrets without validating.
+func read(configPath string, opts Options) (*loadState, error) {
+	v := viper.New()
+
+	// Read the config file and its profile, then apply overrides
+	sources, err := applyLayers(v, configPath, opts)
+	if err != nil {
+		return nil, err
+	}
 
 	// Unmarshal into struct
 	var cfg Config
@@ -259,17 +345,34 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-	// Load and validate secrets from environment
+	// Load and validate secrets from the configured source
+	secrets := opts.secrets
+	if secrets == nil {
+		secrets, err = fetchSecrets(cfg.SecretSource, append(secretNames(), cfg.storySecretNames()...))
+	} else {
+		// Reloads reuse the secrets already read, fetching only those of
+		// stories added since
+		secrets, err = secrets.withNames(cfg.SecretSource, cfg.storySecretNames())
+	}
+	if err != nil {
+		return nil, err
+	}
 	var envErrs []string
 
 	// Required API keys
-	cfg.Anthropic.APIKey = requireEnv("ANTHROPIC_API_KEY", &envErrs)
-	cfg.Instagram.AccountID = requireEnv("INSTAGRAM_ACCOUNT_ID", &envErrs)
-	cfg.Instagram.AccessToken = requireEnv("INSTAGRAM_ACCESS_TOKEN", &envErrs)
+	// Anthropic is only required when an agent runs on it
+	if cfg.usesProvider(ProviderAnthropic) {
+		cfg.Anthropic.APIKey = secrets.require("ANTHROPIC_API_KEY", &envErrs)
+	} else {
+		cfg.Anthropic.APIKey = secrets.optional("ANTHROPIC_API_KEY")
+	}
+	cfg.Providers.OpenAI.APIKey = secrets.optional("OPENAI_API_KEY")
+	cfg.Instagram.AccountID = secrets.require("INSTAGRAM_ACCOUNT_ID", &envErrs)
+	cfg.Instagram.AccessToken = secrets.require("INSTAGRAM_ACCESS_TOKEN", &envErrs)
 
 	// Image generation (at least one required)
-	cfg.ImageGeneration.BFLAPIKey = optionalEnv("BFL_API_KEY")
-	cfg.ImageGeneration.ReplicateToken = optionalEnv("REPLICATE_API_TOKEN")
+	cfg.ImageGeneration.BFLAPIKey = secrets.optional("BFL_API_KEY")
+	cfg.ImageGeneration.ReplicateToken = secrets.optional("REPLICATE_API_TOKEN")
 	if cfg.ImageGeneration.BFLAPIKey == "" && cfg.ImageGeneration.ReplicateToken == "" {
 		envErrs = append(envErrs, "BFL_API_KEY or REPLICATE_API_TOKEN environment variable is required")
 	}
@@ -278,54 +381,47 @@ func Load(configPath string) (*Config, error) {
 	if cfg.Email.Enabled {
 		switch cfg.Email.Provider {
 		case "smtp":
-			cfg.Email.SMTP.User = requireEnv("SMTP_USER", &envErrs)
-			cfg.Email.SMTP.Password = requireEnv("SMTP_PASSWORD", &envErrs)
+			cfg.Email.SMTP.User = secrets.require("SMTP_USER", &envErrs)
+			cfg.Email.SMTP.Password = secrets.require("SMTP_PASSWORD", &envErrs)
 		case "sendgrid":
-			cfg.Email.SendGrid.APIKey = requireEnv("SENDGRID_API_KEY", &envErrs)
+			cfg.Email.SendGrid.APIKey = secrets.require("SENDGRID_API_KEY", &envErrs)
 		}
 		// Load email recipients
-		cfg.Email.Recipients.DailyReport = requireEnv("EMAIL_RECIPIENT_DAILY_REPORT", &envErrs)
-		errorAlertsStr := requireEnv("EMAIL_RECIPIENT_ERROR_ALERTS", &envErrs)
-		if errorAlertsStr != "" {
-			// Split comma-separated list and trim whitespace
-			cfg.Email.Recipients.ErrorAlerts = strings.Split(errorAlertsStr, ",")
-			for i := range cfg.Email.Recipients.ErrorAlerts {
-				cfg.Email.Recipients.ErrorAlerts[i] = strings.TrimSpace(cfg.Email.Recipients.ErrorAlerts[i])
-			}
-		}
+		cfg.Email.Recipients.DailyReport = secrets.require("EMAIL_RECIPIENT_DAILY_REPORT", &envErrs)
+		cfg.Email.Recipients.ErrorAlerts = splitRecipients(secrets.require("EMAIL_RECIPIENT_ERROR_ALERTS", &envErrs))
 	} else {
 		// Load optionally if email is disabled
-		cfg.Email.SMTP.User = optionalEnv("SMTP_USER")
-		cfg.Email.SMTP.Password = optionalEnv("SMTP_PASSWORD")
-		cfg.Email.SendGrid.APIKey = optionalEnv("SENDGRID_API_KEY")
-		cfg.Email.Recipients.DailyReport = optionalEnv("EMAIL_RECIPIENT_DAILY_REPORT")
-		errorAlertsStr := optionalEnv("EMAIL_RECIPIENT_ERROR_ALERTS")
-		if errorAlertsStr != "" {
-			cfg.Email.Recipients.ErrorAlerts = strings.Split(errorAlertsStr, ",")
-			for i := range cfg.Email.Recipients.ErrorAlerts {
-				cfg.Email.Recipients.ErrorAlerts[i] = strings.TrimSpace(cfg.Email.Recipients.ErrorAlerts[i])
-			}
-		}
+		cfg.Email.SMTP.User = secrets.optional("SMTP_USER")
+		cfg.Email.SMTP.Password = secrets.optional("SMTP_PASSWORD")
+		cfg.Email.SendGrid.APIKey = secrets.optional("SENDGRID_API_KEY")
+		cfg.Email.Recipients.DailyReport = secrets.optional("EMAIL_RECIPIENT_DAILY_REPORT")
+		cfg.Email.Recipients.ErrorAlerts = splitRecipients(secrets.optional("EMAIL_RECIPIENT_ERROR_ALERTS"))
 	}
 
 	// monitoring - require if enabled
 	if cfg.Monitoring.Healthchecks.Enabled {
-		cfg.Monitoring.Healthchecks.PingURL = requireEnv("HEALTHCHECKS_PING_URL", &envErrs)
+		cfg.Monitoring.Healthchecks.PingURL = secrets.require("HEALTHCHECKS_PING_URL", &envErrs)
 	} else {
-		cfg.Monitoring.Healthchecks.PingURL = optionalEnv("HEALTHCHECKS_PING_URL")
+		cfg.Monitoring.Healthchecks.PingURL = secrets.optional("HEALTHCHECKS_PING_URL")
 	}
 
-	// Check for environment variable errors
-	if len(envErrs) > 0 {
-		return nil, fmt.Errorf("environment variable errors:\n  - %s", strings.Join(envErrs, "\n  - "))
-	}
+	// Each story may have its own account and recipients
+	cfg.loadStorySecrets(secrets)
 
-	// Validate logical value constraints
-	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
-	}
+	return &loadState{cfg: &cfg, secrets: secrets, sources: sources, envErrs: envErrs}, nil
+}
 
-	return &cfg, nil
+// splitRecipients splits a comma-separated recipient list, trimming
+// whitespace. An empty list is nil.
+func splitRecipients(list string) []string {
+	if list == "" {
+		return nil
+	}
+	recipients := strings.Split(list, ",")
+	for i := range recipients {
+		recipients[i] = strings.TrimSpace(recipients[i])
+	}
+	return recipients
 }
 
 // checks logical constraints on configuration values.
@@ -339,10 +435,13 @@ func (c *Config) Validate() error {
 	errs = append(errs, c.validateImageGeneration()...)
 	errs = append(errs, c.validateEmail()...)
 	errs = append(errs, c.validatePipeline()...)
+	errs = append(errs, c.validateProviders()...)
 	errs = append(errs, c.validateAgents()...)
 	errs = append(errs, c.validatePaths()...)
+	errs = append(errs, c.validateStories()...)
 	errs = append(errs, c.validateMonitoring()...)
 	errs = append(errs, c.validateLogging()...)
+	errs = append(errs, c.validateSecretSource()...)
 
 	if len(errs) > 0 {
 		return fmt.Errorf("configuration errors:\n  - %s", strings.Join(errs, "\n  - "))
@@ -377,6 +476,12 @@ func (c *Config) validateAnthropic() []string {
 		}
 	}
 
+	if c.Anthropic.PromptCaching.Enabled {
+		if ttl := c.Anthropic.PromptCaching.TTL; ttl != "" && ttl != "5m" && ttl != "1h" {
+			errs = append(errs, "anthropic.prompt_caching.ttl must be 5m or 1h")
+		}
+	}
+
 	// Validate retry configuration
 	if c.Anthropic.Retry.MaxAttempts <= 0 {
 		errs = append(errs, "anthropic.retry.max_attempts must be greater than 0")
@@ -525,6 +630,8 @@ func (c *Config) validatePipeline() []string {
 
 	if c.Pipeline.Schedule == "" {
 		errs = append(errs, "pipeline.schedule is required")
+	} else if _, err := scheduler.Parse(c.Pipeline.Schedule); err != nil {
+		errs = append(errs, fmt.Sprintf("pipeline.schedule: %v", err))
 	}
 
 	if c.Pipeline.Timezone == "" {
@@ -567,6 +674,9 @@ func (c *Config) validatePipeline() []string {
 	if c.Pipeline.Hashtags.MaxTotalCharacters <= 0 {
 		errs = append(errs, "pipeline.hashtags.max_total_characters must be greater than 0")
 	}
+	if tag := c.Pipeline.Hashtags.BrandTag; tag != "" && (len(tag) < 2 || tag[0] != '#' || strings.ContainsAny(tag[1:], "# \t")) {
+		errs = append(errs, "pipeline.hashtags.brand_tag must be a single tag starting with #")
+	}
 
 	// Validate context constraints
 	if c.Pipeline.Context.RecentChaptersCount <= 0 {
@@ -581,11 +691,22 @@ func (c *Config) validatePipeline() []string {
 	if c.Pipeline.Context.MaxEntities <= 0 {
 		errs = append(errs, "pipeline.context.max_entities must be greater than 0")
 	}
+	if c.Pipeline.Context.ProximityHorizonChapters < 0 {
+		errs = append(errs, "pipeline.context.proximity_horizon_chapters must be greater than or equal to 0")
+	}
 
 	// Validate validation constraints
 	if c.Pipeline.Validation.LengthRetryAttempts < 0 {
 		errs = append(errs, "pipeline.validation.length_retry_attempts must be greater than or equal to 0")
 	}
+	if c.Pipeline.Validation.CanonCheckEnabled {
+		if c.Pipeline.Validation.CanonCheckAction != "rewrite" && c.Pipeline.Validation.CanonCheckAction != "flag" {
+			errs = append(errs, "pipeline.validation.canon_check_action must be 'rewrite' or 'flag' when canon check is enabled")
+		}
+		if c.Pipeline.Validation.CanonRetryAttempts < 0 {
+			errs = append(errs, "pipeline.validation.canon_retry_attempts must be greater than or equal to 0")
+		}
+	}
 
 	// Validate checkpoint constraints
 	if c.Pipeline.Checkpoints.Enabled && c.Pipeline.Checkpoints.RetentionDays <= 0 {
@@ -615,6 +736,10 @@ func (c *Config) validatePaths() []string {
 		errs = append(errs, "paths.chapters_dir is required")
 	}
 
+	if c.Paths.WorldDir == "" {
+		errs = append(errs, "paths.world_dir is required")
+	}
+
 	if c.Paths.RunsDir == "" {
 		errs = append(errs, "paths.runs_dir is required")
 	}
@@ -623,6 +748,12 @@ func (c *Config) validatePaths() []string {
 		errs = append(errs, "paths.prompts_dir is required")
 	}
 
+	if c.Paths.PromptsOverrideDir != "" {
+		if info, err := os.Stat(c.Paths.PromptsOverrideDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Sprintf("paths.prompts_override_dir %s is not a directory", c.Paths.PromptsOverrideDir))
+		}
+	}
+
 	if c.Paths.TemplatesDir == "" {
 		errs = append(errs, "paths.templates_dir is required")
 	}
@@ -656,6 +787,24 @@ func (c *Config) validateMonitoring() []string {
 		if c.Monitoring.CostTracking.LogFile == "" {
 			errs = append(errs, "monitoring.cost_tracking.log_file is required when cost tracking is enabled")
 		}
+		for _, model := range c.llmModels() {
+			if provider, _, _ := ParseModel(model); provider == ProviderOllama {
+				continue
+			}
+			if _, ok := c.Monitoring.CostTracking.ModelPrices[model]; model != "" && !ok {
+				errs = append(errs, fmt.Sprintf("monitoring.cost_tracking.model_prices has no price for %s", model))
+			}
+		}
+		if _, ok := c.Monitoring.CostTracking.ImagePrices[c.ImageGeneration.Model]; !ok {
+			errs = append(errs, fmt.Sprintf("monitoring.cost_tracking.image_prices has no price for %s", c.ImageGeneration.Model))
+		}
+		budget := c.Monitoring.CostTracking.Budget
+		if budget.PerRunCap < 0 || budget.PerDayCap < 0 {
+			errs = append(errs, "monitoring.cost_tracking.budget caps must be greater than or equal to 0")
+		}
+		if budget.OnExceed != "downgrade" && budget.OnExceed != "abort" {
+			errs = append(errs, "monitoring.cost_tracking.budget.on_exceed must be 'downgrade' or 'abort'")
+		}
 	}
 
 	return errs
@@ -678,55 +827,30 @@ func (c *Config) validateLogging() []string {
 	if c.Logging.File.Enabled && c.Logging.File.FilenamePattern == "" {
 		errs = append(errs, "logging.file.filename_pattern is required when file logging is enabled")
 	}
+	if strings.ContainsAny(c.Logging.File.FilenamePattern, `/\`) || c.Logging.File.FilenamePattern == ".." {
+		errs = append(errs, "logging.file.filename_pattern must be a file name, not a path")
+	}
 
 	return errs
 }
 
-// validateAgents validates agent-specific configurations.
-func (c *Config) validateAgents() []string {
-	var errs []string
-
-	errs = append(errs, c.validateAgentConfig("comment_filter", &c.Agents.CommentFilter)...)
-	errs = append(errs, c.validateAgentConfig("story_planner", &c.Agents.StoryPlanner)...)
-	errs = append(errs, c.validateAgentConfig("story_writer", &c.Agents.StoryWriter)...)
-	errs = append(errs, c.validateAgentConfig("hashtag_generator", &c.Agents.HashtagGenerator)...)
-	errs = append(errs, c.validateAgentConfig("image_prompt_generator", &c.Agents.ImagePromptGenerator)...)
-
-	return errs
-}
-
-// validateAgentConfig validates a single agent configuration.
-func (c *Config) validateAgentConfig(agentName string, agent *AgentConfig) []string {
+// validateSecretSource validates secret source configuration.
+func (c *Config) validateSecretSource() []string {
 	var errs []string
 
-	// Validate thinking budget if thinking is enabled
-	if agent.UseThinking {
-		if agent.ThinkingBudget < 1024 {
-			errs = append(errs, fmt.Sprintf("agents.%s.thinking_budget must be at least 1024 when use_thinking is enabled", agentName))
-		}
-		if agent.ThinkingBudget > 64000 {
-			errs = append(errs, fmt.Sprintf("agents.%s.thinking_budget must not exceed 64000", agentName))
-		}
+	if _, err := NewSecretSource(c.SecretSource); err != nil {
+		errs = append(errs, err.Error())
 	}
-
-	// Validate temperature range (0.0 to 1.0)
-	if agent.Temperature < 0.0 || agent.Temperature > 1.0 {
-		errs = append(errs, fmt.Sprintf("agents.%s.temperature must be between 0.0 and 1.0", agentName))
+	if c.SecretSource.TimeoutSeconds < 0 {
+		errs = append(errs, "secret_source.timeout_seconds must be greater than or equal to 0")
 	}
 
-	// Validate max_suggestions (negative values are invalid)
-	if agent.MaxSuggestions < 0 {
-		errs = append(errs, fmt.Sprintf("agents.%s.max_suggestions must not be negative", agentName))
-	}
-
-	// Validate max_banked_ideas (negative values are invalid)
-	if agent.MaxBankedIdeas < 0 {
-		errs = append(errs, fmt.Sprintf("agents.%s.max_banked_ideas must not be negative", agentName))
-	}
-
-	// Validate max_prompt_length (negative values are invalid)
-	if agent.MaxPromptLength < 0 {
-		errs = append(errs, fmt.Sprintf("agents.%s.max_prompt_length must not be negative", agentName))
+	// Logs mask every secret value; one this short would mask ordinary
+	// text, so it can't be masked and is refused instead
+	for _, s := range c.Settings() {
+		if s.Secret && s.Value.String() != "" && len(s.Value.String()) < MinSecretLength {
+			errs = append(errs, fmt.Sprintf("%s is shorter than %d characters, too short to mask in logs", s.Env, MinSecretLength))
+		}
 	}
 
 	return errs
@@ -741,51 +865,3 @@ func (c *Config) GetTimezone() (*time.Location, error) {
 	}
 	return loc, nil
 }
-
-// requireEnv loads a required environment variable and adds an error if missing.
-func requireEnv(key string, errs *[]string) string {
-	val := os.Getenv(key)
-	if val == "" {
-		*errs = append(*errs, fmt.Sprintf("%s environment variable is required", key))
-	}
-	return val
-}
-
-// optionalEnv loads an optional environment variable.
-func optionalEnv(key string) string {
-	return os.Getenv(key)
-}
-
-// GetModelForAgent returns the appropriate model for a given agent,
-// falling back to defaults if not specified.
-func (c *Config) GetModelForAgent(agentName string) string {
-	switch agentName {
-	case "comment_filter":
-		if c.Agents.CommentFilter.Model != "" {
-			return c.Agents.CommentFilter.Model
-		}
-		return c.Anthropic.FastModel
-	case "story_planner":
-		if c.Agents.StoryPlanner.Model != "" {
-			return c.Agents.StoryPlanner.Model
-		}
-		return c.Anthropic.PrimaryModel
-	case "story_writer":
-		if c.Agents.StoryWriter.Model != "" {
-			return c.Agents.StoryWriter.Model
-		}
-		return c.Anthropic.PrimaryModel
-	case "hashtag_generator":
-		if c.Agents.HashtagGenerator.Model != "" {
-			return c.Agents.HashtagGenerator.Model
-		}
-		return c.Anthropic.FastModel
-	case "image_prompt_generator":
-		if c.Agents.ImagePromptGenerator.Model != "" {
-			return c.Agents.ImagePromptGenerator.Model
-		}
-		return c.Anthropic.PrimaryModel
-	default:
-		return c.Anthropic.PrimaryModel
-	}
-}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sources of a config value, as reported by Inspect. Env-only values
+// report the secret source type instead, e.g. SecretSourceGCP.
+const (
+	SourceYAML    = "yaml"
+	SourceEnv     = "env"
+	SourceDefault = "default"
+	SourceUnset   = "unset"
+)
+
+// Inspection is the effective config with where each value came from. It
+// is produced even when the config would fail to load.
+type Inspection struct {
+	Config   *Config
+	Settings []InspectedSetting
+	// EnvErrors are required environment variables that are missing.
+	EnvErrors []string
+}
+
+// InspectedSetting is one config value and its source. Secret values are
+// masked.
+type InspectedSetting struct {
+	Key    string `json:"key"`
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+	// From names the variable, secret, profile file or flag the value was
+	// read from.
+	From string `json:"from,omitempty"`
+}
+
+// Inspect loads the config like LoadWithOptions, without validating it, and
+// reports for every value which layer set it: the YAML file, the profile,
+// an environment variable, a command line override or the zero-value
+// default.
+func Inspect(configPath string, opts Options) (*Inspection, error) {
+	state, err := read(configPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ins := &Inspection{Config: state.cfg, EnvErrors: state.envErrs}
+	for _, s := range state.cfg.Settings() {
+		setting := InspectedSetting{Key: s.Key, Value: s.Masked()}
+		switch {
+		case s.Env != "":
+			// Reported as the secret source, e.g. env or gcp_secret_manager
+			setting.Source = SourceUnset
+			if state.secrets.optional(s.Env) != "" {
+				setting.Source, setting.From = state.secrets.source, s.Env
+			}
+		case state.sources[s.Key] != "":
+			setting.Source, setting.From, _ = strings.Cut(state.sources[s.Key], " ")
+		default:
+			setting.Source = SourceDefault
+		}
+		ins.Settings = append(ins.Settings, setting)
+	}
+	return ins, nil
+}
+
+// Check returns the problems that would stop the config loading: missing
+// environment variables, then validation errors.
+func (ins *Inspection) Check() error {
+	if len(ins.EnvErrors) > 0 {
+		return fmt.Errorf("environment variable errors:\n  - %s", strings.Join(ins.EnvErrors, "\n  - "))
+	}
+	return ins.Config.Validate()
+}
+
+// envKey is the environment variable name of a key, without EnvPrefix.
+func envKey(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
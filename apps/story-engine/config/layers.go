@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix starts the environment variables that override config keys:
+// anthropic.max_tokens is STORYGEN_ANTHROPIC_MAX_TOKENS.
+const EnvPrefix = "STORYGEN_"
+
+// ProfileEnv selects the profile when --profile is not given.
+const ProfileEnv = EnvPrefix + "PROFILE"
+
+// StoryEnv selects the story when --story is not given.
+const StoryEnv = EnvPrefix + "STORY"
+
+// Layer sources beyond SourceYAML, as reported by Inspect.
+const (
+	SourceProfile = "profile"
+	SourceFlag    = "flag"
+)
+
+var profilePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Options selects the layers merged over the base config file. Later
+// layers win: base file, profile file, STORYGEN_ environment variables,
+// then Overrides.
+type Options struct {
+	// Profile merges pipeline.<profile>.yaml, next to the base file, over it.
+	Profile string
+	// Overrides are key=value settings from the command line.
+	Overrides []string
+
+	// secrets, when set, are used instead of fetching from the source.
+	secrets *secretValues
+}
+
+// ProfilePath returns the profile file for a base config path:
+// config/pipeline.yaml with profile prod is config/pipeline.prod.yaml.
+func ProfilePath(configPath, profile string) (string, error) {
+	if !profilePattern.MatchString(profile) {
+		return "", fmt.Errorf("invalid profile %q: use lowercase letters, digits, - and _", profile)
+	}
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + "." + profile + ext, nil
+}
+
+// applyLayers reads the base and profile files into v, then applies the
+// environment and command line overrides, returning where each key's value
+// came from.
+func applyLayers(v *viper.Viper, configPath string, opts Options) (map[string]string, error) {
+	v.SetConfigFile(configPath)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return nil, fmt.Errorf("config file not found: %w", err)
+		}
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var profile *viper.Viper
+	var profilePath string
+	if opts.Profile != "" {
+		path, err := ProfilePath(configPath, opts.Profile)
+		if err != nil {
+			return nil, err
+		}
+		profile = viper.New()
+		profile.SetConfigFile(path)
+		profile.SetConfigType("yaml")
+		if err := profile.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read profile %s: %w", opts.Profile, err)
+		}
+		if err := v.MergeConfigMap(profile.AllSettings()); err != nil {
+			return nil, fmt.Errorf("failed to merge profile %s: %w", opts.Profile, err)
+		}
+		profilePath = path
+	}
+
+	// Map entries such as agents are only known once the files are read
+	var files Config
+	if err := v.Unmarshal(&files); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	keys := overridableKeys(&files)
+	sources := make(map[string]string)
+	for _, key := range keys {
+		switch {
+		case profile != nil && profile.InConfig(key):
+			sources[key] = SourceProfile + " " + profilePath
+		case v.InConfig(key):
+			sources[key] = SourceYAML
+		}
+	}
+
+	// Set each override explicitly: viper's AutomaticEnv is only consulted
+	// for keys already in a file, so it can't add keys that aren't there.
+	byEnv := make(map[string]string, len(keys))
+	for _, key := range keys {
+		byEnv[EnvPrefix+envKey(key)] = key
+	}
+	var unknown []string
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || name == ProfileEnv || name == StoryEnv {
+			continue
+		}
+		key, ok := byEnv[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		v.Set(key, value)
+		sources[key] = SourceEnv + " " + name
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown config environment variables (no matching key): %s", strings.Join(unknown, ", "))
+	}
+
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key] = true
+	}
+	for _, override := range opts.Overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --set %q, want key=value", override)
+		}
+		if !known[key] {
+			return nil, fmt.Errorf("invalid --set %q: unknown config key %s", override, key)
+		}
+		v.Set(key, value)
+		sources[key] = SourceFlag + " --set"
+	}
+	return sources, nil
+}
+
+// overridableKeys are the dotted keys of every setting that can be set in
+// YAML; env-only secrets are excluded. Map entries, such as each agent's
+// settings, are taken from cfg, so only entries in the files can be
+// overridden.
+func overridableKeys(cfg *Config) []string {
+	var keys []string
+	for _, s := range cfg.Settings() {
+		if s.Env == "" {
+			keys = append(keys, s.Key)
+		}
+	}
+	return keys
+}
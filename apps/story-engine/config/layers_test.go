@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLayers copies the shipped pipeline.yaml into a temp dir next to a
+// "test" profile that raises the log level and reads secrets from a file,
+// and returns the base path.
+func writeLayers(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	base, err := os.ReadFile("pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pipeline.yaml"), base, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	secrets := filepath.Join(dir, "secrets.json")
+	writeJSON(t, secrets, baseSecrets())
+	profile := "logging:\n  level: \"debug\"\nsecret_source:\n  type: \"file\"\n  file: \"" + filepath.ToSlash(secrets) + "\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "pipeline.test.yaml"), []byte(profile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "pipeline.yaml")
+}
+
+func inspected(t *testing.T, ins *Inspection, key string) InspectedSetting {
+	t.Helper()
+	for _, s := range ins.Settings {
+		if s.Key == key {
+			return s
+		}
+	}
+	t.Fatalf("no setting %s", key)
+	return InspectedSetting{}
+}
+
+func TestLayering(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   string
+		env       map[string]string
+		overrides []string
+
+		wantLevel, wantLevelSource string
+		// The Anthropic key is sk-ant-env in the environment and sk-ant-test
+		// in the profile's secrets file
+		wantKey, wantKeySource string
+		wantErr                string
+	}{
+		{
+			name:      "base file",
+			wantLevel: "info", wantLevelSource: SourceYAML,
+			wantKey: "sk-ant-env", wantKeySource: SourceEnv,
+		},
+		{
+			name:      "profile over base",
+			profile:   "test",
+			wantLevel: "debug", wantLevelSource: SourceProfile,
+			wantKey: "sk-ant-test", wantKeySource: SecretSourceFile,
+		},
+		{
+			name:      "env over profile",
+			profile:   "test",
+			env:       map[string]string{"STORYGEN_LOGGING_LEVEL": "warn"},
+			wantLevel: "warn", wantLevelSource: SourceEnv,
+			wantKey: "sk-ant-test", wantKeySource: SecretSourceFile,
+		},
+		{
+			name:      "flag over env",
+			profile:   "test",
+			env:       map[string]string{"STORYGEN_LOGGING_LEVEL": "warn"},
+			overrides: []string{"logging.level=error"},
+			wantLevel: "error", wantLevelSource: SourceFlag,
+			wantKey: "sk-ant-test", wantKeySource: SecretSourceFile,
+		},
+		{
+			name:      "env picks the secret source",
+			profile:   "test",
+			env:       map[string]string{"STORYGEN_SECRET_SOURCE_TYPE": "env"},
+			wantLevel: "debug", wantLevelSource: SourceProfile,
+			wantKey: "sk-ant-env", wantKeySource: SourceEnv,
+		},
+		{
+			name:      "flag picks the secret source",
+			profile:   "test",
+			overrides: []string{"secret_source.type=env"},
+			wantLevel: "debug", wantLevelSource: SourceProfile,
+			wantKey: "sk-ant-env", wantKeySource: SourceEnv,
+		},
+		{
+			name:      "secrets can't be set by flag",
+			overrides: []string{"anthropic.api_key=sk-ant-flag"},
+			wantErr:   "unknown config key anthropic.api_key",
+		},
+		{
+			name:    "secrets can't be set by prefixed env",
+			env:     map[string]string{"STORYGEN_ANTHROPIC_API_KEY": "sk-ant-prefixed"},
+			wantErr: "unknown config environment variables (no matching key): STORYGEN_ANTHROPIC_API_KEY",
+		},
+		{
+			name:    "missing profile",
+			profile: "staging",
+			wantErr: "failed to read profile staging",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range baseSecrets() {
+				t.Setenv(name, value)
+			}
+			t.Setenv("ANTHROPIC_API_KEY", "sk-ant-env")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			ins, err := Inspect(writeLayers(t), Options{Profile: tt.profile, Overrides: tt.overrides})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Inspect error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+
+			if got := ins.Config.Logging.Level; got != tt.wantLevel {
+				t.Errorf("logging.level = %q, want %q", got, tt.wantLevel)
+			}
+			if got := inspected(t, ins, "logging.level").Source; got != tt.wantLevelSource {
+				t.Errorf("logging.level source = %q, want %q", got, tt.wantLevelSource)
+			}
+			if got := ins.Config.Anthropic.APIKey; got != tt.wantKey {
+				t.Errorf("Anthropic.APIKey = %q, want %q", got, tt.wantKey)
+			}
+			key := inspected(t, ins, "anthropic.api_key")
+			if key.Source != tt.wantKeySource || key.From != "ANTHROPIC_API_KEY" {
+				t.Errorf("anthropic.api_key from %s %s, want %s ANTHROPIC_API_KEY", key.Source, key.From, tt.wantKeySource)
+			}
+			if strings.Contains(key.Value.(string), "sk-ant") {
+				t.Errorf("Inspect shows the secret: %v", key.Value)
+			}
+		})
+	}
+}
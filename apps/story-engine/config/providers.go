@@ -0,0 +1,142 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// LLM providers, named by the prefix of a model reference such as
+// "ollama:llama3.1:8b". A model without a prefix is an Anthropic model.
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+	ProviderOllama    = "ollama"
+)
+
+// ProvidersConfig configures the LLM backends other than Anthropic. A
+// backend is available once its base_url is set.
+type ProvidersConfig struct {
+	OpenAI OpenAIConfig `mapstructure:"openai"`
+	Ollama OllamaConfig `mapstructure:"ollama"`
+}
+
+// OpenAIConfig is any server with the OpenAI chat completions API: OpenAI
+// itself, OpenRouter, vLLM or a llama.cpp server.
+type OpenAIConfig struct {
+	// BaseURL is the API root, e.g. https://api.openai.com/v1.
+	BaseURL        string `mapstructure:"base_url"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+	// APIKey is optional; local servers usually don't need one.
+	APIKey string `json:"api_key" env:"OPENAI_API_KEY" secret:"true"` // Loaded from env only
+}
+
+// OllamaConfig is a local Ollama server.
+type OllamaConfig struct {
+	// BaseURL is the server, e.g. http://localhost:11434.
+	BaseURL        string `mapstructure:"base_url"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+}
+
+// ParseModel splits a model reference into its provider and the model name
+// the provider knows it by.
+func ParseModel(ref string) (provider, model string, err error) {
+	provider, model, ok := strings.Cut(ref, ":")
+	if !ok {
+		return ProviderAnthropic, ref, nil
+	}
+	switch provider {
+	case ProviderAnthropic, ProviderOpenAI, ProviderOllama:
+	default:
+		return "", "", fmt.Errorf("unknown provider %q in model %q (use anthropic, openai or ollama)", provider, ref)
+	}
+	if model == "" {
+		return "", "", fmt.Errorf("model %q names no model after the provider", ref)
+	}
+	return provider, model, nil
+}
+
+// usesProvider reports whether any configured agent runs on provider.
+func (c *Config) usesProvider(provider string) bool {
+	for _, agent := range c.Agents {
+		if p, _, err := ParseModel(c.AgentModel(agent)); err == nil && p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// llmModels returns every model reference in use: the tier models and the
+// agents' own models, sorted and without duplicates.
+func (c *Config) llmModels() []string {
+	seen := map[string]bool{c.Anthropic.PrimaryModel: true, c.Anthropic.FastModel: true}
+	for _, agent := range c.Agents {
+		seen[c.AgentModel(agent)] = true
+	}
+	models := make([]string, 0, len(seen))
+	for model := range seen {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
+}
+
+// validateProviders validates the backends and that every agent's model is
+// served by one that is configured.
+func (c *Config) validateProviders() []string {
+	var errs []string
+
+	for _, p := range []struct {
+		name    string
+		baseURL string
+		timeout int
+	}{
+		{ProviderOpenAI, c.Providers.OpenAI.BaseURL, c.Providers.OpenAI.TimeoutSeconds},
+		{ProviderOllama, c.Providers.Ollama.BaseURL, c.Providers.Ollama.TimeoutSeconds},
+	} {
+		if p.baseURL != "" {
+			if u, err := url.Parse(p.baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, fmt.Sprintf("providers.%s.base_url must be an http or https URL", p.name))
+			}
+		}
+		if p.timeout < 0 {
+			errs = append(errs, fmt.Sprintf("providers.%s.timeout_seconds must not be negative", p.name))
+		}
+	}
+
+	if _, _, err := ParseModel(c.Anthropic.PrimaryModel); err != nil {
+		errs = append(errs, fmt.Sprintf("anthropic.primary_model: %v", err))
+	}
+	if _, _, err := ParseModel(c.Anthropic.FastModel); err != nil {
+		errs = append(errs, fmt.Sprintf("anthropic.fast_model: %v", err))
+	}
+
+	names := make([]string, 0, len(c.Agents))
+	for name := range c.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		agent := c.Agents[name]
+		provider, _, err := ParseModel(c.AgentModel(agent))
+		if err != nil {
+			// A bad tier model is reported once, above
+			if agent.Model != "" {
+				errs = append(errs, fmt.Sprintf("agents.%s.model: %v", name, err))
+			}
+			continue
+		}
+		switch {
+		case provider == ProviderOpenAI && c.Providers.OpenAI.BaseURL == "":
+			errs = append(errs, fmt.Sprintf("agents.%s uses the openai provider, which needs providers.openai.base_url", name))
+		case provider == ProviderOllama && c.Providers.Ollama.BaseURL == "":
+			errs = append(errs, fmt.Sprintf("agents.%s uses the ollama provider, which needs providers.ollama.base_url", name))
+		}
+		if agent.UseThinking && provider != ProviderAnthropic {
+			errs = append(errs, fmt.Sprintf("agents.%s.use_thinking is only supported by the anthropic provider", name))
+		}
+	}
+
+	return errs
+}
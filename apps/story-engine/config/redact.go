@@ -0,0 +1,227 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RedactedValue replaces secret values when the config is printed, logged
+// or encoded.
+const RedactedValue = "[REDACTED]"
+
+// Setting is one leaf value of the config, keyed by its dotted YAML path.
+type Setting struct {
+	Key string
+	// Env is the environment variable an env-only field is loaded from.
+	Env    string
+	Secret bool
+	Value  reflect.Value
+}
+
+// Settings returns every leaf value of the config in declaration order.
+// Map entries are walked in key order, so agents.story_writer.model is a
+// setting; slices are leaves. The env variable of a field inside a map
+// entry is suffixed with the entry's key in upper case, so the account of
+// stories.night_train is INSTAGRAM_ACCOUNT_ID_NIGHT_TRAIN.
+func (c *Config) Settings() []Setting {
+	var out []Setting
+	walkSettings(reflect.ValueOf(c).Elem(), "", "", &out)
+	return out
+}
+
+func walkSettings(v reflect.Value, prefix, envSuffix string, out *[]Setting) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := prefix + fieldKey(f)
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			walkSettings(v.Field(i), key+".", envSuffix, out)
+			continue
+		case reflect.Map:
+			walkMap(v.Field(i), key+".", out)
+			continue
+		}
+		env := f.Tag.Get("env")
+		if env != "" {
+			env += envSuffix
+		}
+		*out = append(*out, Setting{
+			Key:    key,
+			Env:    env,
+			Secret: f.Tag.Get("secret") == "true",
+			Value:  v.Field(i),
+		})
+	}
+}
+
+// walkMap adds the entries of a map, including maps decoded from YAML into
+// an any.
+func walkMap(m reflect.Value, prefix string, out *[]Setting) {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, k := range keys {
+		key := prefix + k.String()
+		value := m.MapIndex(k)
+		if value.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Struct:
+			walkSettings(value, key+".", "_"+strings.ToUpper(k.String()), out)
+		case reflect.Map:
+			walkMap(value, key+".", out)
+		default:
+			*out = append(*out, Setting{Key: key, Value: value})
+		}
+	}
+}
+
+// fieldKey is the name of a field in YAML, or for env-only fields in JSON.
+func fieldKey(f reflect.StructField) string {
+	if name := f.Tag.Get("mapstructure"); name != "" {
+		return name
+	}
+	if name := f.Tag.Get("json"); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// MinSecretLength is the shortest secret value Validate accepts. Logs mask
+// every secret value, and a shorter one would mask ordinary text.
+const MinSecretLength = 6
+
+// Secrets returns the non-empty secret values loaded from the environment,
+// for redacting them from logs and other output.
+func (c *Config) Secrets() []string {
+	var out []string
+	for _, s := range c.Settings() {
+		if s.Secret && s.Value.String() != "" {
+			out = append(out, s.Value.String())
+		}
+	}
+	return out
+}
+
+// Masked returns the display value of a setting: secrets that are set
+// become RedactedValue, structs inside maps and slices become ordered maps.
+func (s Setting) Masked() any {
+	if s.Secret {
+		if s.Value.String() == "" {
+			return ""
+		}
+		return RedactedValue
+	}
+	return view(s.Value)
+}
+
+// String returns the config as indented JSON with secrets masked.
+func (c Config) String() string {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "config: " + err.Error()
+	}
+	return string(data)
+}
+
+// GoString returns the masked form for %#v, which would otherwise print
+// every field, secrets included, without calling String.
+func (c Config) GoString() string {
+	return "config.Config" + c.String()
+}
+
+// LogValue implements slog.LogValuer, logging the config as nested groups
+// with secrets masked.
+func (c Config) LogValue() slog.Value {
+	return view(reflect.ValueOf(&c).Elem()).(ordered).logValue()
+}
+
+// MarshalJSON encodes the config with YAML key names and secrets masked.
+func (c Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(view(reflect.ValueOf(&c).Elem()))
+}
+
+// view converts v to plain values for display, masking secret fields.
+func view(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		out := make(ordered, 0, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			value := view(v.Field(i))
+			if f.Tag.Get("secret") == "true" && v.Field(i).String() != "" {
+				value = RedactedValue
+			}
+			out = append(out, keyValue{fieldKey(f), value})
+		}
+		return out
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		out := make(ordered, 0, len(keys))
+		for _, k := range keys {
+			out = append(out, keyValue{k.String(), view(v.MapIndex(k))})
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return []any{}
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = view(v.Index(i))
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// ordered is a JSON object that keeps its key order.
+type ordered []keyValue
+
+type keyValue struct {
+	key   string
+	value any
+}
+
+func (o ordered) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, kv := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(kv.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(kv.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o ordered) logValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(o))
+	for _, kv := range o {
+		if nested, ok := kv.value.(ordered); ok {
+			attrs = append(attrs, slog.Attr{Key: kv.key, Value: nested.logValue()})
+			continue
+		}
+		attrs = append(attrs, slog.Any(kv.key, kv.value))
+	}
+	return slog.GroupValue(attrs...)
+}
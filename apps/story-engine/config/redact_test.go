@@ -0,0 +1,63 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestConfigOutputMasksSecrets(t *testing.T) {
+	secrets := []string{"sk-ant-anthropic", "ig-token-main", "smtp-password", "ig-token-night-train", "https://hc-ping.com/uuid"}
+	cfg := Config{}
+	cfg.Anthropic.APIKey = secrets[0]
+	cfg.Anthropic.PrimaryModel = "claude-sonnet-4-5"
+	cfg.Instagram.AccessToken = secrets[1]
+	cfg.Email.SMTP.Password = secrets[2]
+	cfg.Stories = StoriesConfig{"night_train": {DataDir: "./stories/night_train", InstagramAccessToken: secrets[3]}}
+	cfg.Monitoring.Healthchecks.PingURL = secrets[4]
+
+	slogOutput := func(handler func(*bytes.Buffer) slog.Handler) func() string {
+		return func() string {
+			var buf bytes.Buffer
+			slog.New(handler(&buf)).Info("loaded", "config", cfg)
+			return buf.String()
+		}
+	}
+	tests := []struct {
+		name   string
+		output func() string
+	}{
+		{"String", cfg.String},
+		{"%v", func() string { return fmt.Sprintf("%v", cfg) }},
+		{"%+v", func() string { return fmt.Sprintf("%+v", cfg) }},
+		{"%#v", func() string { return fmt.Sprintf("%#v", cfg) }},
+		{"%+v pointer", func() string { return fmt.Sprintf("%+v", &cfg) }},
+		{"%#v pointer", func() string { return fmt.Sprintf("%#v", &cfg) }},
+		{"json.Marshal", func() string {
+			data, err := json.Marshal(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(data)
+		}},
+		{"slog json", slogOutput(func(b *bytes.Buffer) slog.Handler { return slog.NewJSONHandler(b, nil) })},
+		{"slog text", slogOutput(func(b *bytes.Buffer) slog.Handler { return slog.NewTextHandler(b, nil) })},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := tt.output()
+			for _, secret := range secrets {
+				if strings.Contains(out, secret) {
+					t.Errorf("output contains %q:\n%s", secret, out)
+				}
+			}
+			if !strings.Contains(out, RedactedValue) || !strings.Contains(out, "claude-sonnet-4-5") {
+				t.Errorf("output should show settings with secrets masked:\n%s", out)
+			}
+		})
+	}
+}
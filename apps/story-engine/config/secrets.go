@@ -0,0 +1,384 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Secret source types, set in secret_source.type.
+const (
+	SecretSourceEnv    = "env"
+	SecretSourceDotenv = "dotenv"
+	SecretSourceGCP    = "gcp_secret_manager"
+	SecretSourceFile   = "file"
+)
+
+const defaultSecretTimeout = 10 * time.Second
+
+// SecretSource supplies the env-only config values (API keys, tokens,
+// recipients), looked up by their environment variable names. Load fetches
+// every name in one call so remote sources are asked once per process.
+type SecretSource interface {
+	// Fetch returns the values that are set; missing names are left out.
+	// A source that can read some names but not others returns the values
+	// it read with a SecretErrors naming the rest.
+	Fetch(ctx context.Context, names []string) (map[string]string, error)
+}
+
+// SecretErrors are the names a source failed to read, with why. Load treats
+// them as unset: an optional secret is skipped with a warning and a missing
+// required one is reported with the reason.
+type SecretErrors map[string]error
+
+func (e SecretErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %v", name, e[name])
+	}
+	return "failed to read secrets: " + strings.Join(msgs, "; ")
+}
+
+// NewSecretSource creates the source selected by cfg. An empty type is the
+// process environment.
+func NewSecretSource(cfg SecretSourceConfig) (SecretSource, error) {
+	switch cfg.Type {
+	case "", SecretSourceEnv:
+		return EnvSource{}, nil
+	case SecretSourceDotenv:
+		if cfg.DotenvFile == "" {
+			return nil, errors.New("secret_source.dotenv_file is required for the dotenv source")
+		}
+		return DotenvSource{Path: cfg.DotenvFile}, nil
+	case SecretSourceFile:
+		if cfg.File == "" {
+			return nil, errors.New("secret_source.file is required for the file source")
+		}
+		return FileSource{Path: cfg.File}, nil
+	case SecretSourceGCP:
+		return NewGCPSecretManager(cfg.GCPProjectID), nil
+	default:
+		return nil, fmt.Errorf("unknown secret_source.type %q", cfg.Type)
+	}
+}
+
+// EnvSource reads secrets from the process environment.
+type EnvSource struct{}
+
+func (EnvSource) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			values[name] = v
+		}
+	}
+	return values, nil
+}
+
+// DotenvSource reads secrets from a dotenv file without changing the
+// process environment.
+type DotenvSource struct {
+	Path string
+}
+
+func (s DotenvSource) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	all, err := godotenv.Read(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.Path, err)
+	}
+	return pick(all, names), nil
+}
+
+// FileSource reads secrets from a JSON object of name to value. It stands
+// in for a secret manager in tests and local runs.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.Path, err)
+	}
+	var all map[string]string
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.Path, err)
+	}
+	return pick(all, names), nil
+}
+
+func pick(all map[string]string, names []string) map[string]string {
+	values := make(map[string]string)
+	for _, name := range names {
+		if v := all[name]; v != "" {
+			values[name] = v
+		}
+	}
+	return values
+}
+
+// GCPSecretManager reads the latest version of each secret from Google
+// Secret Manager, authenticating as the service account of the metadata
+// server (Cloud Run, GCE). Secret ids are the environment variable names.
+type GCPSecretManager struct {
+	ProjectID   string
+	MetadataURL string
+	APIURL      string
+	HTTP        *http.Client
+}
+
+// NewGCPSecretManager creates a Secret Manager source. An empty projectID
+// is read from the metadata server.
+func NewGCPSecretManager(projectID string) *GCPSecretManager {
+	return &GCPSecretManager{
+		ProjectID:   projectID,
+		MetadataURL: "http://metadata.google.internal/computeMetadata/v1",
+		APIURL:      "https://secretmanager.googleapis.com/v1",
+		HTTP:        &http.Client{},
+	}
+}
+
+func (s *GCPSecretManager) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := s.metadata(ctx, "/instance/service-accounts/default/token", &token); err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	project := s.ProjectID
+	if project == "" {
+		if err := s.metadata(ctx, "/project/project-id", &project); err != nil {
+			return nil, fmt.Errorf("failed to get project id: %w", err)
+		}
+	}
+
+	// A secret the service account can't read (403) is one this
+	// deployment doesn't use, unless Load finds it required
+	values := make(map[string]string)
+	failed := make(SecretErrors)
+	for _, name := range names {
+		value, err := s.access(ctx, token.AccessToken, project, name)
+		if err != nil {
+			failed[name] = err
+			continue
+		}
+		if value != "" {
+			values[name] = value
+		}
+	}
+	if len(failed) > 0 {
+		return values, failed
+	}
+	return values, nil
+}
+
+// metadata reads a metadata server path into out, a JSON target or a
+// *string for plain text.
+func (s *GCPSecretManager) metadata(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.MetadataURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	data, status, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("metadata server returned %d", status)
+	}
+	if text, ok := out.(*string); ok {
+		*text = strings.TrimSpace(string(data))
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// access returns the latest version of a secret, or "" if it doesn't exist.
+func (s *GCPSecretManager) access(ctx context.Context, token, project, name string) (string, error) {
+	endpoint := fmt.Sprintf("%s/projects/%s/secrets/%s/versions/latest:access",
+		s.APIURL, url.PathEscape(project), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	data, status, err := s.do(req)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case status == http.StatusNotFound:
+		return "", nil
+	case status != http.StatusOK:
+		// The error body names the resource, never the secret value
+		return "", fmt.Errorf("secret manager returned %d: %s", status, strings.TrimSpace(string(data)))
+	}
+
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	value, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode payload: %w", err)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func (s *GCPSecretManager) do(req *http.Request) ([]byte, int, error) {
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return data, resp.StatusCode, err
+}
+
+// secretValues are the fetched env-only values, read by Load.
+type secretValues struct {
+	source string
+	values map[string]string
+	// failed are the names the source could not read.
+	failed SecretErrors
+	// fetched are the names asked of the source, read or not.
+	fetched map[string]bool
+}
+
+// fetchSecrets fetches the named values from the configured source, once,
+// within secret_source.timeout_seconds.
+func fetchSecrets(cfg SecretSourceConfig, names []string) (*secretValues, error) {
+	source, err := NewSecretSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return fetchFrom(source, cfg, names)
+}
+
+// fetchFrom is fetchSecrets from an already created source.
+func fetchFrom(source SecretSource, cfg SecretSourceConfig, names []string) (*secretValues, error) {
+	timeout := defaultSecretTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	values, err := source.Fetch(ctx, names)
+	var failed SecretErrors
+	if errors.As(err, &failed) {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+	if values == nil {
+		values = make(map[string]string)
+	}
+
+	name := cfg.Type
+	if name == "" {
+		name = SecretSourceEnv
+	}
+	fetched := make(map[string]bool, len(names))
+	for _, n := range names {
+		fetched[n] = true
+	}
+	return &secretValues{source: name, values: values, failed: failed, fetched: fetched}, nil
+}
+
+// withNames returns the values with any of names not fetched yet fetched
+// from the source in cfg, so a story added on reload gets its secrets.
+func (s *secretValues) withNames(cfg SecretSourceConfig, names []string) (*secretValues, error) {
+	var missing []string
+	for _, n := range names {
+		if !s.fetched[n] {
+			missing = append(missing, n)
+		}
+	}
+	if len(missing) == 0 {
+		return s, nil
+	}
+	more, err := fetchSecrets(cfg, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &secretValues{
+		source:  s.source,
+		values:  make(map[string]string, len(s.values)+len(more.values)),
+		failed:  make(SecretErrors, len(s.failed)+len(more.failed)),
+		fetched: make(map[string]bool, len(s.fetched)+len(more.fetched)),
+	}
+	for _, from := range []*secretValues{s, more} {
+		for k, v := range from.values {
+			merged.values[k] = v
+		}
+		for k, err := range from.failed {
+			merged.failed[k] = err
+		}
+		for k := range from.fetched {
+			merged.fetched[k] = true
+		}
+	}
+	return merged, nil
+}
+
+// secretNames lists the environment variable names of all env-only fields.
+func secretNames() []string {
+	var names []string
+	for _, s := range (&Config{}).Settings() {
+		if s.Env != "" {
+			names = append(names, s.Env)
+		}
+	}
+	return names
+}
+
+// require loads a required value, recording an error if it is missing.
+func (s *secretValues) require(key string, errs *[]string) string {
+	val := s.values[key]
+	if val == "" {
+		if err, ok := s.failed[key]; ok {
+			*errs = append(*errs, fmt.Sprintf("%s is required but could not be read (secret source %s): %v", key, s.source, err))
+		} else if s.source == SecretSourceEnv {
+			*errs = append(*errs, fmt.Sprintf("%s environment variable is required", key))
+		} else {
+			*errs = append(*errs, fmt.Sprintf("%s is required (secret source %s)", key, s.source))
+		}
+	}
+	return val
+}
+
+// optional loads an optional value. One the source failed to read is
+// treated as unset, with a warning.
+func (s *secretValues) optional(key string) string {
+	if err, ok := s.failed[key]; ok {
+		log.Printf("Warning: optional secret %s could not be read (secret source %s), treating it as unset: %v", key, s.source, err)
+	}
+	return s.values[key]
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// baseSecrets are the secrets the shipped pipeline.yaml requires.
+func baseSecrets() map[string]string {
+	return map[string]string{
+		"ANTHROPIC_API_KEY":            "sk-ant-test",
+		"INSTAGRAM_ACCOUNT_ID":         "1789",
+		"INSTAGRAM_ACCESS_TOKEN":       "ig-token",
+		"BFL_API_KEY":                  "bfl-key",
+		"SMTP_USER":                    "bot@example.com",
+		"SMTP_PASSWORD":                "smtp-pass",
+		"SENDGRID_API_KEY":             "sg-key",
+		"EMAIL_RECIPIENT_DAILY_REPORT": "editor@example.com",
+		"EMAIL_RECIPIENT_ERROR_ALERTS": "ops@example.com",
+		"HEALTHCHECKS_PING_URL":        "https://hc-ping.com/test",
+	}
+}
+
+// loadWithFileSource loads the shipped pipeline.yaml with its secrets read
+// from a FileSource holding secrets.
+func loadWithFileSource(t *testing.T, secrets map[string]string, overrides ...string) (*Config, error) {
+	t.Helper()
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	overrides = append([]string{
+		"secret_source.type=file",
+		"secret_source.file=" + path,
+		// Tests run in config/, which holds the prompts
+		"paths.prompts_dir=prompts",
+	}, overrides...)
+	return LoadWithOptions("pipeline.yaml", Options{Overrides: overrides})
+}
+
+func TestLoadFileSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		drop    []string
+		set     map[string]string
+		wantErr string
+		check   func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "all secrets",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Instagram.AccessToken != "ig-token" {
+					t.Errorf("Instagram.AccessToken = %q, want ig-token", cfg.Instagram.AccessToken)
+				}
+				if got := cfg.Email.Recipients.ErrorAlerts; len(got) != 1 || got[0] != "ops@example.com" {
+					t.Errorf("Email.Recipients.ErrorAlerts = %q, want [ops@example.com]", got)
+				}
+			},
+		},
+		{
+			name:    "required secret missing",
+			drop:    []string{"INSTAGRAM_ACCESS_TOKEN"},
+			wantErr: "INSTAGRAM_ACCESS_TOKEN is required (secret source file)",
+		},
+		{
+			name:    "one image key required",
+			drop:    []string{"BFL_API_KEY"},
+			wantErr: "BFL_API_KEY or REPLICATE_API_TOKEN",
+		},
+		{
+			name:    "secret too short to mask",
+			set:     map[string]string{"SMTP_PASSWORD": "abc"},
+			wantErr: "SMTP_PASSWORD is shorter than 6 characters",
+		},
+		{
+			name: "optional secret missing",
+			drop: []string{"OPENAI_API_KEY", "REPLICATE_API_TOKEN"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Providers.OpenAI.APIKey != "" {
+					t.Errorf("Providers.OpenAI.APIKey = %q, want unset", cfg.Providers.OpenAI.APIKey)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets := baseSecrets()
+			for _, name := range tt.drop {
+				delete(secrets, name)
+			}
+			for name, value := range tt.set {
+				secrets[name] = value
+			}
+			cfg, err := loadWithFileSource(t, secrets)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadWithOptions error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadWithOptions: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+// blockingSource never answers, so only the fetch timeout ends a Fetch.
+type blockingSource struct{}
+
+func (blockingSource) Fetch(ctx context.Context, names []string) (map[string]string, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestFetchTimeout(t *testing.T) {
+	began := time.Now()
+	_, err := fetchFrom(blockingSource{}, SecretSourceConfig{Type: SecretSourceFile, TimeoutSeconds: 1}, secretNames())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("fetchFrom error = %v, want a deadline exceeded", err)
+	}
+	if elapsed := time.Since(began); elapsed > 3*time.Second {
+		t.Errorf("fetchFrom took %s, want the 1s timeout honoured", elapsed)
+	}
+}
+
+// fakeSecretManager serves the metadata server and the Secret Manager
+// access endpoint. Secrets in denied answer 403.
+func fakeSecretManager(t *testing.T, secrets map[string]string, denied ...string) *GCPSecretManager {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/metadata/instance/service-accounts/default/token":
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "token"})
+			return
+		case r.Header.Get("Authorization") != "Bearer token":
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/projects/story/secrets/"), "/versions/latest:access")
+		for _, d := range denied {
+			if d == name {
+				http.Error(w, `{"error": {"code": 403, "status": "PERMISSION_DENIED"}}`, http.StatusForbidden)
+				return
+			}
+		}
+		value, ok := secrets[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		payload := base64.StdEncoding.EncodeToString([]byte(value))
+		json.NewEncoder(w).Encode(map[string]any{"payload": map[string]string{"data": payload}})
+	}))
+	t.Cleanup(srv.Close)
+	return &GCPSecretManager{
+		ProjectID:   "story",
+		MetadataURL: srv.URL + "/metadata",
+		APIURL:      srv.URL + "/api",
+		HTTP:        srv.Client(),
+	}
+}
+
+func TestGCPSecretManagerDenied(t *testing.T) {
+	tests := []struct {
+		name    string
+		denied  string
+		wantErr string
+	}{
+		{name: "optional secret denied", denied: "OPENAI_API_KEY"},
+		{name: "required secret denied", denied: "INSTAGRAM_ACCESS_TOKEN", wantErr: "INSTAGRAM_ACCESS_TOKEN is required but could not be read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := fakeSecretManager(t, baseSecrets(), tt.denied)
+			secrets, err := fetchFrom(source, SecretSourceConfig{Type: SecretSourceGCP}, secretNames())
+			if err != nil {
+				t.Fatalf("fetchFrom: %v, want a denied secret treated as absent", err)
+			}
+			if _, ok := secrets.failed[tt.denied]; !ok {
+				t.Errorf("failed = %v, want %s", secrets.failed, tt.denied)
+			}
+			if got := secrets.values["ANTHROPIC_API_KEY"]; got != "sk-ant-test" {
+				t.Errorf("ANTHROPIC_API_KEY = %q, want the other secrets still read", got)
+			}
+
+			cfg, err := LoadWithOptions("pipeline.yaml", Options{
+				Overrides: []string{"paths.prompts_dir=prompts"},
+				secrets:   secrets,
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadWithOptions error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if !strings.Contains(err.Error(), "403") {
+					t.Errorf("LoadWithOptions error = %v, want the 403 reason", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadWithOptions: %v", err)
+			}
+			if cfg.Providers.OpenAI.APIKey != "" {
+				t.Errorf("Providers.OpenAI.APIKey = %q, want unset", cfg.Providers.OpenAI.APIKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/scheduler"
+)
+
+// StoriesConfig is the registry of stories one deployment runs, keyed by
+// story id. When it is empty the deployment runs a single story from
+// paths.data_dir with the top-level settings.
+type StoriesConfig map[string]SerialConfig
+
+// SerialConfig is one story of the registry. Everything it doesn't set is
+// taken from the top-level config.
+//
+// A story's Instagram account and email recipients are loaded from env
+// only, from the usual variable names suffixed with the story id in upper
+// case: INSTAGRAM_ACCOUNT_ID_<ID>, INSTAGRAM_ACCESS_TOKEN_<ID>,
+// EMAIL_RECIPIENT_DAILY_REPORT_<ID> and EMAIL_RECIPIENT_ERROR_ALERTS_<ID>.
+// Unset ones fall back to the unsuffixed variables.
+type SerialConfig struct {
+	// DataDir holds the story's bible, entities, archive and runs.
+	DataDir string `mapstructure:"data_dir"`
+	// PromptsDir holds prompt files that replace those of the same name in
+	// paths.prompts_dir for this story.
+	PromptsDir string `mapstructure:"prompts_dir"`
+	Schedule   string `mapstructure:"schedule"`
+	Timezone   string `mapstructure:"timezone"`
+	// CostLog is the story's cost ledger. It defaults to a directory named
+	// after the story next to monitoring.cost_tracking.log_file, so budgets
+	// and alerts apply per story.
+	CostLog string `mapstructure:"cost_log"`
+
+	InstagramAccountID   string   `json:"instagram_account_id" env:"INSTAGRAM_ACCOUNT_ID"`                   // Loaded from env only
+	InstagramAccessToken string   `json:"instagram_access_token" env:"INSTAGRAM_ACCESS_TOKEN" secret:"true"` // Loaded from env only
+	DailyReport          string   `json:"daily_report" env:"EMAIL_RECIPIENT_DAILY_REPORT"`                   // Loaded from env only
+	ErrorAlerts          []string `json:"error_alerts" env:"EMAIL_RECIPIENT_ERROR_ALERTS"`                   // Loaded from env only
+}
+
+// storyIDPattern keeps story ids usable in env variable names and paths.
+var storyIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// The env-only values a story can override, by unsuffixed variable name.
+const (
+	envInstagramAccountID   = "INSTAGRAM_ACCOUNT_ID"
+	envInstagramAccessToken = "INSTAGRAM_ACCESS_TOKEN"
+	envDailyReport          = "EMAIL_RECIPIENT_DAILY_REPORT"
+	envErrorAlerts          = "EMAIL_RECIPIENT_ERROR_ALERTS"
+)
+
+// storyEnv is the name of a story's own variable for base.
+func storyEnv(base, id string) string {
+	return base + "_" + strings.ToUpper(id)
+}
+
+// StoryIDs returns the registered story ids in order.
+func (c *Config) StoryIDs() []string {
+	ids := make([]string, 0, len(c.Stories))
+	for id := range c.Stories {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ForStory returns the config of one registered story: the top-level
+// config with the story's data dir, prompt overrides, schedule, timezone,
+// cost ledger, Instagram account and recipients in place.
+func (c *Config) ForStory(id string) (*Config, error) {
+	s, ok := c.Stories[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown story %q (configured: %s)", id, strings.Join(c.StoryIDs(), ", "))
+	}
+
+	story := c.clone()
+	story.Paths.DataDir = s.DataDir
+	if s.PromptsDir != "" {
+		story.Paths.PromptsOverrideDir = s.PromptsDir
+	}
+	if s.Schedule != "" {
+		story.Pipeline.Schedule = s.Schedule
+	}
+	if s.Timezone != "" {
+		story.Pipeline.Timezone = s.Timezone
+	}
+	story.Monitoring.CostTracking.LogFile = c.storyCostLog(id)
+	if s.InstagramAccountID != "" {
+		story.Instagram.AccountID = s.InstagramAccountID
+	}
+	if s.InstagramAccessToken != "" {
+		story.Instagram.AccessToken = s.InstagramAccessToken
+	}
+	if s.DailyReport != "" {
+		story.Email.Recipients.DailyReport = s.DailyReport
+	}
+	if len(s.ErrorAlerts) > 0 {
+		story.Email.Recipients.ErrorAlerts = s.ErrorAlerts
+	}
+	return story, nil
+}
+
+// clone copies c with its own maps and slices, so a story's config can be
+// changed without touching the config it came from or its other stories.
+func (c *Config) clone() *Config {
+	cp := *c
+	cp.Agents = make(AgentsConfig, len(c.Agents))
+	for name, agent := range c.Agents {
+		agent.Params = maps.Clone(agent.Params)
+		cp.Agents[name] = agent
+	}
+	cp.Stories = maps.Clone(c.Stories)
+	cp.ImageGeneration.StyleAnchors = slices.Clone(c.ImageGeneration.StyleAnchors)
+	cp.Email.Recipients.ErrorAlerts = slices.Clone(c.Email.Recipients.ErrorAlerts)
+	cp.Monitoring.CostTracking.ModelPrices = maps.Clone(c.Monitoring.CostTracking.ModelPrices)
+	cp.Monitoring.CostTracking.ImagePrices = maps.Clone(c.Monitoring.CostTracking.ImagePrices)
+	return &cp
+}
+
+// storyCostLog is the cost ledger of a story.
+func (c *Config) storyCostLog(id string) string {
+	if log := c.Stories[id].CostLog; log != "" {
+		return log
+	}
+	base := c.Monitoring.CostTracking.LogFile
+	return filepath.Join(filepath.Dir(base), id, filepath.Base(base))
+}
+
+// storySecretNames lists the env-only variables of every story.
+func (c *Config) storySecretNames() []string {
+	var names []string
+	for _, id := range c.StoryIDs() {
+		for _, base := range []string{envInstagramAccountID, envInstagramAccessToken, envDailyReport, envErrorAlerts} {
+			names = append(names, storyEnv(base, id))
+		}
+	}
+	return names
+}
+
+// loadStorySecrets sets the env-only values of every story.
+func (c *Config) loadStorySecrets(secrets *secretValues) {
+	for id, s := range c.Stories {
+		s.InstagramAccountID = secrets.optional(storyEnv(envInstagramAccountID, id))
+		s.InstagramAccessToken = secrets.optional(storyEnv(envInstagramAccessToken, id))
+		s.DailyReport = secrets.optional(storyEnv(envDailyReport, id))
+		s.ErrorAlerts = splitRecipients(secrets.optional(storyEnv(envErrorAlerts, id)))
+		c.Stories[id] = s
+	}
+}
+
+// validateStories validates the story registry. Stories must not share a
+// data dir, cost ledger or Instagram account.
+func (c *Config) validateStories() []string {
+	var errs []string
+	dataDirs := make(map[string]string)
+	costLogs := make(map[string]string)
+	accounts := make(map[string]string)
+
+	for _, id := range c.StoryIDs() {
+		s := c.Stories[id]
+		key := "stories." + id
+		if !storyIDPattern.MatchString(id) {
+			errs = append(errs, fmt.Sprintf("%s: story ids must be lower case letters, digits and underscores, starting with a letter", key))
+		}
+
+		if s.DataDir == "" {
+			errs = append(errs, key+".data_dir is required")
+		} else if other, ok := dataDirs[filepath.Clean(s.DataDir)]; ok {
+			errs = append(errs, fmt.Sprintf("%s.data_dir is also the data dir of story %s", key, other))
+		} else {
+			dataDirs[filepath.Clean(s.DataDir)] = id
+		}
+
+		if s.PromptsDir != "" {
+			if info, err := os.Stat(s.PromptsDir); err != nil || !info.IsDir() {
+				errs = append(errs, fmt.Sprintf("%s.prompts_dir %s is not a directory", key, s.PromptsDir))
+			}
+		}
+		if s.Schedule != "" {
+			if _, err := scheduler.Parse(s.Schedule); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.schedule: %v", key, err))
+			}
+		}
+		if s.Timezone != "" {
+			if _, err := time.LoadLocation(s.Timezone); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.timezone '%s' is not a valid timezone", key, s.Timezone))
+			}
+		}
+
+		costLog := filepath.Clean(c.storyCostLog(id))
+		if other, ok := costLogs[costLog]; ok {
+			errs = append(errs, fmt.Sprintf("%s.cost_log is also the cost log of story %s", key, other))
+		}
+		costLogs[costLog] = id
+
+		if s.InstagramAccountID != "" && s.InstagramAccessToken == "" {
+			errs = append(errs, fmt.Sprintf("%s has its own Instagram account, so %s is required", key, storyEnv(envInstagramAccessToken, id)))
+		}
+		account := s.InstagramAccountID
+		if account == "" {
+			account = c.Instagram.AccountID
+		}
+		if other, ok := accounts[account]; ok && account != "" {
+			errs = append(errs, fmt.Sprintf("%s posts to the same Instagram account as story %s; set %s", key, other, storyEnv(envInstagramAccountID, id)))
+		}
+		accounts[account] = id
+	}
+	return errs
+}
@@ -0,0 +1,48 @@
+package config
+
+import "testing"
+
+func TestForStoryCopies(t *testing.T) {
+	cfg := &Config{
+		Agents: AgentsConfig{
+			"canon_checker": {Tier: "fast_model", Params: map[string]any{"max_flags": 5}},
+		},
+		Stories: StoriesConfig{
+			"night_train": {DataDir: "./stories/night_train"},
+			"lighthouse":  {DataDir: "./stories/lighthouse"},
+		},
+	}
+	cfg.Monitoring.CostTracking.LogFile = "./metrics/costs.json"
+	cfg.Monitoring.CostTracking.ModelPrices = map[string]ModelPrice{"claude-haiku": {}}
+
+	story, err := cfg.ForStory("night_train")
+	if err != nil {
+		t.Fatalf("ForStory: %v", err)
+	}
+
+	// Overrides on one story leave the top-level config and the other
+	// stories alone
+	agent := story.Agents["canon_checker"]
+	agent.Tier = "primary_model"
+	agent.Params["max_flags"] = 1
+	story.Agents["canon_checker"] = agent
+	story.Agents["comment_filter"] = AgentConfig{}
+	story.Monitoring.CostTracking.ModelPrices["claude-opus"] = ModelPrice{}
+	delete(story.Stories, "lighthouse")
+
+	if got := cfg.Agents["canon_checker"]; got.Tier != "fast_model" || got.Params["max_flags"] != 5 {
+		t.Errorf("top-level canon_checker = %+v, want it unchanged", got)
+	}
+	if len(cfg.Agents) != 1 {
+		t.Errorf("top-level agents = %v, want only canon_checker", cfg.Agents)
+	}
+	if _, ok := cfg.Monitoring.CostTracking.ModelPrices["claude-opus"]; ok {
+		t.Error("a story's model price was added to the top-level config")
+	}
+	if _, err := cfg.ForStory("lighthouse"); err != nil {
+		t.Errorf("ForStory(lighthouse) after changing night_train: %v", err)
+	}
+	if story.Paths.DataDir != "./stories/night_train" || cfg.Paths.DataDir != "" {
+		t.Errorf("data dirs = %q and %q, want only the story's set", story.Paths.DataDir, cfg.Paths.DataDir)
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay lets an editor finish writing before the file is re-read.
+const reloadDelay = 500 * time.Millisecond
+
+// Snapshot is one loaded version of the config.
+type Snapshot struct {
+	Config *Config
+	// Version counts loads, starting at 1.
+	Version int
+	// Hash identifies the effective config. Secrets are masked before
+	// hashing, so it reveals nothing about them.
+	Hash     string
+	LoadedAt time.Time
+
+	// secrets are reused by the next reload.
+	secrets *secretValues
+}
+
+// Reloader holds the current config and replaces it when the config files
+// change. A change that fails to load, validate or pass Check keeps the
+// previous config. Secrets already read are not fetched again on reload;
+// those of a story added by the change are.
+type Reloader struct {
+	// Check, if set, vets a reloaded config before it is swapped in.
+	// Set it before calling Watch.
+	Check func(*Config) error
+
+	path    string
+	opts    Options
+	current atomic.Pointer[Snapshot]
+}
+
+// NewReloader starts from cfg, loaded from path with opts.
+func NewReloader(path string, opts Options, cfg *Config) *Reloader {
+	r := &Reloader{path: path, opts: opts}
+	first := newSnapshot(cfg, 1)
+	first.secrets = secretsFrom(cfg)
+	r.current.Store(first)
+	return r
+}
+
+// Current returns the config to use for the next run.
+func (r *Reloader) Current() *Snapshot {
+	return r.current.Load()
+}
+
+// Reload re-reads the config files, keeping the current secrets and
+// fetching those of new stories, and swaps in the result if it is valid.
+func (r *Reloader) Reload() (*Snapshot, error) {
+	prev := r.Current()
+	opts := r.opts
+	opts.secrets = prev.secrets
+
+	state, err := load(r.path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.Check != nil {
+		if err := r.Check(state.cfg); err != nil {
+			return nil, err
+		}
+	}
+	next := newSnapshot(state.cfg, prev.Version+1)
+	next.secrets = state.secrets
+	if next.Hash == prev.Hash {
+		return prev, nil
+	}
+	r.current.Store(next)
+	return next, nil
+}
+
+// Watch reloads whenever the base or profile file changes, until ctx is
+// cancelled. onReload is called after each attempt with the new snapshot,
+// or the error that kept the old one.
+func (r *Reloader) Watch(ctx context.Context, onReload func(*Snapshot, error)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch config: %w", err)
+	}
+	defer watcher.Close()
+
+	files := map[string]bool{filepath.Clean(r.path): true}
+	if r.opts.Profile != "" {
+		profile, err := ProfilePath(r.path, r.opts.Profile)
+		if err != nil {
+			return err
+		}
+		files[filepath.Clean(profile)] = true
+	}
+	// Watch the directory: editors often save by renaming a new file over
+	// the old one, which drops a watch on the file itself.
+	if err := watcher.Add(filepath.Dir(r.path)); err != nil {
+		return fmt.Errorf("failed to watch config: %w", err)
+	}
+
+	timer := time.NewTimer(0)
+	<-timer.C
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if files[filepath.Clean(event.Name)] && !event.Has(fsnotify.Chmod) {
+				timer.Reset(reloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			onReload(nil, fmt.Errorf("config watcher: %w", err))
+		case <-timer.C:
+			onReload(r.Reload())
+		}
+	}
+}
+
+func newSnapshot(cfg *Config, version int) *Snapshot {
+	data, _ := json.Marshal(cfg)
+	sum := sha256.Sum256(data)
+	return &Snapshot{
+		Config:   cfg,
+		Version:  version,
+		Hash:     hex.EncodeToString(sum[:])[:12],
+		LoadedAt: time.Now(),
+	}
+}
+
+// secretsFrom recovers the secret values of a loaded config so a reload
+// doesn't fetch them again. Every name Load fetched for cfg counts as
+// fetched, set or not.
+func secretsFrom(cfg *Config) *secretValues {
+	values := make(map[string]string)
+	for _, s := range cfg.Settings() {
+		if s.Env == "" {
+			continue
+		}
+		var value string
+		if list, ok := s.Value.Interface().([]string); ok {
+			value = strings.Join(list, ",")
+		} else {
+			value = s.Value.String()
+		}
+		if value != "" {
+			values[s.Env] = value
+		}
+	}
+	source := cfg.SecretSource.Type
+	if source == "" {
+		source = SecretSourceEnv
+	}
+	fetched := make(map[string]bool)
+	for _, name := range append(secretNames(), cfg.storySecretNames()...) {
+		fetched[name] = true
+	}
+	return &secretValues{source: source, values: values, fetched: fetched}
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestReloader loads a copy of the shipped pipeline.yaml with its
+// secrets read from secrets.json in the same temp dir.
+func newTestReloader(t *testing.T) (r *Reloader, dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	base, err := os.ReadFile("pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pipeline.yaml"), base, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeJSON(t, filepath.Join(dir, "secrets.json"), baseSecrets())
+
+	prompts, err := filepath.Abs("prompts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Overrides: []string{
+		"secret_source.type=file",
+		"secret_source.file=" + filepath.Join(dir, "secrets.json"),
+		"paths.prompts_dir=" + prompts,
+	}}
+	path := filepath.Join(dir, "pipeline.yaml")
+	cfg, err := LoadWithOptions(path, opts)
+	if err != nil {
+		t.Fatalf("LoadWithOptions: %v", err)
+	}
+	return NewReloader(path, opts, cfg), dir
+}
+
+// addStory appends a story to the reloader's pipeline.yaml.
+func addStory(t *testing.T, dir, id string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(dir, "pipeline.yaml"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString("\nstories:\n  " + id + ":\n    data_dir: \"./stories/" + id + "\"\n"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadFetchesNewStorySecrets(t *testing.T) {
+	r, dir := newTestReloader(t)
+
+	// The story's secrets arrive with the edit; a rotated base secret is
+	// not picked up until restart
+	secrets := baseSecrets()
+	secrets["ANTHROPIC_API_KEY"] = "sk-ant-rotated"
+	secrets["INSTAGRAM_ACCOUNT_ID_NIGHT_TRAIN"] = "2024"
+	secrets["INSTAGRAM_ACCESS_TOKEN_NIGHT_TRAIN"] = "ig-night-train"
+	secrets["EMAIL_RECIPIENT_DAILY_REPORT_NIGHT_TRAIN"] = "night@example.com"
+	writeJSON(t, filepath.Join(dir, "secrets.json"), secrets)
+	addStory(t, dir, "night_train")
+
+	snapshot, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if snapshot.Version != 2 {
+		t.Errorf("Version = %d, want 2", snapshot.Version)
+	}
+	story := snapshot.Config.Stories["night_train"]
+	if story.InstagramAccountID != "2024" || story.InstagramAccessToken != "ig-night-train" || story.DailyReport != "night@example.com" {
+		t.Errorf("story secrets = %q, %q, %q, want them fetched on reload", story.InstagramAccountID, story.InstagramAccessToken, story.DailyReport)
+	}
+	if got := snapshot.Config.Anthropic.APIKey; got != "sk-ant-test" {
+		t.Errorf("Anthropic.APIKey = %q, want the secret read at start-up kept", got)
+	}
+
+	// Names already fetched, read or not, are not fetched again
+	if err := os.Remove(filepath.Join(dir, "secrets.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reload(); err != nil {
+		t.Errorf("Reload with nothing new to fetch: %v", err)
+	}
+}
+
+func TestReloadCheck(t *testing.T) {
+	r, dir := newTestReloader(t)
+	r.Check = func(cfg *Config) error {
+		if _, ok := cfg.Stories["broken"]; ok {
+			return errors.New("failed to load prompts for story broken")
+		}
+		return nil
+	}
+
+	addStory(t, dir, "broken")
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("Reload succeeded, want the failed check to reject it")
+	}
+	if got := r.Current(); got.Version != 1 || len(got.Config.Stories) != 0 {
+		t.Errorf("Current = version %d with %d stories, want the old config kept", got.Version, len(got.Config.Stories))
+	}
+}
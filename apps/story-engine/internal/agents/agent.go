@@ -0,0 +1,68 @@
+package agents
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/prompts"
+)
+
+// loadAgent returns an agent's registry entry and its system prompt,
+// rendered from its template in the prompts directory.
+func loadAgent(cfg *config.Config, name string) (config.AgentConfig, string, error) {
+	agentCfg, err := cfg.Agent(name)
+	if err != nil {
+		return config.AgentConfig{}, "", err
+	}
+	prompt, err := prompts.Render(cfg, agentCfg.Prompt)
+	if err != nil {
+		return config.AgentConfig{}, "", err
+	}
+	return agentCfg, prompt, nil
+}
+
+// newRequest builds a request for an agent from its configuration: model,
+// max tokens, temperature and extended thinking.
+func newRequest(cfg *config.Config, agent string, agentCfg config.AgentConfig) Request {
+	req := Request{
+		Agent:     agent,
+		Model:     cfg.AgentModel(agentCfg),
+		MaxTokens: cfg.Anthropic.MaxTokens,
+	}
+
+	// Temperature cannot be combined with extended thinking
+	if agentCfg.UseThinking && cfg.Anthropic.Thinking.Enabled {
+		req.Thinking = &Thinking{Type: "enabled", BudgetTokens: agentCfg.ThinkingBudget}
+		req.MaxTokens += agentCfg.ThinkingBudget
+	} else if agentCfg.Temperature > 0 {
+		t := agentCfg.Temperature
+		req.Temperature = &t
+	}
+
+	return req
+}
+
+// decodeJSON extracts the JSON object from a model response, tolerating
+// markdown code fences and surrounding prose, and decodes it into v.
+func decodeJSON(text string, v any) error {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("response contains no JSON object")
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), v); err != nil {
+		return fmt.Errorf("failed to decode response JSON: %w", err)
+	}
+	return nil
+}
+
+// encodeJSON renders v as indented JSON for inclusion in a prompt.
+func encodeJSON(v any) string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,142 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com"
+	anthropicVersion = "2023-06-01"
+
+	// maxCacheBreakpoints is the most cache_control markers a request may
+	// carry.
+	maxCacheBreakpoints = 4
+)
+
+// AnthropicProvider calls the Anthropic Messages API.
+type AnthropicProvider struct {
+	apiKey  string
+	baseURL string
+	caching config.PromptCachingConfig
+	http    *http.Client
+}
+
+// NewAnthropicProvider creates a provider from the Anthropic configuration.
+// With prompt caching enabled, the system prompt and each message's stable
+// prefix (blocks up to one marked Cache) are cached.
+func NewAnthropicProvider(cfg config.AnthropicConfig) *AnthropicProvider {
+	return &AnthropicProvider{
+		apiKey:  cfg.APIKey,
+		baseURL: defaultBaseURL,
+		caching: cfg.PromptCaching,
+		http:    httpClient(0),
+	}
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      []anthropicBlock   `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	Thinking    *Thinking          `json:"thinking,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text"`
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+type cacheControl struct {
+	Type string `json:"type"`
+	TTL  string `json:"ttl,omitempty"`
+}
+
+type anthropicResponse struct {
+	Model      string `json:"model"`
+	StopReason string `json:"stop_reason"`
+	Content    []struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		Thinking string `json:"thinking"`
+	} `json:"content"`
+	Usage Usage `json:"usage"`
+}
+
+func (p *AnthropicProvider) Send(ctx context.Context, req Request) (*Response, error) {
+	data, status, err := postJSON(ctx, p.http, p.baseURL+"/v1/messages", p.body(req), map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, statusError(config.ProviderAnthropic, status, data)
+	}
+
+	var apiResp anthropicResponse
+	if err := json.Unmarshal(data, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	resp := &Response{Model: apiResp.Model, StopReason: apiResp.StopReason, Usage: apiResp.Usage}
+	var text, thinking strings.Builder
+	for _, block := range apiResp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "thinking":
+			thinking.WriteString(block.Thinking)
+		}
+	}
+	resp.Text = text.String()
+	resp.Thinking = thinking.String()
+	return resp, nil
+}
+
+// body translates req, adding cache breakpoints when caching is enabled.
+func (p *AnthropicProvider) body(req Request) anthropicRequest {
+	breakpoints := 0
+	mark := func(cache bool) *cacheControl {
+		if !cache || !p.caching.Enabled || breakpoints == maxCacheBreakpoints {
+			return nil
+		}
+		breakpoints++
+		return &cacheControl{Type: "ephemeral", TTL: p.caching.TTL}
+	}
+
+	body := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Thinking:    req.Thinking,
+	}
+	// The system prompt is a fixed file per agent, so it is always cacheable
+	if req.System != "" {
+		body.System = []anthropicBlock{{Type: "text", Text: req.System, CacheControl: mark(true)}}
+	}
+	for _, m := range req.Messages {
+		msg := anthropicMessage{Role: m.Role}
+		if len(m.Blocks) == 0 {
+			msg.Content = []anthropicBlock{{Type: "text", Text: m.Content}}
+		}
+		for _, b := range m.Blocks {
+			msg.Content = append(msg.Content, anthropicBlock{Type: "text", Text: b.Text, CacheControl: mark(b.Cache)})
+		}
+		body.Messages = append(body.Messages, msg)
+	}
+	return body
+}
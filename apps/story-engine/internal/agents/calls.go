@@ -0,0 +1,51 @@
+package agents
+
+import (
+	"context"
+	"sync"
+)
+
+// Call is one completed or failed request made through a Client.
+type Call struct {
+	Agent string
+	// Model is the model used, after any budget downgrade.
+	Model string
+	// Attempts counts the requests sent, so retries are Attempts-1.
+	Attempts int
+	Usage    Usage
+	Err      error
+}
+
+// CallLog collects the calls made with a context, so a pipeline stage can
+// report the calls, retries and models it used.
+type CallLog struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls returns the calls recorded so far.
+func (l *CallLog) Calls() []Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Call(nil), l.calls...)
+}
+
+func (l *CallLog) add(c Call) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, c)
+}
+
+type callLogKey struct{}
+
+// WithCallLog returns a context whose calls are recorded in log.
+func WithCallLog(ctx context.Context, log *CallLog) context.Context {
+	return context.WithValue(ctx, callLogKey{}, log)
+}
+
+// recordCall adds c to the context's call log, if it has one.
+func recordCall(ctx context.Context, c Call) {
+	if log, ok := ctx.Value(callLogKey{}).(*CallLog); ok {
+		log.add(c)
+	}
+}
@@ -0,0 +1,277 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+const canonCheckerAgent = "canon_checker"
+
+// Violation sources.
+const (
+	SourceRules = "rules"
+	SourceLLM   = "llm"
+)
+
+// CanonRule is a single rule a chapter must respect, identified by its path
+// in the story bible.
+type CanonRule struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// CanonViolation is a sentence in a chapter that breaks a canon rule.
+type CanonViolation struct {
+	Rule        string `json:"rule"`
+	RuleText    string `json:"rule_text"`
+	Quote       string `json:"quote"`
+	Explanation string `json:"explanation"`
+	Source      string `json:"source"`
+}
+
+func (v CanonViolation) String() string {
+	return fmt.Sprintf("%s: %q (%s)", v.Rule, v.Quote, v.Explanation)
+}
+
+// CanonRules lists the story bible rules a chapter is checked against:
+// every universe rule and every never_do constraint.
+func CanonRules(bible *models.StoryBible) []CanonRule {
+	var rules []CanonRule
+
+	keys := make([]string, 0, len(bible.Universe.Rules))
+	for key := range bible.Universe.Rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		rules = append(rules, CanonRule{
+			ID:   "universe.rules." + key,
+			Text: fmt.Sprint(bible.Universe.Rules[key]),
+		})
+	}
+
+	for i, text := range bible.Constraints.NeverDo {
+		rules = append(rules, CanonRule{ID: fmt.Sprintf("constraints.never_do[%d]", i), Text: text})
+	}
+
+	return rules
+}
+
+// CanonChecker flags chapter sentences that break the story bible's
+// universe rules. A fast keyword pass catches explicit prohibitions and
+// anachronisms; the LLM pass catches the rest.
+type CanonChecker struct {
+	client *Client
+	cfg    *config.Config
+	agent  config.AgentConfig
+	prompt string
+}
+
+// NewCanonChecker creates a canon checker, loading its system prompt.
+func NewCanonChecker(client *Client, cfg *config.Config) (*CanonChecker, error) {
+	agentCfg, prompt, err := loadAgent(cfg, canonCheckerAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &CanonChecker{client: client, cfg: cfg, agent: agentCfg, prompt: prompt}, nil
+}
+
+// Check returns every canon violation found in the chapter.
+func (c *CanonChecker) Check(ctx context.Context, bible *models.StoryBible, chapter *models.Chapter) ([]CanonViolation, error) {
+	rules := CanonRules(bible)
+	violations := CheckCanonRules(rules, c.cfg.Pipeline.Validation.Anachronisms, chapter.Text)
+
+	llm, err := c.checkLLM(ctx, rules, chapter.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	return dedupeViolations(append(violations, llm...)), nil
+}
+
+type canonCheckResponse struct {
+	Violations []struct {
+		Rule        string `json:"rule"`
+		Quote       string `json:"quote"`
+		Explanation string `json:"explanation"`
+	} `json:"violations"`
+}
+
+// checkLLM asks the model for violations. Flags citing a rule that does not
+// exist or quoting text that is not in the chapter are dropped, since they
+// cannot be acted on.
+func (c *CanonChecker) checkLLM(ctx context.Context, rules []CanonRule, text string) ([]CanonViolation, error) {
+	req := newRequest(c.cfg, canonCheckerAgent, c.agent)
+	req.System = c.prompt
+	var input Context
+	// The rules come from the story bible, so they are cached across
+	// rewrites of the same chapter
+	input.AddStable("Rules", map[string]any{"rules": rules})
+	input.Add("Chapter", text)
+	req.Messages = []Message{input.Message()}
+
+	resp, err := c.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var out canonCheckResponse
+	if err := decodeJSON(resp.Text, &out); err != nil {
+		return nil, fmt.Errorf("canon checker: %w", err)
+	}
+
+	byID := make(map[string]string, len(rules))
+	for _, r := range rules {
+		byID[r.ID] = r.Text
+	}
+
+	logger := logging.FromContext(ctx).With("agent", canonCheckerAgent)
+	var violations []CanonViolation
+	for _, v := range out.Violations {
+		ruleText, ok := byID[v.Rule]
+		if !ok {
+			logger.Warn("canon checker cited unknown rule, ignoring", "rule", v.Rule)
+			continue
+		}
+		sentence, ok := sentenceContaining(text, v.Quote)
+		if !ok {
+			logger.Warn("canon checker quote not found in chapter, ignoring", "quote", v.Quote)
+			continue
+		}
+		violations = append(violations, CanonViolation{
+			Rule:        v.Rule,
+			RuleText:    ruleText,
+			Quote:       sentence,
+			Explanation: v.Explanation,
+			Source:      SourceLLM,
+		})
+	}
+	return violations, nil
+}
+
+// prohibition matches "no <thing>" phrases in rule text, such as
+// "no printing press".
+var prohibition = regexp.MustCompile(`(?i)\bno ([a-z][a-z -]*?)\s*(?:[.,;:]|$)`)
+
+// CheckCanonRules is the deterministic pass: it flags sentences that mention
+// something a rule explicitly says does not exist, and the anachronisms
+// (pipeline.validation.anachronisms) of every era the technology level names.
+func CheckCanonRules(rules []CanonRule, anachronisms map[string][]string, text string) []CanonViolation {
+	var violations []CanonViolation
+
+	for _, rule := range rules {
+		var terms []string
+		for _, m := range prohibition.FindAllStringSubmatch(rule.Text, -1) {
+			terms = append(terms, strings.TrimSpace(m[1]))
+		}
+		if strings.HasSuffix(rule.ID, "technology_level") {
+			terms = append(terms, eraTerms(anachronisms, rule.Text)...)
+		}
+
+		for _, term := range terms {
+			re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `(?:e?s)?\b`)
+			for _, sentence := range sentences(text) {
+				if re.MatchString(sentence) {
+					violations = append(violations, CanonViolation{
+						Rule:        rule.ID,
+						RuleText:    rule.Text,
+						Quote:       sentence,
+						Explanation: fmt.Sprintf("mentions %q, which this world does not have", term),
+						Source:      SourceRules,
+					})
+				}
+			}
+		}
+	}
+
+	return violations
+}
+
+// eraTerms returns the anachronisms of every era named in text, in era
+// order and without duplicates.
+func eraTerms(anachronisms map[string][]string, text string) []string {
+	lower := strings.ToLower(text)
+	eras := make([]string, 0, len(anachronisms))
+	for era := range anachronisms {
+		if strings.Contains(lower, strings.ToLower(era)) {
+			eras = append(eras, era)
+		}
+	}
+	sort.Strings(eras)
+
+	var terms []string
+	seen := make(map[string]bool)
+	for _, era := range eras {
+		for _, term := range anachronisms[era] {
+			if key := strings.ToLower(term); !seen[key] {
+				seen[key] = true
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// sentenceEnd matches the end of a sentence, including a closing quote.
+var sentenceEnd = regexp.MustCompile(`[.!?]+["'”’]?(\s+|$)`)
+
+// sentences splits prose into trimmed sentences.
+func sentences(text string) []string {
+	var out []string
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		if s := strings.TrimSpace(text[start:loc[1]]); s != "" {
+			out = append(out, s)
+		}
+		start = loc[1]
+	}
+	if s := strings.TrimSpace(text[start:]); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// sentenceContaining returns the full sentence of text that contains quote,
+// ignoring case and whitespace differences.
+func sentenceContaining(text, quote string) (string, bool) {
+	q := normalizeSpace(quote)
+	if q == "" {
+		return "", false
+	}
+	for _, s := range sentences(text) {
+		if strings.Contains(normalizeSpace(s), q) {
+			return s, true
+		}
+	}
+	// The quote may span several sentences
+	if strings.Contains(normalizeSpace(text), q) {
+		return strings.TrimSpace(quote), true
+	}
+	return "", false
+}
+
+func normalizeSpace(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// dedupeViolations keeps the first flag for each rule and sentence.
+func dedupeViolations(vs []CanonViolation) []CanonViolation {
+	seen := make(map[string]bool, len(vs))
+	var out []CanonViolation
+	for _, v := range vs {
+		key := v.Rule + "|" + normalizeSpace(v.Quote)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, v)
+	}
+	return out
+}
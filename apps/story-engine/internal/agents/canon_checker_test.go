@@ -0,0 +1,119 @@
+package agents
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheckCanonRules(t *testing.T) {
+	anachronisms := map[string][]string{
+		"pre-industrial": {"steam engine", "telegraph"},
+		"medieval":       {"pistol", "telegraph"},
+	}
+
+	tests := []struct {
+		name string
+		rule CanonRule
+		text string
+		// want is the quote of each violation, with its term
+		want [][2]string
+	}{
+		{
+			name: "no X",
+			rule: CanonRule{ID: "universe.rules.technology_level", Text: "Sailing ships, forged steel, no printing press. Maps are rare."},
+			text: "The printing press hissed. Maps were rare.",
+			want: [][2]string{{"The printing press hissed.", "printing press"}},
+		},
+		{
+			name: "plural and case",
+			rule: CanonRule{ID: "universe.rules.communication", Text: "No telescope."},
+			text: "Two Telescopes glinted on the wall. Mira squinted.",
+			want: [][2]string{{"Two Telescopes glinted on the wall.", "telescope"}},
+		},
+		{
+			name: "several prohibitions",
+			rule: CanonRule{ID: "universe.rules.magic_system", Text: "No curses, no resurrection; the dead stay dead"},
+			text: "The witch laid curses on the road. Resurrection was a rumour. Nobody came back.",
+			want: [][2]string{
+				{"The witch laid curses on the road.", "curses"},
+				{"Resurrection was a rumour.", "resurrection"},
+			},
+		},
+		{
+			name: "whole words only",
+			rule: CanonRule{ID: "universe.rules.technology_level", Text: "No gun."},
+			text: "She gripped the gunwale. The gun-metal sky darkened.",
+			want: [][2]string{{"The gun-metal sky darkened.", "gun"}},
+		},
+		{
+			name: "no inside a word is not a prohibition",
+			rule: CanonRule{ID: "universe.rules.religion", Text: "Casino halls, piano music."},
+			text: "The piano music drifted from the casino halls.",
+		},
+		{
+			name: "prohibition without punctuation is not a term",
+			rule: CanonRule{ID: "universe.rules.magic_system", Text: "No fireballs—more like places that bend probability."},
+			text: "A fireball lit the sky.",
+		},
+		{
+			name: "anachronisms of the era",
+			rule: CanonRule{ID: "universe.rules.technology_level", Text: "Pre-industrial. Sailing ships, forged steel."},
+			text: "A steam engine coughed. He drew a pistol.",
+			want: [][2]string{{"A steam engine coughed.", "steam engine"}},
+		},
+		{
+			name: "anachronisms of every era named",
+			rule: CanonRule{ID: "universe.rules.technology_level", Text: "Late medieval, pre-industrial."},
+			text: "A steam engine coughed. He drew a pistol. The telegraph clicked.",
+			want: [][2]string{
+				{"He drew a pistol.", "pistol"},
+				{"The telegraph clicked.", "telegraph"},
+				{"A steam engine coughed.", "steam engine"},
+			},
+		},
+		{
+			name: "era with no list",
+			rule: CanonRule{ID: "universe.rules.technology_level", Text: "Early industrial: railways and mills."},
+			text: "A steam engine coughed.",
+		},
+		{
+			name: "era named outside the technology level",
+			rule: CanonRule{ID: "universe.rules.government", Text: "Medieval lords and merchant councils."},
+			text: "He drew a pistol.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := CheckCanonRules([]CanonRule{tt.rule}, anachronisms, tt.text)
+			var got [][2]string
+			for _, v := range violations {
+				if v.Rule != tt.rule.ID || v.RuleText != tt.rule.Text || v.Source != SourceRules {
+					t.Errorf("violation = %+v, want rule %s from the rules pass", v, tt.rule.ID)
+				}
+				got = append(got, [2]string{v.Quote, v.Explanation})
+			}
+			var want [][2]string
+			for _, w := range tt.want {
+				want = append(want, [2]string{w[0], `mentions "` + w[1] + `", which this world does not have`})
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("violations = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestEraTerms(t *testing.T) {
+	anachronisms := map[string][]string{
+		"pre-industrial": {"steam engine", "Telegraph"},
+		"medieval":       {"pistol", "telegraph"},
+	}
+	got := eraTerms(anachronisms, "Late MEDIEVAL, pre-industrial")
+	if want := []string{"pistol", "telegraph", "steam engine"}; !slices.Equal(got, want) {
+		t.Errorf("eraTerms = %q, want %q", got, want)
+	}
+	if got := eraTerms(nil, "medieval"); got != nil {
+		t.Errorf("eraTerms with no lists = %q, want none", got)
+	}
+}
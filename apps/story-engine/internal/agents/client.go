@@ -0,0 +1,279 @@
+// Package agents implements the AI agents of the story pipeline and the
+// LLM client they share. The client sends each request to the provider
+// named by its model: Anthropic, an OpenAI-compatible server or Ollama.
+
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+)
+
+const (
+	// maxResponseBytes caps how much of an API response is read.
+	maxResponseBytes = 10 << 20
+
+	// defaultTimeout bounds a single HTTP call to a provider.
+	defaultTimeout = 5 * time.Minute
+)
+
+// Message is a single conversation turn. Content is plain text; Blocks,
+// when set, replace it with parts that can be cached separately (see
+// Context).
+type Message struct {
+	Role    string  `json:"role"`
+	Content string  `json:"content"`
+	Blocks  []Block `json:"-"`
+}
+
+// Block is part of a message.
+type Block struct {
+	Text string
+	// Cache ends a stable prefix: providers that support prompt caching
+	// cache everything up to and including this block.
+	Cache bool
+}
+
+// Text returns the message as plain text, its blocks joined by blank lines.
+func (m Message) Text() string {
+	if len(m.Blocks) == 0 {
+		return m.Content
+	}
+	parts := make([]string, len(m.Blocks))
+	for i, b := range m.Blocks {
+		parts[i] = b.Text
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Request is a call to an LLM. Each provider translates it to its own API.
+type Request struct {
+	// Agent is the name of the calling agent, used for logging and accounting.
+	Agent string
+	// Model is a model reference, e.g. "ollama:llama3.1:8b"; see
+	// config.ParseModel. Providers receive the bare model name.
+	Model       string
+	System      string
+	Messages    []Message
+	MaxTokens   int
+	Temperature *float64
+	Thinking    *Thinking
+}
+
+// Thinking enables extended thinking with a token budget.
+type Thinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+// Usage is the token accounting returned with every response.
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// Response is the decoded result of a call.
+type Response struct {
+	Model      string
+	StopReason string
+	Text       string
+	Thinking   string
+	Usage      Usage
+}
+
+// StatusError is a non-success response from a provider's API.
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("%s api returned %d: %s", e.Provider, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s api returned %d %s: %s", e.Provider, e.StatusCode, e.Type, e.Message)
+}
+
+// retryable reports whether the request may succeed if tried again.
+func (e *StatusError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// UsageRecorder is told the token usage of every successful call.
+type UsageRecorder interface {
+	RecordUsage(agent, model string, usage Usage, thinkingTokens int)
+}
+
+// Estimate describes a call before it is made, for budget checks.
+type Estimate struct {
+	Agent           string
+	Model           string
+	InputTokens     int
+	MaxOutputTokens int
+}
+
+// BudgetGuard approves each call before it is sent. It returns the model to
+// use, which may be cheaper than the one requested, or an error to stop.
+type BudgetGuard interface {
+	Preflight(e Estimate) (string, error)
+}
+
+// Client sends requests to the configured providers with retries.
+type Client struct {
+	providers map[string]LLMProvider
+	retry     config.RetryConfig
+	recorder  UsageRecorder
+	budget    BudgetGuard
+
+	includeThinking bool
+}
+
+// NewClient creates a client with a provider for Anthropic, when its API key
+// is set, and for each backend configured under providers. Retries follow
+// anthropic.retry for every provider.
+func NewClient(cfg *config.Config) *Client {
+	c := &Client{providers: make(map[string]LLMProvider), retry: cfg.Anthropic.Retry}
+	if cfg.Anthropic.APIKey != "" {
+		c.SetProvider(config.ProviderAnthropic, NewAnthropicProvider(cfg.Anthropic))
+	}
+	if p := cfg.Providers.OpenAI; p.BaseURL != "" {
+		c.SetProvider(config.ProviderOpenAI, NewOpenAIProvider(p))
+	}
+	if p := cfg.Providers.Ollama; p.BaseURL != "" {
+		c.SetProvider(config.ProviderOllama, NewOllamaProvider(p))
+	}
+	return c
+}
+
+// SetProvider sets the backend for a provider name, replacing any other.
+func (c *Client) SetProvider(name string, p LLMProvider) {
+	c.providers[name] = p
+}
+
+// SetRecorder sets where token usage is reported, e.g. the cost ledger.
+func (c *Client) SetRecorder(r UsageRecorder) {
+	c.recorder = r
+}
+
+// SetIncludeThinking sets whether extended thinking output is logged
+// (logging.include_thinking).
+func (c *Client) SetIncludeThinking(include bool) {
+	c.includeThinking = include
+}
+
+// SetBudget sets the guard that approves each call before it is sent.
+func (c *Client) SetBudget(b BudgetGuard) {
+	c.budget = b
+}
+
+// Complete sends a request to its model's provider, retrying rate limits,
+// server errors and network failures with exponential backoff as configured
+// in anthropic.retry. Requests that were sent are recorded in the
+// context's CallLog, if any.
+func (c *Client) Complete(ctx context.Context, req Request) (*Response, error) {
+	if c.budget != nil {
+		model, err := c.budget.Preflight(Estimate{
+			Agent:           req.Agent,
+			Model:           req.Model,
+			InputTokens:     estimateRequestTokens(req),
+			MaxOutputTokens: req.MaxTokens,
+		})
+		if err != nil {
+			return nil, err
+		}
+		req.Model = model
+	}
+
+	name, model, err := config.ParseModel(req.Model)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", req.Agent, err)
+	}
+	provider, ok := c.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%s request failed: provider %s is not configured", req.Agent, name)
+	}
+	sent := req
+	sent.Model = model
+
+	logger := logging.FromContext(ctx).With("agent", req.Agent, "model", req.Model)
+	var lastErr error
+	attempts := 0
+	for attempt := 1; attempt <= max(c.retry.MaxAttempts, 1); attempt++ {
+		logger.Debug("sending request", "attempt", attempt)
+		if attempt > 1 {
+			select {
+			case <-time.After(c.backoff(attempt - 1)):
+			case <-ctx.Done():
+				recordCall(ctx, Call{Agent: req.Agent, Model: req.Model, Attempts: attempts, Err: ctx.Err()})
+				return nil, ctx.Err()
+			}
+		}
+
+		attempts = attempt
+		resp, err := provider.Send(ctx, sent)
+		if err == nil {
+			logger.Info("request completed",
+				"input_tokens", resp.Usage.InputTokens,
+				"output_tokens", resp.Usage.OutputTokens,
+				"cache_read_tokens", resp.Usage.CacheReadInputTokens,
+				"cache_write_tokens", resp.Usage.CacheCreationInputTokens,
+				"stop_reason", resp.StopReason)
+			if c.includeThinking && resp.Thinking != "" {
+				logger.Info("thinking", "text", resp.Thinking)
+			}
+			if c.recorder != nil {
+				c.recorder.RecordUsage(req.Agent, req.Model, resp.Usage, estimateTokens(resp.Thinking))
+			}
+			recordCall(ctx, Call{Agent: req.Agent, Model: req.Model, Attempts: attempts, Usage: resp.Usage})
+			return resp, nil
+		}
+		lastErr = err
+		logger.Warn("request failed", "attempt", attempt, "error", err)
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	recordCall(ctx, Call{Agent: req.Agent, Model: req.Model, Attempts: attempts, Err: lastErr})
+	return nil, fmt.Errorf("%s request failed: %w", req.Agent, lastErr)
+}
+
+// backoff returns the delay before retry n (1-based).
+func (c *Client) backoff(n int) time.Duration {
+	delay := float64(c.retry.InitialDelayMs) * math.Pow(c.retry.Multiplier, float64(n-1))
+	delay = math.Min(delay, float64(c.retry.MaxDelayMs))
+	return time.Duration(delay) * time.Millisecond
+}
+
+// estimateTokens approximates the token count of text at four characters
+// per token. The API reports thinking as part of output tokens only.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// estimateRequestTokens approximates the prompt tokens of a request.
+func estimateRequestTokens(req Request) int {
+	n := estimateTokens(req.System)
+	for _, m := range req.Messages {
+		n += estimateTokens(m.Text())
+	}
+	return n
+}
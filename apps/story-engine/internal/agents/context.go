@@ -0,0 +1,42 @@
+package agents
+
+// Context builds the user message of an agent request from titled
+// sections. Stable sections, such as the story bible, world map and canon
+// rules, are the same on every call; they are placed first, in the order
+// added, and the last of them ends the cached prefix. The other sections
+// follow, so each call only pays full price for what changed.
+type Context struct {
+	stable   []string
+	volatile []string
+}
+
+// AddStable adds a section that doesn't change between calls. v is
+// rendered as-is if it is a string, or as indented JSON.
+func (c *Context) AddStable(title string, v any) {
+	c.stable = append(c.stable, section(title, v))
+}
+
+// Add adds a section specific to this call, such as the chapter.
+func (c *Context) Add(title string, v any) {
+	c.volatile = append(c.volatile, section(title, v))
+}
+
+// Message returns the sections as a user message.
+func (c *Context) Message() Message {
+	msg := Message{Role: "user"}
+	for i, s := range c.stable {
+		msg.Blocks = append(msg.Blocks, Block{Text: s, Cache: i == len(c.stable)-1})
+	}
+	for _, s := range c.volatile {
+		msg.Blocks = append(msg.Blocks, Block{Text: s})
+	}
+	return msg
+}
+
+func section(title string, v any) string {
+	body, ok := v.(string)
+	if !ok {
+		body = encodeJSON(v)
+	}
+	return "## " + title + "\n\n" + body
+}
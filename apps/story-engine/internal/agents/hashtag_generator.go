@@ -0,0 +1,76 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/hashtags"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+const hashtagGeneratorAgent = "hashtag_generator"
+
+// HashtagGenerator proposes categorized hashtag candidates for a chapter.
+// The final set is chosen by hashtags.Select.
+type HashtagGenerator struct {
+	client *Client
+	cfg    *config.Config
+	agent  config.AgentConfig
+	prompt string
+}
+
+// NewHashtagGenerator creates a hashtag generator, loading its system prompt.
+func NewHashtagGenerator(client *Client, cfg *config.Config) (*HashtagGenerator, error) {
+	agentCfg, prompt, err := loadAgent(cfg, hashtagGeneratorAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &HashtagGenerator{client: client, cfg: cfg, agent: agentCfg, prompt: prompt}, nil
+}
+
+// Categories returns the enabled hashtag categories, in the order they take
+// turns during selection.
+func (g *HashtagGenerator) Categories() []string {
+	var categories []string
+	if g.agent.Bool("include_story_tags") {
+		categories = append(categories, hashtags.CategoryStory)
+	}
+	if g.agent.Bool("include_genre_tags") {
+		categories = append(categories, hashtags.CategoryGenre)
+	}
+	if g.agent.Bool("include_general_tags") {
+		categories = append(categories, hashtags.CategoryGeneral)
+	}
+	return categories
+}
+
+// Candidates asks the model for hashtag candidates in the enabled categories.
+func (g *HashtagGenerator) Candidates(ctx context.Context, bible *models.StoryBible, chapter *models.Chapter) (*hashtags.Candidates, error) {
+	var input Context
+	input.AddStable("Story", map[string]any{
+		"title": bible.Meta.StoryTitle,
+		"genre": bible.Meta.Genre,
+		"tone":  bible.Meta.Tone,
+	})
+	input.Add("Chapter", map[string]any{
+		"title": chapter.Title,
+		"text":  chapter.Text,
+	})
+	input.Add("Categories", g.Categories())
+
+	req := newRequest(g.cfg, hashtagGeneratorAgent, g.agent)
+	req.System = g.prompt
+	req.Messages = []Message{input.Message()}
+
+	resp, err := g.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var c hashtags.Candidates
+	if err := decodeJSON(resp.Text, &c); err != nil {
+		return nil, fmt.Errorf("hashtag generator: %w", err)
+	}
+	return &c, nil
+}
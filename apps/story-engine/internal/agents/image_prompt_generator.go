@@ -0,0 +1,158 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+const imagePromptAgent = "image_prompt_generator"
+
+// Shots are the image compositions, in the order they are used. A chapter
+// with images_per_chapter N gets the first N.
+var Shots = []string{"establishing", "character", "focal_object", "negative_space"}
+
+// ImagePrompt is the prompt for one chapter image.
+type ImagePrompt struct {
+	Shot string `json:"shot"`
+	// Prompt is sent to the image model, style anchors included.
+	Prompt string `json:"prompt"`
+	// Rationale explains the choice of image for the daily email.
+	Rationale string   `json:"rationale"`
+	Entities  []string `json:"entities,omitempty"`
+}
+
+// EntityVisual is what the image prompt writer is told about an entity's
+// appearance.
+type EntityVisual struct {
+	ID     string         `json:"id"`
+	Name   string         `json:"name"`
+	Type   string         `json:"type"`
+	Visual *models.Visual `json:"visual"`
+}
+
+// ImagePromptGenerator writes image prompts from a chapter.
+type ImagePromptGenerator struct {
+	client *Client
+	cfg    *config.Config
+	agent  config.AgentConfig
+	prompt string
+}
+
+// NewImagePromptGenerator creates an image prompt generator, loading its
+// system prompt.
+func NewImagePromptGenerator(client *Client, cfg *config.Config) (*ImagePromptGenerator, error) {
+	agentCfg, prompt, err := loadAgent(cfg, imagePromptAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &ImagePromptGenerator{client: client, cfg: cfg, agent: agentCfg, prompt: prompt}, nil
+}
+
+type imagePromptResponse struct {
+	Prompts []ImagePrompt `json:"prompts"`
+}
+
+// Generate returns count distinct prompts for the chapter, normally
+// images_per_chapter, each ending with the style anchors and no longer than
+// max_prompt_length. A count of 0 or less returns no prompts without
+// calling the model; one above len(Shots) gets a prompt per shot.
+func (g *ImagePromptGenerator) Generate(ctx context.Context, chapter *models.Chapter, visuals []EntityVisual, count int) ([]ImagePrompt, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+	shots := Shots[:min(count, len(Shots))]
+	anchors := g.cfg.ImageGeneration.StyleAnchors
+	maxLength := g.agent.Int("max_prompt_length")
+
+	input := map[string]any{
+		"chapter": map[string]any{
+			"title":          chapter.Title,
+			"text":           chapter.Text,
+			"emotional_beat": chapter.EmotionalBeatAchieved,
+		},
+		"shots": shots,
+	}
+	if g.agent.Bool("include_character_refs") && len(visuals) > 0 {
+		input["visuals"] = visuals
+	}
+	if maxLength > 0 {
+		input["max_prompt_length"] = sceneBudget(anchors, maxLength)
+	}
+
+	req := newRequest(g.cfg, imagePromptAgent, g.agent)
+	req.System = g.prompt
+	req.Messages = []Message{{Role: "user", Content: encodeJSON(input)}}
+
+	resp, err := g.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var out imagePromptResponse
+	if err := decodeJSON(resp.Text, &out); err != nil {
+		return nil, fmt.Errorf("image prompt generator: %w", err)
+	}
+
+	var prompts []ImagePrompt
+	seen := make(map[string]bool)
+	for _, p := range out.Prompts {
+		scene := strings.TrimSpace(p.Prompt)
+		key := normalizeSpace(scene)
+		if scene == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		p.Prompt = FitPrompt(scene, anchors, maxLength)
+		prompts = append(prompts, p)
+		if len(prompts) == len(shots) {
+			break
+		}
+	}
+
+	if len(prompts) < len(shots) {
+		return nil, fmt.Errorf("image prompt generator returned %d distinct prompts, want %d", len(prompts), len(shots))
+	}
+	return prompts, nil
+}
+
+// sceneBudget is how many characters of a prompt are left for the scene
+// once the style anchors are appended.
+func sceneBudget(anchors []string, maxLength int) int {
+	if len(anchors) == 0 {
+		return maxLength
+	}
+	return maxLength - utf8.RuneCountInString(", "+strings.Join(anchors, ", "))
+}
+
+// FitPrompt appends the style anchors to a scene description, shortening the
+// scene so the whole prompt fits in maxLength characters. The scene is cut
+// at the last comma or space that fits; the anchors are never cut. A
+// maxLength of 0 means no limit.
+func FitPrompt(scene string, anchors []string, maxLength int) string {
+	suffix := ""
+	if len(anchors) > 0 {
+		suffix = ", " + strings.Join(anchors, ", ")
+	}
+	scene = strings.TrimRight(strings.TrimSpace(scene), ".,; ")
+	if maxLength <= 0 {
+		return scene + suffix
+	}
+
+	budget := sceneBudget(anchors, maxLength)
+	if utf8.RuneCountInString(scene) > budget {
+		runes := []rune(scene)
+		cut := string(runes[:max(budget, 0)])
+		if i := strings.LastIndex(cut, ","); i > len(cut)/2 {
+			cut = cut[:i]
+		} else if i := strings.LastIndex(cut, " "); i > 0 {
+			cut = cut[:i]
+		}
+		scene = strings.TrimRight(cut, ".,; ")
+	}
+	return scene + suffix
+}
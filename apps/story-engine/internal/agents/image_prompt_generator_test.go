@@ -0,0 +1,191 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+func TestSceneBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		anchors   []string
+		maxLength int
+		want      int
+	}{
+		{"no anchors", nil, 100, 100},
+		{"one anchor", []string{"oil painting"}, 100, 86},
+		{"several anchors", []string{"oil painting", "muted palette"}, 100, 71},
+		{"multibyte anchors count as characters", []string{"clair-obscur é"}, 100, 84},
+		{"anchors longer than the limit", []string{"oil painting"}, 10, -4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sceneBudget(tt.anchors, tt.maxLength); got != tt.want {
+				t.Errorf("sceneBudget = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitPrompt(t *testing.T) {
+	anchors := []string{"oil painting", "muted palette"}
+	const suffix = ", oil painting, muted palette" // 29 characters
+
+	tests := []struct {
+		name      string
+		scene     string
+		anchors   []string
+		maxLength int
+		want      string
+	}{
+		{
+			name:    "no limit",
+			scene:   "  A fox at the ford.  ",
+			anchors: anchors,
+			want:    "A fox at the ford" + suffix,
+		},
+		{
+			name:  "no anchors",
+			scene: "A fox at the ford;",
+			want:  "A fox at the ford",
+		},
+		{
+			name:      "fits",
+			scene:     "A fox at the ford",
+			anchors:   anchors,
+			maxLength: 46,
+			want:      "A fox at the ford" + suffix,
+		},
+		{
+			name:      "cut at a comma in the second half",
+			scene:     "A fox at the ford, dusk light, rain",
+			anchors:   anchors,
+			maxLength: 29 + 32,
+			want:      "A fox at the ford, dusk light" + suffix,
+		},
+		{
+			name:      "cut at a space when the comma is early",
+			scene:     "A fox, crossing the cold ford at dusk",
+			anchors:   anchors,
+			maxLength: 29 + 30,
+			want:      "A fox, crossing the cold ford" + suffix,
+		},
+		{
+			name:      "multibyte scene",
+			scene:     "Café façade, ébène doors at night",
+			anchors:   nil,
+			maxLength: 22,
+			want:      "Café façade, ébène",
+		},
+		{
+			name:      "anchors are never cut",
+			scene:     "A fox at the ford",
+			anchors:   anchors,
+			maxLength: 20,
+			want:      suffix,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FitPrompt(tt.scene, tt.anchors, tt.maxLength)
+			if got != tt.want {
+				t.Errorf("FitPrompt = %q, want %q", got, tt.want)
+			}
+			if tt.maxLength > 0 && utf8.RuneCountInString(got) > max(tt.maxLength, utf8.RuneCountInString(suffix)) {
+				t.Errorf("FitPrompt is %d characters, want at most %d", utf8.RuneCountInString(got), tt.maxLength)
+			}
+		})
+	}
+}
+
+// promptsProvider answers with n distinct image prompts and records the
+// shots it was asked for.
+type promptsProvider struct {
+	n     int
+	calls int
+	shots []string
+}
+
+func (p *promptsProvider) Send(_ context.Context, req Request) (*Response, error) {
+	p.calls++
+	var input struct {
+		Shots []string `json:"shots"`
+	}
+	if err := json.Unmarshal([]byte(req.Messages[0].Content), &input); err != nil {
+		return nil, err
+	}
+	p.shots = input.Shots
+
+	var out imagePromptResponse
+	for i := range p.n {
+		out.Prompts = append(out.Prompts, ImagePrompt{Shot: Shots[i%len(Shots)], Prompt: fmt.Sprintf("scene %d", i)})
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Text: string(data)}, nil
+}
+
+func TestImagePromptGeneratorCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		replies   int
+		wantShots int
+		wantErr   bool
+	}{
+		{name: "zero", count: 0},
+		{name: "negative", count: -1},
+		{name: "one", count: 1, replies: 4, wantShots: 1},
+		{name: "every shot", count: 4, replies: 4, wantShots: 4},
+		{name: "more than the shots", count: 6, replies: 6, wantShots: 4},
+		{name: "too few replies", count: 3, replies: 2, wantShots: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &promptsProvider{n: tt.replies}
+			client := &Client{providers: map[string]LLMProvider{config.ProviderAnthropic: provider}, retry: config.RetryConfig{MaxAttempts: 1}}
+			cfg := &config.Config{}
+			cfg.ImageGeneration.StyleAnchors = []string{"oil painting"}
+			g := &ImagePromptGenerator{client: client, cfg: cfg, agent: config.AgentConfig{Model: "claude-haiku-4-5"}}
+
+			prompts, err := g.Generate(context.Background(), &models.Chapter{Title: "The Ford"}, nil, tt.count)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Generate = %v, want an error", prompts)
+				}
+			} else if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			if tt.wantShots == 0 {
+				if provider.calls != 0 || prompts != nil {
+					t.Errorf("Generate made %d calls and returned %v, want no call and no prompts", provider.calls, prompts)
+				}
+				return
+			}
+			if len(provider.shots) != tt.wantShots {
+				t.Errorf("asked for shots %v, want the first %d", provider.shots, tt.wantShots)
+			}
+			if !tt.wantErr && len(prompts) != tt.wantShots {
+				t.Errorf("got %d prompts, want %d", len(prompts), tt.wantShots)
+			}
+			for _, p := range prompts {
+				if !strings.HasSuffix(p.Prompt, ", oil painting") {
+					t.Errorf("prompt %q does not end with the style anchors", p.Prompt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+// OllamaProvider calls a local Ollama server's chat API. Extended thinking
+// is not sent; thinking text returned by the model is kept as Thinking.
+type OllamaProvider struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewOllamaProvider creates a provider for the server at cfg.BaseURL.
+func NewOllamaProvider(cfg config.OllamaConfig) *OllamaProvider {
+	return &OllamaProvider{
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		http:    httpClient(cfg.TimeoutSeconds),
+	}
+}
+
+type ollamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []Message     `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  ollamaOptions `json:"options"`
+}
+
+type ollamaOptions struct {
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model   string `json:"model"`
+	Message struct {
+		Content  string `json:"content"`
+		Thinking string `json:"thinking"`
+	} `json:"message"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+}
+
+func (p *OllamaProvider) Send(ctx context.Context, req Request) (*Response, error) {
+	body := ollamaRequest{
+		Model:    req.Model,
+		Messages: chatMessages(req),
+		Options:  ollamaOptions{NumPredict: req.MaxTokens, Temperature: req.Temperature},
+	}
+
+	data, status, err := postJSON(ctx, p.http, p.baseURL+"/api/chat", body, nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, statusError(config.ProviderOllama, status, data)
+	}
+
+	var apiResp ollamaResponse
+	if err := json.Unmarshal(data, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &Response{
+		Model:      apiResp.Model,
+		StopReason: apiResp.DoneReason,
+		Text:       apiResp.Message.Content,
+		Thinking:   apiResp.Message.Thinking,
+		Usage: Usage{
+			InputTokens:  apiResp.PromptEvalCount,
+			OutputTokens: apiResp.EvalCount,
+		},
+	}, nil
+}
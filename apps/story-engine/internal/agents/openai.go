@@ -0,0 +1,91 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+// OpenAIProvider calls an OpenAI-compatible chat completions API, such as
+// OpenAI, OpenRouter, vLLM or a llama.cpp server. Extended thinking is not
+// sent; reasoning text returned by the server is kept as Thinking.
+type OpenAIProvider struct {
+	apiKey  string
+	baseURL string
+	http    *http.Client
+}
+
+// NewOpenAIProvider creates a provider for the server at cfg.BaseURL.
+func NewOpenAIProvider(cfg config.OpenAIConfig) *OpenAIProvider {
+	return &OpenAIProvider{
+		apiKey:  cfg.APIKey,
+		baseURL: strings.TrimRight(cfg.BaseURL, "/"),
+		http:    httpClient(cfg.TimeoutSeconds),
+	}
+}
+
+type openAIRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+func (p *OpenAIProvider) Send(ctx context.Context, req Request) (*Response, error) {
+	body := openAIRequest{
+		Model:       req.Model,
+		Messages:    chatMessages(req),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["authorization"] = "Bearer " + p.apiKey
+	}
+
+	data, status, err := postJSON(ctx, p.http, p.baseURL+"/chat/completions", body, headers)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, statusError(config.ProviderOpenAI, status, data)
+	}
+
+	var apiResp openAIResponse
+	if err := json.Unmarshal(data, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(apiResp.Choices) == 0 {
+		return nil, fmt.Errorf("response contains no choices")
+	}
+	choice := apiResp.Choices[0]
+	return &Response{
+		Model:      apiResp.Model,
+		StopReason: choice.FinishReason,
+		Text:       choice.Message.Content,
+		Thinking:   choice.Message.ReasoningContent,
+		Usage: Usage{
+			InputTokens:  apiResp.Usage.PromptTokens,
+			OutputTokens: apiResp.Usage.CompletionTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,57 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+const premiseDrafterAgent = "premise_drafter"
+
+// PremiseSeed is what the author has decided about a new story so far.
+type PremiseSeed struct {
+	StoryTitle string   `json:"story_title"`
+	Tagline    string   `json:"tagline"`
+	Genre      []string `json:"genre"`
+	Tone       []string `json:"tone"`
+	Universe   string   `json:"universe"`
+	Origin     string   `json:"origin"`
+}
+
+// Premise is the premise section of the story bible.
+type Premise struct {
+	Setup            string `json:"setup"`
+	IncitingIncident string `json:"inciting_incident"`
+	CentralQuestion  string `json:"central_question"`
+	Stakes           struct {
+		Personal string `json:"personal"`
+		World    string `json:"world"`
+	} `json:"stakes"`
+}
+
+// DraftPremise asks the premise drafter for a first premise for a new story.
+func DraftPremise(ctx context.Context, client *Client, cfg *config.Config, seed PremiseSeed) (*Premise, error) {
+	agentCfg, prompt, err := loadAgent(cfg, premiseDrafterAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	req := newRequest(cfg, premiseDrafterAgent, agentCfg)
+	req.System = prompt
+	req.Messages = []Message{{Role: "user", Content: encodeJSON(seed)}}
+
+	resp, err := client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Premise
+	if err := decodeJSON(resp.Text, &p); err != nil {
+		return nil, fmt.Errorf("premise drafter: %w", err)
+	}
+	if p.Setup == "" || p.CentralQuestion == "" {
+		return nil, fmt.Errorf("premise drafter returned an incomplete premise")
+	}
+	return &p, nil
+}
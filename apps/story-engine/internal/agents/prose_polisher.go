@@ -0,0 +1,59 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+const prosePolisherAgent = "prose_polisher"
+
+// ProsePolisher makes a targeted craft pass over a written chapter:
+// stronger verbs, varied rhythm, specific senses, emotions shown in the
+// body. The plot is left alone.
+type ProsePolisher struct {
+	client *Client
+	cfg    *config.Config
+	agent  config.AgentConfig
+	prompt string
+}
+
+// NewProsePolisher creates a prose polisher, loading its system prompt.
+func NewProsePolisher(client *Client, cfg *config.Config) (*ProsePolisher, error) {
+	agentCfg, prompt, err := loadAgent(cfg, prosePolisherAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &ProsePolisher{client: client, cfg: cfg, agent: agentCfg, prompt: prompt}, nil
+}
+
+// Polish returns the polished chapter.
+func (p *ProsePolisher) Polish(ctx context.Context, chapter *models.Chapter) (*models.Chapter, error) {
+	req := newRequest(p.cfg, prosePolisherAgent, p.agent)
+	req.System = p.prompt
+	req.Messages = []Message{{Role: "user", Content: "Polish this chapter:\n\n" + encodeJSON(chapter) +
+		"\n\nRespond with the polished chapter as a JSON object with the same fields."}}
+
+	resp, err := p.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var polished models.Chapter
+	if err := decodeJSON(resp.Text, &polished); err != nil {
+		return nil, fmt.Errorf("prose polisher: %w", err)
+	}
+	if polished.Text == "" {
+		return nil, fmt.Errorf("prose polisher returned an empty chapter")
+	}
+	if polished.ChapterNumber == 0 {
+		polished.ChapterNumber = chapter.ChapterNumber
+	}
+	// Never trust the model's own count
+	polished.CharacterCount = utf8.RuneCountInString(polished.Text)
+
+	return &polished, nil
+}
@@ -0,0 +1,113 @@
+package agents
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LLMProvider sends requests to one LLM backend. req.Model is the model
+// name without its provider prefix. A non-success response is returned as
+// a *StatusError so the client can tell which are worth retrying.
+type LLMProvider interface {
+	Send(ctx context.Context, req Request) (*Response, error)
+}
+
+// apiError is the error body of the Anthropic and OpenAI APIs.
+type apiError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// postJSON posts body as JSON to url with the given headers and returns the
+// response body and status code.
+func postJSON(ctx context.Context, client *http.Client, url string, body any, headers map[string]string) ([]byte, int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("content-type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	out, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response: %w", err)
+	}
+	return out, resp.StatusCode, nil
+}
+
+// statusError builds the error for a non-success response, reading the
+// message from an Anthropic or OpenAI style error body, or an Ollama
+// {"error": "..."}, and falling back to the start of the raw body.
+func statusError(provider string, status int, data []byte) *StatusError {
+	err := &StatusError{Provider: provider, StatusCode: status}
+	var api apiError
+	if json.Unmarshal(data, &api) == nil && api.Error.Message != "" {
+		err.Type = api.Error.Type
+		err.Message = api.Error.Message
+		return err
+	}
+	var plain struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &plain) == nil && plain.Error != "" {
+		err.Message = plain.Error
+		return err
+	}
+	err.Message = strings.TrimSpace(truncate(string(data), 200))
+	return err
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// httpClient returns a client with the configured timeout in seconds, or
+// the default when it is 0.
+func httpClient(timeoutSeconds int) *http.Client {
+	timeout := defaultTimeout
+	if timeoutSeconds > 0 {
+		timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// chatMessages prepends the system prompt as a system message and flattens
+// blocks to text, as the OpenAI and Ollama chat APIs expect.
+func chatMessages(req Request) []Message {
+	messages := make([]Message, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, Message{Role: "system", Content: req.System})
+	}
+	for _, m := range req.Messages {
+		messages = append(messages, Message{Role: m.Role, Content: m.Text()})
+	}
+	return messages
+}
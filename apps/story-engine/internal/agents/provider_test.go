@@ -0,0 +1,317 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+// replyServer answers every request with status and reply.
+func replyServer(t *testing.T, status int, reply string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testRequest() Request {
+	temperature := 0.3
+	return Request{
+		Agent:       "story_writer",
+		Model:       "test-model",
+		System:      "You write stories.",
+		Messages:    []Message{{Role: "user", Blocks: []Block{{Text: "context", Cache: true}, {Text: "write"}}}},
+		MaxTokens:   512,
+		Temperature: &temperature,
+	}
+}
+
+// wantChatMessages checks the system prompt is sent as the first message and
+// blocks are flattened to text.
+func wantChatMessages(t *testing.T, body map[string]any) {
+	t.Helper()
+	messages, _ := body["messages"].([]any)
+	if len(messages) != 2 {
+		t.Fatalf("messages = %v, want system and user", body["messages"])
+	}
+	system, _ := messages[0].(map[string]any)
+	if system["role"] != "system" || system["content"] != "You write stories." {
+		t.Errorf("messages[0] = %v, want the system prompt", system)
+	}
+	user, _ := messages[1].(map[string]any)
+	if user["role"] != "user" || user["content"] != "context\n\nwrite" {
+		t.Errorf("messages[1] = %v, want the blocks joined", user)
+	}
+}
+
+func TestOpenAIProviderSend(t *testing.T) {
+	var got map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s, want /v1/chat/completions", r.URL.Path)
+		}
+		auth = r.Header.Get("authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"model":"test-model-0613","choices":[{"message":{"content":"Once","reasoning_content":"hmm"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":34}}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider(config.OpenAIConfig{BaseURL: srv.URL + "/v1/", APIKey: "sk-openai"})
+	resp, err := p.Send(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if auth != "Bearer sk-openai" {
+		t.Errorf("authorization = %q, want the bearer key", auth)
+	}
+	if got["model"] != "test-model" || got["max_tokens"] != 512.0 || got["temperature"] != 0.3 {
+		t.Errorf("request = %v, want model, max_tokens and temperature", got)
+	}
+	wantChatMessages(t, got)
+
+	want := Response{Model: "test-model-0613", StopReason: "stop", Text: "Once", Thinking: "hmm", Usage: Usage{InputTokens: 12, OutputTokens: 34}}
+	if *resp != want {
+		t.Errorf("response = %+v, want %+v", *resp, want)
+	}
+}
+
+func TestOpenAIProviderOmitsUnset(t *testing.T) {
+	var got map[string]any
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider(config.OpenAIConfig{BaseURL: srv.URL})
+	if _, err := p.Send(context.Background(), Request{Model: "m", Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if auth != "" {
+		t.Errorf("authorization = %q, want none without an API key", auth)
+	}
+	for _, key := range []string{"temperature", "max_tokens"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("request has %s, want it omitted when unset", key)
+		}
+	}
+	if messages, _ := got["messages"].([]any); len(messages) != 1 {
+		t.Errorf("messages = %v, want no system message without a system prompt", got["messages"])
+	}
+}
+
+func TestOpenAIProviderNoChoices(t *testing.T) {
+	srv := replyServer(t, http.StatusOK, `{"choices":[]}`)
+	p := NewOpenAIProvider(config.OpenAIConfig{BaseURL: srv.URL})
+	if _, err := p.Send(context.Background(), testRequest()); err == nil || !strings.Contains(err.Error(), "no choices") {
+		t.Errorf("Send = %v, want a no choices error", err)
+	}
+}
+
+func TestOllamaProviderSend(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s, want /api/chat", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"model":"llama3.1:8b","message":{"content":"Once","thinking":"hmm"},"done_reason":"stop","prompt_eval_count":12,"eval_count":34}`))
+	}))
+	defer srv.Close()
+
+	p := NewOllamaProvider(config.OllamaConfig{BaseURL: srv.URL + "/"})
+	resp, err := p.Send(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if got["model"] != "test-model" || got["stream"] != false {
+		t.Errorf("request = %v, want the model and stream false", got)
+	}
+	options, _ := got["options"].(map[string]any)
+	if options["num_predict"] != 512.0 || options["temperature"] != 0.3 {
+		t.Errorf("options = %v, want num_predict and temperature", options)
+	}
+	wantChatMessages(t, got)
+
+	want := Response{Model: "llama3.1:8b", StopReason: "stop", Text: "Once", Thinking: "hmm", Usage: Usage{InputTokens: 12, OutputTokens: 34}}
+	if *resp != want {
+		t.Errorf("response = %+v, want %+v", *resp, want)
+	}
+}
+
+func TestProviderStatusErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantType  string
+		wantMsg   string
+		retryable bool
+	}{
+		{"openai style", http.StatusBadRequest, `{"error":{"type":"invalid_request_error","message":"bad model"}}`, "invalid_request_error", "bad model", false},
+		{"ollama style", http.StatusNotFound, `{"error":"model not found"}`, "", "model not found", false},
+		{"raw body", http.StatusBadGateway, "  upstream down\n", "", "upstream down", true},
+		{"rate limited", http.StatusTooManyRequests, `{"error":{"type":"rate_limit_error","message":"slow down"}}`, "rate_limit_error", "slow down", true},
+		{"server error", http.StatusInternalServerError, `{"error":"oom"}`, "", "oom", true},
+		{"unauthorized", http.StatusUnauthorized, `{"error":{"message":"bad key"}}`, "", "bad key", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := replyServer(t, tt.status, tt.body)
+			providers := map[string]LLMProvider{
+				config.ProviderOpenAI: NewOpenAIProvider(config.OpenAIConfig{BaseURL: srv.URL}),
+				config.ProviderOllama: NewOllamaProvider(config.OllamaConfig{BaseURL: srv.URL}),
+			}
+			for name, p := range providers {
+				_, err := p.Send(context.Background(), testRequest())
+				var statusErr *StatusError
+				if !errors.As(err, &statusErr) {
+					t.Fatalf("%s: Send = %v, want a *StatusError", name, err)
+				}
+				want := StatusError{Provider: name, StatusCode: tt.status, Type: tt.wantType, Message: tt.wantMsg}
+				if *statusErr != want {
+					t.Errorf("%s: error = %+v, want %+v", name, *statusErr, want)
+				}
+				if statusErr.retryable() != tt.retryable {
+					t.Errorf("%s: retryable = %v, want %v", name, statusErr.retryable(), tt.retryable)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusErrorTruncatesBody(t *testing.T) {
+	body := strings.Repeat("é", 150) // 300 bytes
+	err := statusError(config.ProviderOpenAI, http.StatusBadGateway, []byte(body))
+	if len(err.Message) > 200 || !strings.HasPrefix(body, err.Message) {
+		t.Errorf("message is %d bytes, want at most 200 on a character boundary", len(err.Message))
+	}
+}
+
+// stubProvider records the model of each request it is sent.
+type stubProvider struct {
+	models []string
+}
+
+func (s *stubProvider) Send(_ context.Context, req Request) (*Response, error) {
+	s.models = append(s.models, req.Model)
+	return &Response{Text: "ok"}, nil
+}
+
+func TestClientCompleteRouting(t *testing.T) {
+	tests := []struct {
+		model        string
+		wantProvider string
+		wantModel    string
+		wantErr      string
+	}{
+		{"claude-sonnet-4-5", config.ProviderAnthropic, "claude-sonnet-4-5", ""},
+		{"anthropic:claude-haiku-4-5", config.ProviderAnthropic, "claude-haiku-4-5", ""},
+		{"openai:gpt-4o-mini", config.ProviderOpenAI, "gpt-4o-mini", ""},
+		{"ollama:llama3.1:8b", config.ProviderOllama, "llama3.1:8b", ""},
+		{"mistral:large", "", "", "unknown provider"},
+		{"ollama:", "", "", "names no model"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			c := &Client{providers: map[string]LLMProvider{}, retry: config.RetryConfig{MaxAttempts: 1}}
+			stubs := map[string]*stubProvider{}
+			for _, name := range []string{config.ProviderAnthropic, config.ProviderOpenAI, config.ProviderOllama} {
+				stubs[name] = &stubProvider{}
+				c.SetProvider(name, stubs[name])
+			}
+
+			_, err := c.Complete(context.Background(), Request{Agent: "story_writer", Model: tt.model})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Complete = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			for name, stub := range stubs {
+				if name == tt.wantProvider {
+					if len(stub.models) != 1 || stub.models[0] != tt.wantModel {
+						t.Errorf("%s received %v, want [%s]", name, stub.models, tt.wantModel)
+					}
+				} else if len(stub.models) != 0 {
+					t.Errorf("%s received %v, want nothing", name, stub.models)
+				}
+			}
+		})
+	}
+}
+
+func TestClientCompleteUnconfiguredProvider(t *testing.T) {
+	c := NewClient(&config.Config{})
+	_, err := c.Complete(context.Background(), Request{Agent: "story_writer", Model: "ollama:llama3.1:8b"})
+	if err == nil || !strings.Contains(err.Error(), "provider ollama is not configured") {
+		t.Errorf("Complete = %v, want provider ollama is not configured", err)
+	}
+}
+
+func TestClientCompleteRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantAttempts int32
+	}{
+		{"rate limit retried", http.StatusTooManyRequests, 3},
+		{"server error retried", http.StatusServiceUnavailable, 3},
+		{"bad request not retried", http.StatusBadRequest, 1},
+		{"not found not retried", http.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"error":"nope"}`))
+			}))
+			defer srv.Close()
+
+			cfg := &config.Config{}
+			cfg.Anthropic.Retry = config.RetryConfig{MaxAttempts: 3, InitialDelayMs: 1, MaxDelayMs: 1, Multiplier: 1}
+			cfg.Providers.Ollama = config.OllamaConfig{BaseURL: srv.URL}
+			c := NewClient(cfg)
+
+			log := &CallLog{}
+			_, err := c.Complete(WithCallLog(context.Background(), log), Request{Agent: "story_writer", Model: "ollama:llama3.1:8b"})
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status {
+				t.Fatalf("Complete = %v, want the %d status error", err, tt.status)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("server saw %d attempts, want %d", got, tt.wantAttempts)
+			}
+			if calls := log.Calls(); len(calls) != 1 || int32(calls[0].Attempts) != tt.wantAttempts {
+				t.Errorf("calls = %+v, want one call with %d attempts", calls, tt.wantAttempts)
+			}
+		})
+	}
+}
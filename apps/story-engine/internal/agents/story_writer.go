@@ -0,0 +1,70 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+const storyWriterAgent = "story_writer"
+
+// StoryWriter turns a chapter plan into prose, and revises drafts that
+// failed a later check.
+type StoryWriter struct {
+	client *Client
+	cfg    *config.Config
+	agent  config.AgentConfig
+	prompt string
+}
+
+// NewStoryWriter creates a story writer, loading its system prompt.
+func NewStoryWriter(client *Client, cfg *config.Config) (*StoryWriter, error) {
+	agentCfg, prompt, err := loadAgent(cfg, storyWriterAgent)
+	if err != nil {
+		return nil, err
+	}
+	return &StoryWriter{client: client, cfg: cfg, agent: agentCfg, prompt: prompt}, nil
+}
+
+// Revise sends a drafted chapter back to the writer with a list of problems
+// to fix. Plot, POV and emotional beat are kept; only the flagged passages
+// should change.
+func (w *StoryWriter) Revise(ctx context.Context, draft *models.Chapter, feedback []string) (*models.Chapter, error) {
+	var msg strings.Builder
+	msg.WriteString("Revise the chapter below to fix every issue listed. Keep the plot, POV, emotional beat and length constraints. Change only what is needed.\n\n")
+	msg.WriteString("## Issues\n\n")
+	for _, f := range feedback {
+		msg.WriteString("- " + f + "\n")
+	}
+	msg.WriteString("\n## Chapter\n\n")
+	msg.WriteString(encodeJSON(draft))
+	msg.WriteString("\n\nRespond with the revised chapter in the same JSON output format.")
+
+	req := newRequest(w.cfg, storyWriterAgent, w.agent)
+	req.System = w.prompt
+	req.Messages = []Message{{Role: "user", Content: msg.String()}}
+
+	resp, err := w.client.Complete(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var revised models.Chapter
+	if err := decodeJSON(resp.Text, &revised); err != nil {
+		return nil, fmt.Errorf("story writer: %w", err)
+	}
+	if revised.Text == "" {
+		return nil, fmt.Errorf("story writer returned an empty chapter")
+	}
+	if revised.ChapterNumber == 0 {
+		revised.ChapterNumber = draft.ChapterNumber
+	}
+	// Never trust the model's own count
+	revised.CharacterCount = utf8.RuneCountInString(revised.Text)
+
+	return &revised, nil
+}
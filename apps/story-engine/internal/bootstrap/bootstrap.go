@@ -0,0 +1,304 @@
+// Package bootstrap creates the data files for a new story.
+//
+// A new story starts from the *.template.json examples shipped in the data
+// directory: each is copied to a real file with its editor notes removed.
+// The world map gets a single origin location and the world state starts
+// empty.
+
+package bootstrap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// OriginID is the location id of the origin of a new world map.
+const OriginID = "loc_001"
+
+// ErrStoryExists is returned by Init when story files are already present
+// and Force is not set.
+var ErrStoryExists = errors.New("a story already exists")
+
+// Options are the author's choices for a new story.
+type Options struct {
+	Title    string
+	Tagline  string
+	Genre    []string
+	Tone     []string
+	Universe string
+	Origin   string
+
+	// Premise replaces the template premise when set.
+	Premise any
+
+	// Force overwrites an existing story.
+	Force bool
+
+	Now time.Time
+}
+
+// Result lists what Init did.
+type Result struct {
+	Written []string
+	// Skipped are templates that could not be copied, with the reason.
+	Skipped map[string]error
+}
+
+// Existing returns the first file that shows a story has already been
+// created: the story bible, world map, world state or any non-template
+// entity file. Init writes all of them, so any one is enough to refuse.
+func Existing(store *storage.Store) (string, bool, error) {
+	for _, path := range []string{store.StoryBiblePath(), store.WorldMapPath(), store.WorldStatePath()} {
+		if _, err := os.Stat(path); err == nil {
+			return path, true, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", false, fmt.Errorf("failed to check %s: %w", path, err)
+		}
+	}
+
+	files, err := store.EntityFiles(false)
+	if err != nil {
+		return "", false, err
+	}
+	if len(files) > 0 {
+		return files[0], true, nil
+	}
+	return "", false, nil
+}
+
+// Init writes the story bible, entity files, world map and world state for
+// a new story.
+func Init(store *storage.Store, opts Options) (*Result, error) {
+	if opts.Title == "" {
+		return nil, fmt.Errorf("story title is required")
+	}
+	if opts.Origin == "" {
+		return nil, fmt.Errorf("origin location is required")
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	if !opts.Force {
+		path, exists, err := Existing(store)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("%w (%s), use --force to overwrite", ErrStoryExists, path)
+		}
+	}
+
+	res := &Result{Skipped: make(map[string]error)}
+	timestamp := opts.Now.UTC().Format(time.RFC3339)
+
+	index, err := copyEntityTemplates(store, opts, res)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeStoryBible(store, opts, timestamp, index); err != nil {
+		return nil, err
+	}
+	res.Written = append(res.Written, store.StoryBiblePath())
+
+	if err := storage.WriteJSON(store.WorldMapPath(), newWorldMap(opts, timestamp)); err != nil {
+		return nil, err
+	}
+	res.Written = append(res.Written, store.WorldMapPath())
+
+	if err := storage.WriteJSON(store.WorldStatePath(), newWorldState(timestamp)); err != nil {
+		return nil, err
+	}
+	res.Written = append(res.Written, store.WorldStatePath())
+
+	return res, nil
+}
+
+// StripNotes removes editor notes ("_note", "_template_note" and other
+// "_*_note" keys) from every object in v.
+func StripNotes(v any) {
+	storage.Walk(v, func(o *storage.Object) {
+		for _, key := range o.Keys() {
+			if storage.IsNoteKey(key) {
+				o.Delete(key)
+			}
+		}
+	})
+}
+
+// copyEntityTemplates copies each entity template to <dir>/<id>.json and
+// returns the entity index for the story bible. The location template
+// becomes the origin location, and entities placed at a location are moved
+// there. Templates that fail to parse are skipped so
+// one broken example does not block the rest.
+func copyEntityTemplates(store *storage.Store, opts Options, res *Result) (*storage.Object, error) {
+	files, err := store.EntityFiles(true)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]string{
+		models.TypeCharacter: "characters",
+		models.TypeLocation:  "locations",
+		models.TypeObject:    "objects",
+		models.TypeCreature:  "creatures",
+	}
+	entries := map[string][]any{}
+
+	for _, path := range files {
+		if !storage.IsTemplate(path) {
+			continue
+		}
+
+		doc, err := storage.ReadObject(path)
+		if err != nil {
+			res.Skipped[path] = err
+			continue
+		}
+		StripNotes(doc)
+
+		entityType := valueOf(doc, "type")
+		if entityType == models.TypeLocation {
+			doc.Set("id", OriginID)
+			doc.Set("name", opts.Origin)
+		} else if loc, ok := valueOf(doc, "location").(*storage.Object); ok {
+			// Start everyone at the origin, the only place on the new map
+			if _, isID := valueOf(loc, "current").(string); isID {
+				loc.Set("current", OriginID)
+			}
+		}
+
+		id, _ := valueOf(doc, "id").(string)
+		if id == "" || filepath.Base(id) != id {
+			res.Skipped[path] = fmt.Errorf("template has no usable id")
+			continue
+		}
+
+		dest := filepath.Join(filepath.Dir(path), id+".json")
+		if err := storage.WriteJSON(dest, doc); err != nil {
+			return nil, err
+		}
+		res.Written = append(res.Written, dest)
+
+		if group, ok := groups[fmt.Sprint(entityType)]; ok {
+			entry := storage.NewObject()
+			entry.Set("id", id)
+			entry.Set("name", valueOf(doc, "name"))
+			if status, ok := doc.Get("status"); ok {
+				if s, ok := status.(*storage.Object); ok {
+					entry.Set("status", valueOf(s, "current"))
+				}
+			}
+			entries[group] = append(entries[group], entry)
+		}
+	}
+
+	index := storage.NewObject()
+	for _, group := range []string{"characters", "locations", "objects", "creatures"} {
+		items := entries[group]
+		if items == nil {
+			items = []any{}
+		}
+		index.Set(group, items)
+	}
+	return index, nil
+}
+
+// writeStoryBible copies the story bible template, seeding its meta and
+// universe name from opts.
+func writeStoryBible(store *storage.Store, opts Options, timestamp string, index *storage.Object) error {
+	doc, err := storage.ReadObject(storage.TemplatePath(store.StoryBiblePath()))
+	if err != nil {
+		return err
+	}
+	StripNotes(doc)
+
+	meta := doc.Path("meta")
+	if meta == nil {
+		return fmt.Errorf("story bible template meta is not an object")
+	}
+	meta.Set("story_title", opts.Title)
+	meta.Set("tagline", opts.Tagline)
+	if opts.Genre != nil {
+		meta.Set("genre", opts.Genre)
+	}
+	if opts.Tone != nil {
+		meta.Set("tone", opts.Tone)
+	}
+	meta.Set("created_at", timestamp)
+	meta.Set("last_updated", timestamp)
+
+	if opts.Universe != "" {
+		universe := doc.Path("universe")
+		if universe == nil {
+			return fmt.Errorf("story bible template universe is not an object")
+		}
+		universe.Set("name", opts.Universe)
+	}
+
+	if opts.Premise != nil {
+		doc.Set("premise", opts.Premise)
+	}
+	doc.Set("entity_index", index)
+
+	return storage.WriteJSON(store.StoryBiblePath(), doc)
+}
+
+func newWorldMap(opts Options, timestamp string) *models.WorldMap {
+	chapter := 1
+	return &models.WorldMap{
+		Description: "World topology - locations and their connections. Source of truth for geography.",
+		Meta: models.WorldMapMeta{
+			WorldName:        opts.Universe,
+			CoordinateSystem: "Relative grid. Each unit ≈ 1 day travel on clear terrain.",
+			OriginNote:       fmt.Sprintf("%s (%s) is the anchor point at (0, 0).", opts.Origin, OriginID),
+			LastUpdated:      timestamp,
+		},
+		Regions: []models.Region{},
+		Locations: []models.Location{{
+			ID:                OriginID,
+			Name:              opts.Origin,
+			Discovered:        true,
+			DiscoveredChapter: &chapter,
+		}},
+		Connections: []models.Connection{},
+		DistanceMatrix: models.DistanceMatrix{
+			Note: "Quick reference for travel times between key locations (in days). -1 = unknown/impassable.",
+		},
+		TerrainTypes: map[string]models.TerrainType{},
+		CardinalDirections: models.CardinalDirections{
+			Note: "For prose generation - how to describe travel between locations",
+		},
+	}
+}
+
+func newWorldState(timestamp string) *models.WorldState {
+	return &models.WorldState{
+		Description:        "Current world state - where all mobile entities are RIGHT NOW. Updated after each chapter.",
+		Meta:               models.WorldStateMeta{AsOfChapter: 0, LastUpdated: timestamp},
+		CharacterPositions: []models.CharacterPosition{},
+		CreaturePositions:  []models.CreaturePosition{},
+		ObjectPositions:    []models.ObjectPosition{},
+		RecentMovements:    []models.Movement{},
+		LocationOccupancy: map[string]json.RawMessage{
+			"_note": json.RawMessage(`"Quick lookup: who is in each location right now?"`),
+		},
+		ProximityAlerts:  []models.ProximityAlert{},
+		TravelInProgress: []models.Travel{},
+	}
+}
+
+// valueOf returns the value under key, or nil.
+func valueOf(o *storage.Object, key string) any {
+	v, _ := o.Get(key)
+	return v
+}
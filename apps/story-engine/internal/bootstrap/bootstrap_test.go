@@ -0,0 +1,51 @@
+package bootstrap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+func TestInitRefusesExistingStory(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+	}{
+		{"story bible", "story_bible.json"},
+		{"world map", "world/world_map.json"},
+		{"world state", "world/world_state.json"},
+		{"entity", "entities/characters/char_001.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := storage.New(config.PathsConfig{
+				DataDir:     dir,
+				StoryBible:  "story_bible.json",
+				EntitiesDir: "entities",
+				WorldDir:    "world",
+			})
+			path := filepath.Join(dir, tt.existing)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			original := []byte(`{"kept": true}`)
+			if err := os.WriteFile(path, original, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Init(store, Options{Title: "Thornwood", Origin: "Gallows Crossing"})
+			if !errors.Is(err, ErrStoryExists) {
+				t.Fatalf("Init error = %v, want ErrStoryExists", err)
+			}
+			if got, _ := os.ReadFile(path); string(got) != string(original) {
+				t.Errorf("%s was overwritten: %s", tt.existing, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+// Package continuity cross-checks entity files, world_state.json and
+// world_map.json for contradictions.
+//
+// Entity files are the source of truth for history, world_state for where
+// things are now, and world_map for geography. When a chapter updates one and
+// not the others they drift apart; this package reports where.
+
+package continuity
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// Severity of a continuity issue.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue codes.
+const (
+	CodeLoadFailed       = "load_failed"
+	CodeUnknownLocation  = "unknown_location"
+	CodeLocationMismatch = "location_mismatch"
+	CodeDeadMoved        = "dead_moved"
+	CodeAbsentCarrier    = "absent_carrier"
+	CodeCarrierMismatch  = "carrier_mismatch"
+	CodeStaleAppearance  = "stale_last_appeared"
+)
+
+// Issue is a single continuity problem.
+type Issue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	EntityID string `json:"entity_id,omitempty"`
+	File     string `json:"file,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", i.Severity, i.Code)
+	if i.EntityID != "" {
+		fmt.Fprintf(&b, " %s", i.EntityID)
+	}
+	fmt.Fprintf(&b, ": %s", i.Message)
+	if i.File != "" {
+		fmt.Fprintf(&b, " (%s)", i.File)
+	}
+	return b.String()
+}
+
+// Report is the result of a continuity check.
+type Report struct {
+	Issues []Issue `json:"issues"`
+}
+
+// HasErrors reports whether any issue is an error rather than a warning.
+func (r *Report) HasErrors() bool {
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Input is everything the validator cross-checks. Bible may be nil if the
+// story has no story bible yet.
+type Input struct {
+	Map        *models.WorldMap
+	State      *models.WorldState
+	Bible      *models.StoryBible
+	Entities   []*models.Entity
+	LoadErrors map[string]error
+}
+
+// validator holds the lookups built from Input while checks run.
+type validator struct {
+	in       Input
+	entities map[string]*models.Entity
+	tracked  map[string]models.PositionLocation
+	issues   []Issue
+}
+
+// Validate runs every continuity check and returns the issues found, errors first.
+func Validate(in Input) *Report {
+	v := &validator{
+		in:       in,
+		entities: make(map[string]*models.Entity, len(in.Entities)),
+		tracked:  make(map[string]models.PositionLocation),
+	}
+	for _, e := range in.Entities {
+		v.entities[e.ID] = e
+	}
+	for _, c := range in.State.CharacterPositions {
+		v.tracked[c.EntityID] = c.CurrentLocation
+	}
+	for _, c := range in.State.CreaturePositions {
+		v.tracked[c.EntityID] = c.CurrentLocation
+	}
+
+	paths := make([]string, 0, len(in.LoadErrors))
+	for path := range in.LoadErrors {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		v.add(SeverityError, CodeLoadFailed, "", path, "%s", in.LoadErrors[path])
+	}
+
+	v.checkStateLocations()
+	v.checkEntityLocations()
+	v.checkDeadMovement()
+	v.checkCarriers()
+	v.checkLastAppeared()
+
+	sort.SliceStable(v.issues, func(i, j int) bool {
+		return v.issues[i].Severity == SeverityError && v.issues[j].Severity != SeverityError
+	})
+	return &Report{Issues: v.issues}
+}
+
+func (v *validator) add(severity, code, entityID, file, format string, args ...any) {
+	v.issues = append(v.issues, Issue{
+		Severity: severity,
+		Code:     code,
+		EntityID: entityID,
+		File:     file,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// knownLocation reports whether id is on the map. "unknown" and empty ids
+// are valid placeholders for entities whose position is a mystery.
+func (v *validator) knownLocation(id string) bool {
+	if id == "" || id == "unknown" {
+		return true
+	}
+	return v.in.Map.LocationByID(id) != nil
+}
+
+// checkStateLocations reports world_state location ids with no map entry.
+func (v *validator) checkStateLocations() {
+	for _, c := range v.in.State.CharacterPositions {
+		if !v.knownLocation(c.CurrentLocation.LocationID) {
+			v.add(SeverityError, CodeUnknownLocation, c.EntityID, "world_state.json",
+				"current location %s is not on the world map", c.CurrentLocation.LocationID)
+		}
+		if !v.knownLocation(c.Destination) {
+			v.add(SeverityError, CodeUnknownLocation, c.EntityID, "world_state.json",
+				"destination %s is not on the world map", c.Destination)
+		}
+	}
+	for _, c := range v.in.State.CreaturePositions {
+		if !v.knownLocation(c.CurrentLocation.LocationID) {
+			v.add(SeverityError, CodeUnknownLocation, c.EntityID, "world_state.json",
+				"current location %s is not on the world map", c.CurrentLocation.LocationID)
+		}
+		for _, loc := range c.Territory {
+			if !v.knownLocation(loc) {
+				v.add(SeverityError, CodeUnknownLocation, c.EntityID, "world_state.json",
+					"territory location %s is not on the world map", loc)
+			}
+		}
+	}
+	for _, o := range v.in.State.ObjectPositions {
+		if !v.knownLocation(o.LocationID) {
+			v.add(SeverityError, CodeUnknownLocation, o.EntityID, "world_state.json",
+				"location %s is not on the world map", o.LocationID)
+		}
+	}
+}
+
+// checkEntityLocations compares entity location.current with world_state,
+// which is the source of truth for current positions.
+func (v *validator) checkEntityLocations() {
+	for _, e := range v.in.Entities {
+		switch e.Type {
+		case models.TypeLocation:
+			if !v.knownLocation(e.ID) {
+				v.add(SeverityError, CodeUnknownLocation, e.ID, e.Path,
+					"location entity has no entry in the world map")
+			}
+			continue
+		case models.TypeObject:
+			continue
+		}
+
+		current := e.Location.Placement().LocationID
+		if !v.knownLocation(current) {
+			v.add(SeverityError, CodeUnknownLocation, e.ID, e.Path,
+				"location.current %s is not on the world map", current)
+		}
+
+		state, ok := v.tracked[e.ID]
+		if !ok || state.LocationID == "" || current == "" {
+			continue
+		}
+		if state.LocationID != current {
+			v.add(SeverityError, CodeLocationMismatch, e.ID, e.Path,
+				"entity file says %s is at %s but world_state says %s (%s)",
+				e.Name, current, state.LocationID, describeLocation(state))
+		}
+	}
+}
+
+// checkDeadMovement reports dead entities that moved after their death.
+func (v *validator) checkDeadMovement() {
+	for _, e := range v.in.Entities {
+		died, ok := e.DiedInChapter()
+		if !ok {
+			if !e.IsDead() {
+				continue
+			}
+			died = 0
+		}
+
+		for _, h := range e.Location.History {
+			if died > 0 && h.Chapter > died {
+				v.add(SeverityError, CodeDeadMoved, e.ID, e.Path,
+					"%s died in chapter %d but location.history moves them in chapter %d", e.Name, died, h.Chapter)
+			}
+		}
+		for _, m := range v.in.State.RecentMovements {
+			if m.EntityID == e.ID && (died == 0 || m.Chapter > died) {
+				v.add(SeverityError, CodeDeadMoved, e.ID, "world_state.json",
+					"%s is dead but recent_movements moves them in chapter %d", e.Name, m.Chapter)
+			}
+		}
+		if c := v.in.State.CharacterByID(e.ID); c != nil && c.MovementStatus == "traveling" {
+			v.add(SeverityError, CodeDeadMoved, e.ID, "world_state.json",
+				"%s is dead but world_state has them traveling", e.Name)
+		}
+	}
+}
+
+// checkCarriers reports objects carried by characters who are not present,
+// not tracked, or dead, and object files that disagree about who carries them.
+func (v *validator) checkCarriers() {
+	for _, o := range v.in.State.ObjectPositions {
+		if o.PositionType != "carried" || o.CarrierID == "" {
+			continue
+		}
+
+		loc, tracked := v.tracked[o.CarrierID]
+		switch {
+		case !tracked:
+			v.add(SeverityError, CodeAbsentCarrier, o.EntityID, "world_state.json",
+				"%s is carried by %s, who has no position in world_state", o.Name, o.CarrierID)
+		case v.isDead(o.CarrierID):
+			v.add(SeverityError, CodeAbsentCarrier, o.EntityID, "world_state.json",
+				"%s is carried by %s, who is dead", o.Name, o.CarrierID)
+		case loc.LocationID == "" || loc.LocationID == "unknown":
+			v.add(SeverityWarning, CodeAbsentCarrier, o.EntityID, "world_state.json",
+				"%s is carried by %s, whose location is unknown", o.Name, o.CarrierID)
+		case v.isMissing(o.CarrierID):
+			v.add(SeverityWarning, CodeAbsentCarrier, o.EntityID, "world_state.json",
+				"%s is carried by %s, who is missing", o.Name, o.CarrierID)
+		}
+
+		if e, ok := v.entities[o.EntityID]; ok {
+			if p := e.Location.Placement(); p.CarrierID != "" && p.CarrierID != o.CarrierID {
+				v.add(SeverityError, CodeCarrierMismatch, o.EntityID, e.Path,
+					"entity file says %s carries %s but world_state says %s", p.CarrierID, o.Name, o.CarrierID)
+			}
+		}
+	}
+}
+
+// checkLastAppeared reports entities whose last-appeared chapter is behind
+// chapters in which their own history or world_state shows them on the page.
+func (v *validator) checkLastAppeared() {
+	latest := make(map[string]int)
+	bump := func(id string, ch int) {
+		if ch > latest[id] {
+			latest[id] = ch
+		}
+	}
+	for _, m := range v.in.State.RecentMovements {
+		bump(m.EntityID, m.Chapter)
+	}
+	for _, c := range v.in.State.CharacterPositions {
+		if c.LastMovedChapter != nil {
+			bump(c.EntityID, *c.LastMovedChapter)
+		}
+	}
+	for _, c := range v.in.State.CreaturePositions {
+		if c.LastSeenChapter != nil {
+			bump(c.EntityID, *c.LastSeenChapter)
+		}
+	}
+
+	for _, e := range v.in.Entities {
+		for _, h := range e.Status.History {
+			bump(e.ID, h.Chapter)
+		}
+		for _, h := range e.Location.History {
+			bump(e.ID, h.Chapter)
+		}
+		for _, k := range e.KeyEvents {
+			bump(e.ID, k.Chapter)
+		}
+
+		last, ok := e.LastAppeared()
+		if !ok {
+			continue
+		}
+		if seen := latest[e.ID]; seen > last {
+			v.add(SeverityWarning, CodeStaleAppearance, e.ID, e.Path,
+				"last appeared chapter is %d but %s is on the page in chapter %d", last, e.Name, seen)
+		}
+	}
+}
+
+// isDead checks the entity file first, then the story bible index.
+func (v *validator) isDead(id string) bool {
+	if e, ok := v.entities[id]; ok {
+		return e.IsDead()
+	}
+	if v.in.Bible != nil {
+		if status, ok := v.in.Bible.IndexStatus(id); ok {
+			return models.IsDeadStatus(status)
+		}
+	}
+	return false
+}
+
+func (v *validator) isMissing(id string) bool {
+	status := ""
+	if e, ok := v.entities[id]; ok {
+		status = e.Status.Current
+	} else if v.in.Bible != nil {
+		status, _ = v.in.Bible.IndexStatus(id)
+	}
+	return strings.EqualFold(status, "missing")
+}
+
+func describeLocation(loc models.PositionLocation) string {
+	if loc.SubLocation != "" {
+		return loc.SubLocation
+	}
+	return loc.LocationName
+}
+
+// LoadInput gathers everything Validate needs from the store. Entity files
+// that fail to parse are recorded in LoadErrors rather than aborting, so one
+// bad hand edit does not hide every other problem. A missing story bible is
+// not an error.
+func LoadInput(store *storage.Store, includeTemplates bool) (Input, error) {
+	in := Input{LoadErrors: make(map[string]error)}
+
+	var err error
+	if in.Map, err = store.LoadWorldMap(); err != nil {
+		return in, err
+	}
+	if in.State, err = store.LoadWorldState(); err != nil {
+		return in, err
+	}
+	if _, statErr := os.Stat(store.StoryBiblePath()); statErr == nil {
+		if in.Bible, err = store.LoadStoryBible(); err != nil {
+			return in, err
+		}
+	}
+
+	files, err := store.EntityFiles(includeTemplates)
+	if err != nil {
+		return in, err
+	}
+	for _, path := range files {
+		e, err := storage.LoadEntity(path)
+		if err != nil {
+			in.LoadErrors[path] = err
+			continue
+		}
+		in.Entities = append(in.Entities, e)
+	}
+
+	return in, nil
+}
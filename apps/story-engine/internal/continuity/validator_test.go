@@ -0,0 +1,265 @@
+package continuity
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// sampleInput loads the shipped sample story, whose entity templates are
+// the only entity files. Its character file has Mira at loc_003 while
+// world_state has her at loc_001.
+func sampleInput(t *testing.T) Input {
+	t.Helper()
+	store := storage.New(config.PathsConfig{
+		DataDir:     "../../data",
+		StoryBible:  "story_bible.json",
+		EntitiesDir: "entities",
+		WorldDir:    "world",
+	})
+	in, err := LoadInput(store, true)
+	if err != nil {
+		t.Fatalf("LoadInput: %v", err)
+	}
+	return in
+}
+
+func entity(t *testing.T, in *Input, id string) *models.Entity {
+	t.Helper()
+	for _, e := range in.Entities {
+		if e.ID == id {
+			return e
+		}
+	}
+	t.Fatalf("no entity %s in the sample data", id)
+	return nil
+}
+
+func objectPosition(t *testing.T, in *Input, id string) *models.ObjectPosition {
+	t.Helper()
+	for i := range in.State.ObjectPositions {
+		if in.State.ObjectPositions[i].EntityID == id {
+			return &in.State.ObjectPositions[i]
+		}
+	}
+	t.Fatalf("no object position %s in the sample data", id)
+	return nil
+}
+
+// agree moves Mira's entity file to where world_state has her, which
+// leaves the sample data free of issues.
+func agree(t *testing.T, in *Input) {
+	entity(t, in, "char_001").Location.Current = json.RawMessage(`"loc_001"`)
+}
+
+// withBible sets a story bible whose index gives each character a status.
+func withBible(in *Input, statuses map[string]string) {
+	in.Bible = &models.StoryBible{}
+	for id, status := range statuses {
+		in.Bible.EntityIndex.Characters = append(in.Bible.EntityIndex.Characters, models.IndexEntry{ID: id, Status: status})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		// sample keeps the sample data's own disagreement
+		sample bool
+		edit   func(t *testing.T, in *Input)
+		// want is each issue as "severity code entity", in report order;
+		// wantMessage is part of the first issue's message
+		want        []string
+		wantMessage string
+	}{
+		{
+			name:        "sample data",
+			sample:      true,
+			want:        []string{"error location_mismatch char_001"},
+			wantMessage: "entity file says Mira Thorne is at loc_003 but world_state says loc_001",
+		},
+		{
+			name: "agreeing data",
+		},
+		{
+			name: "unknown destination",
+			edit: func(t *testing.T, in *Input) {
+				in.State.CharacterPositions[0].Destination = "loc_099"
+			},
+			want:        []string{"error unknown_location char_001"},
+			wantMessage: "destination loc_099 is not on the world map",
+		},
+		{
+			name: "unknown creature territory",
+			edit: func(t *testing.T, in *Input) {
+				in.State.CreaturePositions[1].Territory = []string{"loc_001", "loc_099"}
+			},
+			want:        []string{"error unknown_location crt_002"},
+			wantMessage: "territory location loc_099",
+		},
+		{
+			name: "unknown entity location",
+			edit: func(t *testing.T, in *Input) {
+				entity(t, in, "char_001").Location.Current = json.RawMessage(`"loc_099"`)
+			},
+			want:        []string{"error unknown_location char_001", "error location_mismatch char_001"},
+			wantMessage: "location.current loc_099 is not on the world map",
+		},
+		{
+			name: "location entity missing from the map",
+			edit: func(t *testing.T, in *Input) {
+				entity(t, in, "loc_001").ID = "loc_099"
+			},
+			want: []string{"error unknown_location loc_099"},
+		},
+		{
+			name: "unknown placeholder",
+			edit: func(t *testing.T, in *Input) {
+				in.State.CharacterPositions[0].Destination = "unknown"
+				objectPosition(t, in, "obj_005").LocationID = ""
+			},
+		},
+		{
+			name: "moved after a recorded death",
+			edit: func(t *testing.T, in *Input) {
+				crt := entity(t, in, "crt_001")
+				crt.Status.Current = "dead"
+				crt.Status.History = append(crt.Status.History, models.StatusChange{Chapter: 12, Status: "killed"})
+			},
+			want:        []string{"error dead_moved crt_001"},
+			wantMessage: "Thornback died in chapter 12 but location.history moves them in chapter 15",
+		},
+		{
+			name: "moved in the chapter of death",
+			edit: func(t *testing.T, in *Input) {
+				crt := entity(t, in, "crt_001")
+				crt.Status.Current = "dead"
+				crt.Status.History = append(crt.Status.History, models.StatusChange{Chapter: 15, Status: "dead"})
+			},
+		},
+		{
+			// Kael is dead with no chapter of death: his entity file's
+			// history can't be checked, but any movement in world_state can
+			name: "dead in an unknown chapter",
+			edit: func(t *testing.T, in *Input) {
+				in.Entities = append(in.Entities, &models.Entity{
+					ID: "char_002", Name: "Kael Vorn", Type: models.TypeCharacter,
+					Status:   models.EntityStatus{Current: "deceased"},
+					Location: models.EntityLocation{History: []models.LocationChange{{Chapter: 12, Location: "loc_001"}}},
+				})
+				in.State.CharacterPositions[1].MovementStatus = "traveling"
+			},
+			want:        []string{"error dead_moved char_002", "error dead_moved char_002"},
+			wantMessage: "Kael Vorn is dead but recent_movements moves them in chapter 12",
+		},
+		{
+			name: "carrier with no position",
+			edit: func(t *testing.T, in *Input) {
+				objectPosition(t, in, "obj_002").CarrierID = "char_009"
+			},
+			want:        []string{"error absent_carrier obj_002"},
+			wantMessage: "carried by char_009, who has no position in world_state",
+		},
+		{
+			name: "dead carrier in the bible",
+			edit: func(t *testing.T, in *Input) {
+				withBible(in, map[string]string{"char_002": "Deceased"})
+				objectPosition(t, in, "obj_002").CarrierID = "char_002"
+			},
+			want:        []string{"error absent_carrier obj_002"},
+			wantMessage: "carried by char_002, who is dead",
+		},
+		{
+			name: "dead carrier in the entity file",
+			edit: func(t *testing.T, in *Input) {
+				mira := entity(t, in, "char_001")
+				mira.Status.Current = "dead"
+				mira.Status.History = append(mira.Status.History, models.StatusChange{Chapter: 16, Status: "dead"})
+			},
+			want: []string{"error absent_carrier obj_001", "error absent_carrier obj_002", "error absent_carrier obj_003"},
+		},
+		{
+			name: "carrier at an unknown location",
+			edit: func(t *testing.T, in *Input) {
+				objectPosition(t, in, "obj_002").CarrierID = "char_003"
+			},
+			want:        []string{"warning absent_carrier obj_002"},
+			wantMessage: "whose location is unknown",
+		},
+		{
+			name: "missing carrier",
+			edit: func(t *testing.T, in *Input) {
+				withBible(in, map[string]string{"char_002": "missing"})
+				objectPosition(t, in, "obj_002").CarrierID = "char_002"
+			},
+			want:        []string{"warning absent_carrier obj_002"},
+			wantMessage: "carried by char_002, who is missing",
+		},
+		{
+			name: "carrier mismatch",
+			edit: func(t *testing.T, in *Input) {
+				objectPosition(t, in, "obj_001").CarrierID = "char_002"
+			},
+			want:        []string{"error carrier_mismatch obj_001"},
+			wantMessage: "entity file says char_001 carries The Burned Map but world_state says char_002",
+		},
+		{
+			name: "stale last appeared from world_state",
+			edit: func(t *testing.T, in *Input) {
+				in.State.RecentMovements = append(in.State.RecentMovements, models.Movement{Chapter: 18, EntityID: "char_001"})
+			},
+			want:        []string{"warning stale_last_appeared char_001"},
+			wantMessage: "last appeared chapter is 16 but Mira Thorne is on the page in chapter 18",
+		},
+		{
+			name: "stale last appeared from key events",
+			edit: func(t *testing.T, in *Input) {
+				obj := entity(t, in, "obj_001")
+				obj.KeyEvents = append(obj.KeyEvents, models.ChapterEvent{Chapter: 16})
+			},
+			want:        []string{"warning stale_last_appeared obj_001"},
+			wantMessage: "last appeared chapter is 14",
+		},
+		{
+			name: "errors before warnings",
+			edit: func(t *testing.T, in *Input) {
+				in.State.RecentMovements = append(in.State.RecentMovements, models.Movement{Chapter: 18, EntityID: "char_001"})
+				in.LoadErrors["data/entities/characters/char_009.json"] = errors.New("invalid character")
+			},
+			want: []string{"error load_failed ", "warning stale_last_appeared char_001"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := sampleInput(t)
+			if !tt.sample {
+				agree(t, &in)
+			}
+			if tt.edit != nil {
+				tt.edit(t, &in)
+			}
+
+			report := Validate(in)
+			var got []string
+			for _, i := range report.Issues {
+				got = append(got, i.Severity+" "+i.Code+" "+i.EntityID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("issues = %q, want %q\n%v", got, tt.want, report.Issues)
+			}
+			if tt.wantMessage != "" && !strings.Contains(report.Issues[0].Message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to contain %q", report.Issues[0].Message, tt.wantMessage)
+			}
+			wantErrors := slices.ContainsFunc(tt.want, func(s string) bool { return strings.HasPrefix(s, SeverityError) })
+			if report.HasErrors() != wantErrors {
+				t.Errorf("HasErrors = %v, want %v", report.HasErrors(), wantErrors)
+			}
+		})
+	}
+}
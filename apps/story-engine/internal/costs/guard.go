@@ -0,0 +1,117 @@
+package costs
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+)
+
+// ErrBudgetExceeded is returned when a call would take spend over a cap.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Guard checks the estimated cost of each call against the per-run and
+// per-day caps before it is made. With on_exceed "downgrade" it first tries
+// the fast model, or fewer images, and only refuses when that still does
+// not fit.
+type Guard struct {
+	cfg       config.CostTrackingConfig
+	fastModel string
+	ledger    *Ledger
+	runStart  time.Time
+	logger    *slog.Logger
+}
+
+// NewGuard creates a guard for a run starting now. Spend so far is read
+// from the ledger.
+func NewGuard(cfg config.CostTrackingConfig, fastModel string, ledger *Ledger) *Guard {
+	return &Guard{cfg: cfg, fastModel: fastModel, ledger: ledger, runStart: ledger.now().UTC(), logger: slog.Default()}
+}
+
+// SetLogger sets the logger of the guard and its ledger, normally the
+// run's. Call it before the run starts.
+func (g *Guard) SetLogger(logger *slog.Logger) {
+	g.logger = logger
+	g.ledger.SetLogger(logger)
+}
+
+// Preflight implements agents.BudgetGuard. It returns the model to use:
+// the requested one, or the fast model if only that fits.
+func (g *Guard) Preflight(e agents.Estimate) (string, error) {
+	run, day, err := g.ledger.Spent(g.runStart)
+	if err != nil {
+		return "", fmt.Errorf("failed to check budget: %w", err)
+	}
+
+	cost := g.estimateLLM(e.Model, e.InputTokens, e.MaxOutputTokens)
+	overErr := g.check(e.Agent+" call", cost, run, day)
+	if overErr == nil {
+		return e.Model, nil
+	}
+
+	if g.cfg.Budget.OnExceed == "downgrade" && e.Model != g.fastModel {
+		fastCost := g.estimateLLM(g.fastModel, e.InputTokens, e.MaxOutputTokens)
+		if g.check(e.Agent+" call", fastCost, run, day) == nil {
+			g.logger.Warn("budget: switching to the fast model",
+				"agent", e.Agent, "model", e.Model, "estimate_usd", cost, "fast_model", g.fastModel, "fast_estimate_usd", fastCost)
+			return g.fastModel, nil
+		}
+	}
+	return "", overErr
+}
+
+// AllowImages returns how many of the requested images fit the budget. With
+// on_exceed "downgrade" the count is lowered to fit; it is an error if not
+// even one image fits, or with "abort" if any would have to be dropped.
+func (g *Guard) AllowImages(model string, requested int) (int, error) {
+	run, day, err := g.ledger.Spent(g.runStart)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check budget: %w", err)
+	}
+
+	price := g.cfg.ImagePrices[model]
+	overErr := g.check(fmt.Sprintf("%d images", requested), price*float64(requested), run, day)
+	if overErr == nil {
+		return requested, nil
+	}
+	if g.cfg.Budget.OnExceed != "downgrade" {
+		return 0, overErr
+	}
+
+	n := requested
+	for n > 0 && g.check("images", price*float64(n), run, day) != nil {
+		n--
+	}
+	if n == 0 {
+		return 0, overErr
+	}
+	g.logger.Warn("budget: generating fewer images", "images", n, "requested", requested)
+	return n, nil
+}
+
+// check returns an error naming the cap that cost would break.
+func (g *Guard) check(what string, cost, run, day float64) error {
+	budget := g.cfg.Budget
+	if budget.PerRunCap > 0 && run+cost > budget.PerRunCap {
+		return fmt.Errorf("%w: %s estimated at $%.4f would take this run's spend to $%.2f, over the $%.2f per-run cap",
+			ErrBudgetExceeded, what, cost, run+cost, budget.PerRunCap)
+	}
+	if budget.PerDayCap > 0 && day+cost > budget.PerDayCap {
+		return fmt.Errorf("%w: %s estimated at $%.4f would take today's spend to $%.2f, over the $%.2f per-day cap",
+			ErrBudgetExceeded, what, cost, day+cost, budget.PerDayCap)
+	}
+	return nil
+}
+
+// estimateLLM is the most a call can cost: every prompt token uncached and
+// the full max_tokens generated. An unpriced model is assumed free, as in
+// the ledger.
+func (g *Guard) estimateLLM(model string, inputTokens, maxOutputTokens int) float64 {
+	price := g.cfg.ModelPrices[model]
+	cost := (float64(inputTokens)*price.Input + float64(maxOutputTokens)*price.Output) / 1e6
+	return math.Round(cost*1e6) / 1e6
+}
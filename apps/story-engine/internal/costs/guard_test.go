@@ -0,0 +1,120 @@
+package costs
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+)
+
+// newTestGuard returns a guard whose run starts at noon, with $0.50 spent
+// so far in the run and $1.50 today.
+func newTestGuard(t *testing.T, budget config.BudgetConfig) *Guard {
+	t.Helper()
+	noon := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cfg := config.CostTrackingConfig{
+		LogFile: filepath.Join(t.TempDir(), "costs.json"),
+		Budget:  budget,
+		ModelPrices: map[string]config.ModelPrice{
+			"primary": {Input: 3, Output: 15},
+			"fast":    {Input: 1, Output: 5},
+		},
+		ImagePrices: map[string]float64{"flux": 0.10},
+	}
+	entries := []Entry{
+		{Time: noon.AddDate(0, 0, -1), Kind: KindLLM, Agent: "story_writer", Model: "primary", CostUSD: 10},
+		{Time: noon.Add(-4 * time.Hour), Kind: KindLLM, Agent: "story_writer", Model: "primary", CostUSD: 1},
+		{Time: noon, Kind: KindLLM, Agent: "canon_checker", Model: "fast", CostUSD: 0.5},
+	}
+	for _, e := range entries {
+		if err := appendEntry(cfg.LogFile, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ledger := NewLedger(cfg, time.UTC, nil)
+	ledger.now = func() time.Time { return noon }
+	return NewGuard(cfg, "fast", ledger)
+}
+
+func TestGuardPreflight(t *testing.T) {
+	// 100k tokens in and up to 10k out: $0.45 on primary, $0.15 on fast
+	estimate := agents.Estimate{Agent: "story_writer", Model: "primary", InputTokens: 100_000, MaxOutputTokens: 10_000}
+
+	tests := []struct {
+		name      string
+		budget    config.BudgetConfig
+		model     string
+		wantModel string
+		wantErr   string
+	}{
+		{name: "no caps", wantModel: "primary"},
+		{name: "under run cap", budget: config.BudgetConfig{PerRunCap: 1, OnExceed: "downgrade"}, wantModel: "primary"},
+		{name: "run cap downgrades", budget: config.BudgetConfig{PerRunCap: 0.9, OnExceed: "downgrade"}, wantModel: "fast"},
+		{name: "run cap aborts", budget: config.BudgetConfig{PerRunCap: 0.9, OnExceed: "abort"}, wantErr: "per-run cap"},
+		{name: "fast model over run cap", budget: config.BudgetConfig{PerRunCap: 0.6, OnExceed: "downgrade"}, wantErr: "per-run cap"},
+		{name: "day cap downgrades", budget: config.BudgetConfig{PerDayCap: 1.8, OnExceed: "downgrade"}, wantModel: "fast"},
+		{name: "day cap aborts", budget: config.BudgetConfig{PerDayCap: 1.6, OnExceed: "abort"}, wantErr: "per-day cap"},
+		{name: "already on fast model", budget: config.BudgetConfig{PerRunCap: 0.6, OnExceed: "downgrade"}, model: "fast", wantErr: "per-run cap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := estimate
+			if tt.model != "" {
+				e.Model = tt.model
+			}
+			model, err := newTestGuard(t, tt.budget).Preflight(e)
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Preflight error = %v, want ErrBudgetExceeded naming the %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Preflight: %v", err)
+			}
+			if model != tt.wantModel {
+				t.Errorf("Preflight model = %s, want %s", model, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestGuardAllowImages(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  config.BudgetConfig
+		want    int
+		wantErr bool
+	}{
+		{name: "no caps", want: 4},
+		{name: "fits", budget: config.BudgetConfig{PerRunCap: 1, OnExceed: "downgrade"}, want: 4},
+		{name: "downgrade to fewer", budget: config.BudgetConfig{PerRunCap: 0.75, OnExceed: "downgrade"}, want: 2},
+		{name: "abort", budget: config.BudgetConfig{PerRunCap: 0.75, OnExceed: "abort"}, wantErr: true},
+		{name: "not even one", budget: config.BudgetConfig{PerRunCap: 0.55, OnExceed: "downgrade"}, wantErr: true},
+		{name: "day cap", budget: config.BudgetConfig{PerDayCap: 1.85, OnExceed: "downgrade"}, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := newTestGuard(t, tt.budget).AllowImages("flux", 4)
+			if tt.wantErr {
+				if !errors.Is(err, ErrBudgetExceeded) {
+					t.Fatalf("AllowImages error = %v, want ErrBudgetExceeded", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AllowImages: %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("AllowImages = %d, want %d", n, tt.want)
+			}
+		})
+	}
+}
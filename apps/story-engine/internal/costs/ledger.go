@@ -0,0 +1,490 @@
+// Package costs records what each API call costs.
+//
+// Every LLM and image generation call is appended to the ledger file
+// (monitoring.cost_tracking.log_file) as one JSON line with its token counts
+// and price. When
+// a day's spend crosses daily_alert_threshold, the error alert recipients
+// are emailed once for that day.
+
+package costs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+)
+
+// Entry kinds.
+const (
+	KindLLM   = "llm"
+	KindImage = "image"
+)
+
+// Entry is one billed call.
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Kind  string    `json:"kind"`
+	Agent string    `json:"agent"`
+	Model string    `json:"model"`
+
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+	// ThinkingTokens is an estimate of the output tokens spent on extended
+	// thinking. They are billed as output and already in OutputTokens.
+	ThinkingTokens   int `json:"thinking_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+
+	Images int `json:"images,omitempty"`
+
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// Alerter sends an alert to the people who look after the engine.
+type Alerter interface {
+	Alert(ctx context.Context, subject, body string) error
+}
+
+// Ledger appends entries to the cost log and raises the daily alert.
+type Ledger struct {
+	mu      sync.Mutex
+	cfg     config.CostTrackingConfig
+	loc     *time.Location
+	alerter Alerter
+	logger  *slog.Logger
+	now     func() time.Time
+
+	// entries is the log as read so far and offset the size it was read
+	// at, so each call only reads what was appended since, by this process
+	// or another.
+	entries []Entry
+	offset  int64
+}
+
+// NewLedger creates a ledger. Days are counted in loc, the pipeline's
+// timezone. alerter may be nil to only log threshold breaches.
+func NewLedger(cfg config.CostTrackingConfig, loc *time.Location, alerter Alerter) *Ledger {
+	return &Ledger{cfg: cfg, loc: loc, alerter: alerter, logger: slog.Default(), now: time.Now}
+}
+
+// SetLogger sets the logger for missing prices, failed writes and alerts,
+// normally the run's. Call it before recording.
+func (l *Ledger) SetLogger(logger *slog.Logger) {
+	l.logger = logger
+}
+
+// RecordUsage records an LLM call. It implements agents.UsageRecorder;
+// failures are logged rather than returned so accounting never breaks a run.
+// Models on a local Ollama server are free and need no price.
+func (l *Ledger) RecordUsage(agent, model string, usage agents.Usage, thinkingTokens int) {
+	if _, ok := l.cfg.ModelPrices[model]; !ok {
+		if provider, _, _ := config.ParseModel(model); provider != config.ProviderOllama {
+			l.logger.Warn("no price configured for model, recording its cost as 0", "model", model)
+		}
+	}
+	cost := LLMCost(l.cfg, model, usage)
+
+	l.record(Entry{
+		Kind:             KindLLM,
+		Agent:            agent,
+		Model:            model,
+		InputTokens:      usage.InputTokens,
+		OutputTokens:     usage.OutputTokens,
+		ThinkingTokens:   thinkingTokens,
+		CacheWriteTokens: usage.CacheCreationInputTokens,
+		CacheReadTokens:  usage.CacheReadInputTokens,
+		CostUSD:          cost,
+	})
+}
+
+// LLMCost prices an LLM call from model_prices. A model without a price
+// costs 0.
+func LLMCost(cfg config.CostTrackingConfig, model string, usage agents.Usage) float64 {
+	price := cfg.ModelPrices[model]
+	return (float64(usage.InputTokens)*price.Input +
+		float64(usage.OutputTokens)*price.Output +
+		float64(usage.CacheCreationInputTokens)*price.CacheWrite +
+		float64(usage.CacheReadInputTokens)*price.CacheRead) / 1e6
+}
+
+// RecordImages records generated images at the configured per-image price.
+func (l *Ledger) RecordImages(agent, model string, count int) {
+	price, ok := l.cfg.ImagePrices[model]
+	if !ok {
+		l.logger.Warn("no price configured for image model, recording its cost as 0", "model", model)
+	}
+	l.record(Entry{
+		Kind:    KindImage,
+		Agent:   agent,
+		Model:   model,
+		Images:  count,
+		CostUSD: price * float64(count),
+	})
+}
+
+func (l *Ledger) record(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Time = l.now().UTC()
+	if err := l.refresh(); err != nil {
+		l.logger.Error("failed to record cost", "error", err)
+		return
+	}
+	day := l.dayOf(e.Time)
+	before := total(l.entriesOf(day))
+
+	if err := appendEntry(l.cfg.LogFile, e); err != nil {
+		l.logger.Error("failed to record cost", "error", err)
+		return
+	}
+
+	after := before + e.CostUSD
+	threshold := l.cfg.DailyAlertThreshold
+	if threshold > 0 && before < threshold && after >= threshold {
+		l.alert(day, after, append(l.entriesOf(day), e))
+	}
+}
+
+// refresh reads the entries appended to the log since the last call. A log
+// that shrank was replaced, and is read again from the start.
+func (l *Ledger) refresh() error {
+	if l.offset == 0 {
+		if err := migrate(l.cfg.LogFile); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(l.cfg.LogFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		l.entries, l.offset = nil, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", l.cfg.LogFile, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", l.cfg.LogFile, err)
+	}
+	if info.Size() < l.offset {
+		l.entries, l.offset = nil, 0
+	}
+	if info.Size() == l.offset {
+		return nil
+	}
+	if _, err := f.Seek(l.offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read %s: %w", l.cfg.LogFile, err)
+	}
+	entries, n, err := decodeEntries(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", l.cfg.LogFile, err)
+	}
+	l.entries = append(l.entries, entries...)
+	l.offset += n
+	return nil
+}
+
+// entriesOf returns the entries recorded on day. Entries are appended in
+// time order, so only the tail of the log is looked at.
+func (l *Ledger) entriesOf(day string) []Entry {
+	i := len(l.entries)
+	for i > 0 && l.dayOf(l.entries[i-1].Time) >= day {
+		i--
+	}
+	var out []Entry
+	for _, e := range l.entries[i:] {
+		if l.dayOf(e.Time) == day {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func total(entries []Entry) float64 {
+	sum := 0.0
+	for _, e := range entries {
+		sum += e.CostUSD
+	}
+	return sum
+}
+
+// alert reports the day's spend by agent.
+func (l *Ledger) alert(day string, spend float64, today []Entry) {
+	subject := fmt.Sprintf("Story engine spend $%.2f on %s crossed the $%.2f daily threshold", spend, day, l.cfg.DailyAlertThreshold)
+	var body strings.Builder
+	body.WriteString(subject + "\n\n")
+	for _, a := range Summarize(today).Agents {
+		fmt.Fprintf(&body, "%-24s %4d calls  $%.4f\n", a.Agent, a.Calls, a.CostUSD)
+	}
+
+	l.logger.Warn(subject)
+	if l.alerter == nil {
+		return
+	}
+	ctx := logging.WithLogger(context.Background(), l.logger)
+	if err := l.alerter.Alert(ctx, subject, body.String()); err != nil {
+		l.logger.Error("failed to send cost alert", "error", err)
+	}
+}
+
+// Spent returns the spend recorded at or after since, and the spend of the
+// current day.
+func (l *Ledger) Spent(since time.Time) (float64, float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.refresh(); err != nil {
+		return 0, 0, err
+	}
+	var sinceTotal float64
+	for i := len(l.entries) - 1; i >= 0 && !l.entries[i].Time.Before(since); i-- {
+		sinceTotal += l.entries[i].CostUSD
+	}
+	return sinceTotal, total(l.entriesOf(l.dayOf(l.now()))), nil
+}
+
+func (l *Ledger) dayOf(t time.Time) string {
+	return t.In(l.loc).Format("2006-01-02")
+}
+
+// Load reads the cost log. A missing file is an empty ledger.
+func Load(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if entries, ok, err := decodeArray(data); ok {
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return entries, nil
+	}
+	entries, _, err := decodeEntries(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// decodeArray reads a log written before entries were appended as lines,
+// which holds a single JSON array. It reports whether data is one.
+func decodeArray(data []byte) ([]Entry, bool, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		return nil, false, nil
+	}
+	var entries []Entry
+	err := json.Unmarshal(data, &entries)
+	return entries, true, err
+}
+
+// decodeEntries reads JSON lines of entries from r and returns them with the
+// number of bytes they took. A line still being written is left for the
+// next read.
+func decodeEntries(r io.Reader) ([]Entry, int64, error) {
+	var entries []Entry
+	var read int64
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return entries, read, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var e Entry
+			if err := json.Unmarshal(trimmed, &e); err != nil {
+				return nil, 0, fmt.Errorf("invalid entry at byte %d: %w", read, err)
+			}
+			entries = append(entries, e)
+		}
+		read += int64(len(line))
+	}
+}
+
+// migrate rewrites a log that holds a single JSON array as JSON lines, so
+// entries can be appended to it. Other logs are left alone.
+func migrate(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	entries, ok, err := decodeArray(data)
+	if !ok {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode cost entry: %w", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// appendEntry appends e to the log at path as one JSON line. The line is a
+// single write to a file opened for appending, so entries from concurrent
+// processes are not interleaved.
+func appendEntry(path string, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode cost entry: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// AgentSummary is the spend of one agent over a period.
+type AgentSummary struct {
+	Agent            string `json:"agent"`
+	Calls            int    `json:"calls"`
+	InputTokens      int    `json:"input_tokens"`
+	OutputTokens     int    `json:"output_tokens"`
+	ThinkingTokens   int    `json:"thinking_tokens"`
+	CacheWriteTokens int    `json:"cache_write_tokens"`
+	CacheReadTokens  int    `json:"cache_read_tokens"`
+	// CacheHitRate is the share of prompt tokens read from the cache.
+	CacheHitRate float64 `json:"cache_hit_rate"`
+	Images       int     `json:"images"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// Summary is the spend over a period, most expensive agent first.
+type Summary struct {
+	Agents          []AgentSummary `json:"agents"`
+	CacheReadTokens int            `json:"cache_read_tokens"`
+	CacheHitRate    float64        `json:"cache_hit_rate"`
+	TotalUSD        float64        `json:"total_usd"`
+}
+
+// Summarize totals entries by agent.
+func Summarize(entries []Entry) Summary {
+	byAgent := make(map[string]*AgentSummary)
+	var s Summary
+	var prompt int
+	for _, e := range entries {
+		a, ok := byAgent[e.Agent]
+		if !ok {
+			a = &AgentSummary{Agent: e.Agent}
+			byAgent[e.Agent] = a
+		}
+		a.Calls++
+		a.InputTokens += e.InputTokens
+		a.OutputTokens += e.OutputTokens
+		a.ThinkingTokens += e.ThinkingTokens
+		a.CacheWriteTokens += e.CacheWriteTokens
+		a.CacheReadTokens += e.CacheReadTokens
+		a.Images += e.Images
+		a.CostUSD += e.CostUSD
+		s.CacheReadTokens += e.CacheReadTokens
+		prompt += e.InputTokens + e.CacheWriteTokens + e.CacheReadTokens
+		s.TotalUSD += e.CostUSD
+	}
+	s.CacheHitRate = hitRate(s.CacheReadTokens, prompt)
+
+	s.Agents = []AgentSummary{}
+	for _, a := range byAgent {
+		a.CacheHitRate = hitRate(a.CacheReadTokens, a.InputTokens+a.CacheWriteTokens+a.CacheReadTokens)
+		s.Agents = append(s.Agents, *a)
+	}
+	sort.Slice(s.Agents, func(i, j int) bool {
+		if s.Agents[i].CostUSD != s.Agents[j].CostUSD {
+			return s.Agents[i].CostUSD > s.Agents[j].CostUSD
+		}
+		return s.Agents[i].Agent < s.Agents[j].Agent
+	})
+	return s
+}
+
+// hitRate is the share of prompt tokens that were cache reads. Anthropic
+// counts cache reads and writes separately from input tokens.
+func hitRate(read, prompt int) float64 {
+	if prompt == 0 {
+		return 0
+	}
+	return math.Round(float64(read)/float64(prompt)*1000) / 1000
+}
+
+// Since returns the entries at or after t.
+func Since(entries []Entry, t time.Time) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if !e.Time.Before(t) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ParseSince parses a lookback such as "30d", "2w", "12h" or a date
+// "2026-01-02" into the start time it refers to.
+func ParseSince(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, nil
+	}
+
+	var n int
+	var unit string
+	if _, err := fmt.Sscanf(s, "%d%s", &n, &unit); err == nil && n >= 0 {
+		switch unit {
+		case "d":
+			return now.AddDate(0, 0, -n), nil
+		case "w":
+			return now.AddDate(0, 0, -7*n), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q, want e.g. 30d, 2w, 12h or 2026-01-02", s)
+}
@@ -0,0 +1,132 @@
+package costs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+type recordingAlerter struct {
+	subjects []string
+}
+
+func (a *recordingAlerter) Alert(_ context.Context, subject, _ string) error {
+	a.subjects = append(a.subjects, subject)
+	return nil
+}
+
+func newTestLedger(path string, now *time.Time, alerter Alerter) *Ledger {
+	cfg := config.CostTrackingConfig{
+		LogFile:             path,
+		DailyAlertThreshold: 1,
+		ImagePrices:         map[string]float64{"flux": 0.25},
+	}
+	ledger := NewLedger(cfg, time.UTC, alerter)
+	ledger.now = func() time.Time { return *now }
+	return ledger
+}
+
+func TestLedgerAppendsLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics", "costs.json")
+	now := time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)
+	alerter := &recordingAlerter{}
+	ledger := newTestLedger(path, &now, alerter)
+	// Another process recording to the same log
+	other := newTestLedger(path, &now, nil)
+
+	ledger.RecordImages("image_generator", "flux", 2)
+	other.RecordImages("image_generator", "flux", 1)
+	ledger.RecordImages("image_generator", "flux", 1)
+	ledger.RecordImages("image_generator", "flux", 1)
+	now = now.Add(2 * time.Hour)
+	ledger.RecordImages("image_generator", "flux", 1)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); len(lines) != 5 {
+		t.Errorf("log has %d lines, want one per call:\n%s", len(lines), data)
+	}
+
+	// $0.50, $0.25 from the other ledger, then $0.25 crosses $1 once
+	if len(alerter.subjects) != 1 || !strings.Contains(alerter.subjects[0], "$1.00 on 2026-10-19") {
+		t.Errorf("alerts = %q, want one for 2026-10-19", alerter.subjects)
+	}
+
+	run, day, err := ledger.Spent(now.Add(-3 * time.Hour))
+	if err != nil {
+		t.Fatalf("Spent: %v", err)
+	}
+	if run != 1.5 || day != 0.25 {
+		t.Errorf("Spent = %v, %v, want 1.5, 0.25", run, day)
+	}
+	other.RecordImages("image_generator", "flux", 4)
+	if _, day, _ := ledger.Spent(now); day != 1.25 {
+		t.Errorf("Spent today after another process recorded = %v, want 1.25", day)
+	}
+
+	entries, err := Load(path)
+	if err != nil || len(entries) != 6 {
+		t.Errorf("Load = %d entries, %v, want 6", len(entries), err)
+	}
+}
+
+func TestLedgerPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.json")
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	ledger := newTestLedger(path, &now, nil)
+	ledger.RecordImages("image_generator", "flux", 1)
+
+	// A line another process is still writing is read once it is complete
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(`{"time":"2026-10-19T11:00:00Z","kind":"image",`); err != nil {
+		t.Fatal(err)
+	}
+	if _, day, err := ledger.Spent(now); err != nil || day != 0.25 {
+		t.Errorf("Spent with a partial line = %v, %v, want 0.25", day, err)
+	}
+	if _, err := f.WriteString(`"cost_usd":0.5}` + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, day, err := ledger.Spent(now); err != nil || day != 0.75 {
+		t.Errorf("Spent once the line is complete = %v, %v, want 0.75", day, err)
+	}
+}
+
+func TestLedgerMigratesArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "costs.json")
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	old := []Entry{
+		{Time: now.AddDate(0, 0, -1), Kind: KindImage, Agent: "image_generator", Model: "flux", Images: 4, CostUSD: 1},
+		{Time: now.Add(-time.Hour), Kind: KindImage, Agent: "image_generator", Model: "flux", Images: 1, CostUSD: 0.25},
+	}
+	if err := storage.WriteJSON(path, old); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Load(path)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Load of an array ledger = %d entries, %v, want 2", len(entries), err)
+	}
+
+	ledger := newTestLedger(path, &now, nil)
+	ledger.RecordImages("image_generator", "flux", 1)
+	entries, err = Load(path)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Load after recording = %d entries, %v, want 3", len(entries), err)
+	}
+	if _, day, err := ledger.Spent(now); err != nil || day != 0.5 {
+		t.Errorf("Spent today = %v, %v, want 0.5", day, err)
+	}
+}
@@ -0,0 +1,133 @@
+// Package email sends the engine's emails: the daily report and error
+// alerts, through SMTP or SendGrid as configured under email.
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+)
+
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// Mailer sends plain-text email.
+type Mailer struct {
+	cfg  config.EmailConfig
+	http *http.Client
+}
+
+// NewMailer creates a mailer for the email configuration.
+func NewMailer(cfg config.EmailConfig) *Mailer {
+	return &Mailer{cfg: cfg, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Alert emails the error alert recipients. With email disabled or no
+// recipients configured the alert is only logged.
+func (m *Mailer) Alert(ctx context.Context, subject, body string) error {
+	if !m.cfg.Enabled || len(m.cfg.Recipients.ErrorAlerts) == 0 {
+		logging.FromContext(ctx).Warn("alert not emailed: email is disabled or has no recipients", "subject", subject)
+		return nil
+	}
+	return m.Send(ctx, m.cfg.Recipients.ErrorAlerts, subject, body)
+}
+
+// Report emails the daily report recipient. With email disabled or no
+// recipient configured the report is only logged.
+func (m *Mailer) Report(ctx context.Context, subject, body string) error {
+	if !m.cfg.Enabled || m.cfg.Recipients.DailyReport == "" {
+		logging.FromContext(ctx).Info("daily report not emailed: email is disabled or has no recipient", "subject", subject)
+		return nil
+	}
+	return m.Send(ctx, []string{m.cfg.Recipients.DailyReport}, subject, body)
+}
+
+// Send emails a plain-text message to the given recipients.
+func (m *Mailer) Send(ctx context.Context, to []string, subject, body string) error {
+	// Header values must not contain line breaks
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	var err error
+	switch m.cfg.Provider {
+	case "smtp":
+		err = m.sendSMTP(to, subject, body)
+	case "sendgrid":
+		err = m.sendSendGrid(ctx, to, subject, body)
+	default:
+		err = fmt.Errorf("unknown email provider %q", m.cfg.Provider)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send email %q: %w", subject, err)
+	}
+	return nil
+}
+
+func (m *Mailer) sendSMTP(to []string, subject, body string) error {
+	smtpCfg := m.cfg.SMTP
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s <%s>\r\n", m.cfg.FromName, m.cfg.FromAddress)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	addr := smtpCfg.Host + ":" + strconv.Itoa(smtpCfg.Port)
+	auth := smtp.PlainAuth("", smtpCfg.User, smtpCfg.Password, smtpCfg.Host)
+	return smtp.SendMail(addr, auth, m.cfg.FromAddress, to, msg.Bytes())
+}
+
+func (m *Mailer) sendSendGrid(ctx context.Context, to []string, subject, body string) error {
+	type address struct {
+		Email string `json:"email"`
+		Name  string `json:"name,omitempty"`
+	}
+	var recipients []address
+	for _, addr := range to {
+		recipients = append(recipients, address{Email: addr})
+	}
+
+	fromName := m.cfg.SendGrid.FromName
+	if fromName == "" {
+		fromName = m.cfg.FromName
+	}
+	payload, err := json.Marshal(map[string]any{
+		"personalizations": []map[string]any{{"to": recipients}},
+		"from":             address{Email: m.cfg.FromAddress, Name: fromName},
+		"subject":          subject,
+		"content":          []map[string]string{{"type": "text/plain", "value": body}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.cfg.SendGrid.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("sendgrid returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
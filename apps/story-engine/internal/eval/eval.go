@@ -0,0 +1,261 @@
+// Package eval runs agents offline over the inputs of past runs and scores
+// their output next to what those runs produced.
+//
+// A fixture is a past run. Its last checkpoint holds the chapter and the
+// hashtags the run produced, which are the baseline, and its manifest
+// records the model each agent ran with. Each selected agent runs on the
+// fixture with the current prompts, on the recorded model or an alternate
+// one, and the baseline and the new output are scored by the same checks.
+// Nothing is written to the story's data, so prompts can be changed without
+// touching the live story.
+//
+// The comment filter is not evaluated: the pipeline has no comment filter
+// agent yet and runs don't record the comments they read.
+
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/hashtags"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/pipeline"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// Agents eval can run.
+const (
+	AgentWriter   = "story_writer"
+	AgentPolisher = "prose_polisher"
+	AgentHashtags = "hashtag_generator"
+)
+
+// Agents returns the agents eval can run, in report order.
+func Agents() []string {
+	return []string{AgentWriter, AgentPolisher, AgentHashtags}
+}
+
+// CheckAgents returns an error naming any agent eval can't run.
+func CheckAgents(names []string) error {
+	for _, name := range names {
+		switch name {
+		case AgentWriter, AgentPolisher, AgentHashtags:
+		default:
+			return fmt.Errorf("agent %q can't be evaluated, want one of %v", name, Agents())
+		}
+	}
+	return nil
+}
+
+// Fixture is a past run used as input.
+type Fixture struct {
+	RunID      string
+	Checkpoint *pipeline.Checkpoint
+	// Provenance is nil for runs from before it was recorded.
+	Provenance *models.Provenance
+}
+
+// LoadFixtures loads the runs with ids runIDs, or with none given, the
+// latest limit runs that checkpointed a chapter.
+func LoadFixtures(cfg *config.Config, runIDs []string, limit int) ([]Fixture, error) {
+	store := storage.New(cfg.Paths)
+	explicit := len(runIDs) > 0
+	if !explicit {
+		entries, err := os.ReadDir(store.DataPath(cfg.Paths.RunsDir))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list runs: %w", err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				runIDs = append(runIDs, e.Name())
+			}
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(runIDs)))
+	}
+
+	var fixtures []Fixture
+	for _, id := range runIDs {
+		if !explicit && len(fixtures) == limit {
+			break
+		}
+		// Run ids are dates; anything else is not a run directory
+		if _, err := time.Parse(pipeline.RunDateFormat, id); err != nil {
+			if explicit {
+				return nil, fmt.Errorf("invalid run id %q, want a date like 2026-01-02", id)
+			}
+			continue
+		}
+
+		dir := store.DataPath(cfg.Paths.RunsDir, id)
+		checkpoint, err := pipeline.LoadLatestCheckpoint(dir)
+		if err == nil && checkpoint.Chapter == nil {
+			err = fmt.Errorf("run %s checkpointed no chapter: %w", id, fs.ErrNotExist)
+		}
+		if err != nil {
+			if !explicit && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to load run %s: %w", id, err)
+		}
+
+		fixture := Fixture{RunID: id, Checkpoint: checkpoint}
+		if m, err := pipeline.LoadManifest(dir); err == nil {
+			fixture.Provenance = m.Provenance
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to load run %s: %w", id, err)
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("no runs with a checkpointed chapter found")
+	}
+	return fixtures, nil
+}
+
+// Output is what an agent produced for a fixture.
+type Output struct {
+	Chapter  *models.Chapter `json:"chapter,omitempty"`
+	Hashtags []string        `json:"hashtags,omitempty"`
+}
+
+// Case is one agent run on one fixture, next to the fixture's baseline.
+type Case struct {
+	RunID     string `json:"run_id"`
+	Agent     string `json:"agent"`
+	Model     string `json:"model"`
+	Baseline  Output `json:"baseline"`
+	Candidate Output `json:"candidate"`
+
+	BaselineScore  Score `json:"baseline_score"`
+	CandidateScore Score `json:"candidate_score"`
+
+	// Error is why the agent produced no candidate.
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the result of an eval.
+type Report struct {
+	Created time.Time `json:"created"`
+	// Model is the alternate model, empty when the recorded ones were used.
+	Model string `json:"model,omitempty"`
+	Cases []Case `json:"cases"`
+}
+
+// Runner runs agents over fixtures.
+type Runner struct {
+	cfg    *config.Config
+	client *agents.Client
+	bible  *models.StoryBible
+	// model, when set, replaces the recorded model of every agent.
+	model string
+}
+
+// NewRunner creates a runner. Hashtags are generated against bible, the
+// current story bible. model, when set, is used for every agent instead of
+// the one each fixture recorded.
+func NewRunner(cfg *config.Config, client *agents.Client, bible *models.StoryBible, model string) *Runner {
+	return &Runner{cfg: cfg, client: client, bible: bible, model: model}
+}
+
+// Run runs each agent over each fixture. A failed agent call is recorded
+// in its case and the eval carries on.
+func (r *Runner) Run(ctx context.Context, fixtures []Fixture, agentNames []string) (*Report, error) {
+	if err := CheckAgents(agentNames); err != nil {
+		return nil, err
+	}
+
+	report := &Report{Created: time.Now().UTC(), Model: r.model}
+	for _, fx := range fixtures {
+		for _, name := range agentNames {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+			report.Cases = append(report.Cases, r.runCase(ctx, fx, name))
+		}
+	}
+	return report, nil
+}
+
+func (r *Runner) runCase(ctx context.Context, fx Fixture, name string) Case {
+	cfg := r.configFor(fx, name)
+	c := Case{RunID: fx.RunID, Agent: name, Model: cfg.AgentModel(cfg.Agents[name])}
+
+	chapter := fx.Checkpoint.Chapter
+	if name == AgentHashtags {
+		c.Baseline.Hashtags = fx.Checkpoint.Hashtags
+		c.BaselineScore = ScoreHashtags(c.Baseline.Hashtags, cfg.Pipeline.Hashtags)
+	} else {
+		c.Baseline.Chapter = chapter
+		c.BaselineScore = ScoreChapter(chapter, cfg.Pipeline.Story)
+	}
+
+	var err error
+	switch name {
+	case AgentWriter:
+		// The writer revises the baseline against its own failed checks,
+		// as it does after a failed canon check
+		var writer *agents.StoryWriter
+		if writer, err = agents.NewStoryWriter(r.client, cfg); err == nil {
+			feedback := c.BaselineScore.Failed()
+			if len(feedback) == 0 {
+				feedback = []string{"Tighten the prose against the craft checklist without changing the plot."}
+			}
+			c.Candidate.Chapter, err = writer.Revise(ctx, chapter, feedback)
+		}
+	case AgentPolisher:
+		var polisher *agents.ProsePolisher
+		if polisher, err = agents.NewProsePolisher(r.client, cfg); err == nil {
+			c.Candidate.Chapter, err = polisher.Polish(ctx, chapter)
+		}
+	case AgentHashtags:
+		var generator *agents.HashtagGenerator
+		if generator, err = agents.NewHashtagGenerator(r.client, cfg); err == nil {
+			c.Candidate.Hashtags, err = pipeline.SelectHashtags(ctx, cfg.Pipeline.Hashtags, generator, r.bible, chapter)
+			// Too few tags still get posted; the count check reports it
+			var tooFew *hashtags.ErrTooFew
+			if errors.As(err, &tooFew) {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		c.Error = err.Error()
+	}
+
+	if name == AgentHashtags {
+		c.CandidateScore = ScoreHashtags(c.Candidate.Hashtags, cfg.Pipeline.Hashtags)
+	} else if c.Candidate.Chapter != nil {
+		c.CandidateScore = ScoreChapter(c.Candidate.Chapter, cfg.Pipeline.Story)
+	}
+	return c
+}
+
+// configFor returns the config with agent name set to run on the alternate
+// model, or else the model the fixture recorded for it.
+func (r *Runner) configFor(fx Fixture, name string) *config.Config {
+	model := r.model
+	if model == "" && fx.Provenance != nil {
+		model = fx.Provenance.Agents[name].Model
+	}
+	if model == "" {
+		return r.cfg
+	}
+
+	cfg := *r.cfg
+	cfg.Agents = make(config.AgentsConfig, len(r.cfg.Agents))
+	for k, v := range r.cfg.Agents {
+		cfg.Agents[k] = v
+	}
+	agent := cfg.Agents[name]
+	agent.Model = model
+	cfg.Agents[name] = agent
+	return &cfg
+}
@@ -0,0 +1,159 @@
+package eval
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/pipeline"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// writeRuns builds a runs directory in a temp dir:
+//
+//	2026-01-01  a chapter, no manifest
+//	2026-01-02  a chapter in its second checkpoint, with a manifest
+//	2026-01-03  checkpoints without a chapter
+//	2026-01-04  no checkpoints
+//	notes       not a run
+func writeRuns(t *testing.T) *config.Config {
+	t.Helper()
+	cfg := &config.Config{Paths: config.PathsConfig{DataDir: t.TempDir(), RunsDir: "runs"}}
+	runs := filepath.Join(cfg.Paths.DataDir, "runs")
+	write := func(path string, v any) {
+		t.Helper()
+		if err := storage.WriteJSON(filepath.Join(runs, path), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("2026-01-01/checkpoints/01_write.json", pipeline.Checkpoint{Stage: "write", Chapter: &models.Chapter{ChapterNumber: 1}})
+	write("2026-01-02/checkpoints/01_write.json", pipeline.Checkpoint{Stage: "write", Chapter: &models.Chapter{ChapterNumber: 2, Text: "draft"}})
+	write("2026-01-02/checkpoints/02_hashtags.json", pipeline.Checkpoint{
+		Stage:    "hashtags",
+		Chapter:  &models.Chapter{ChapterNumber: 2, Text: "polished"},
+		Hashtags: []string{"#thornwood"},
+	})
+	write("2026-01-02/"+pipeline.ManifestFile, pipeline.Manifest{
+		RunID:      "2026-01-02",
+		Provenance: &models.Provenance{Agents: map[string]models.AgentProvenance{AgentWriter: {Model: "claude-opus-4-1"}}},
+	})
+	write("2026-01-03/checkpoints/01_plan.json", pipeline.Checkpoint{Stage: "plan"})
+	write("2026-01-04/notes.json", map[string]string{})
+	write("notes/todo.json", map[string]string{})
+	return cfg
+}
+
+func TestLoadFixtures(t *testing.T) {
+	cfg := writeRuns(t)
+
+	tests := []struct {
+		name    string
+		runIDs  []string
+		limit   int
+		want    []string
+		wantErr string
+	}{
+		{name: "latest with a chapter", limit: 5, want: []string{"2026-01-02", "2026-01-01"}},
+		{name: "limit", limit: 1, want: []string{"2026-01-02"}},
+		{name: "explicit", runIDs: []string{"2026-01-01"}, want: []string{"2026-01-01"}},
+		{name: "explicit without a chapter", runIDs: []string{"2026-01-03"}, wantErr: "run 2026-01-03 checkpointed no chapter"},
+		{name: "explicit without checkpoints", runIDs: []string{"2026-01-04"}, wantErr: "failed to load run 2026-01-04"},
+		{name: "explicit missing", runIDs: []string{"2026-02-01"}, wantErr: "failed to load run 2026-02-01"},
+		{name: "invalid id", runIDs: []string{"../2026-01-01"}, wantErr: "invalid run id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixtures, err := LoadFixtures(cfg, tt.runIDs, tt.limit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadFixtures = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadFixtures: %v", err)
+			}
+			var got []string
+			for _, fx := range fixtures {
+				got = append(got, fx.RunID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("runs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFixturesContents(t *testing.T) {
+	cfg := writeRuns(t)
+	fixtures, err := LoadFixtures(cfg, []string{"2026-01-02", "2026-01-01"}, 0)
+	if err != nil {
+		t.Fatalf("LoadFixtures: %v", err)
+	}
+
+	latest := fixtures[0]
+	if latest.Checkpoint.Stage != "hashtags" || latest.Checkpoint.Chapter.Text != "polished" {
+		t.Errorf("checkpoint = %s with %q, want the last stage's chapter", latest.Checkpoint.Stage, latest.Checkpoint.Chapter.Text)
+	}
+	if !slices.Equal(latest.Checkpoint.Hashtags, []string{"#thornwood"}) {
+		t.Errorf("hashtags = %v, want the checkpointed ones", latest.Checkpoint.Hashtags)
+	}
+	if latest.Provenance == nil || latest.Provenance.Agents[AgentWriter].Model != "claude-opus-4-1" {
+		t.Errorf("provenance = %+v, want the manifest's", latest.Provenance)
+	}
+	if fixtures[1].Provenance != nil {
+		t.Errorf("provenance = %+v, want nil for a run without a manifest", fixtures[1].Provenance)
+	}
+}
+
+func TestLoadFixturesNoRuns(t *testing.T) {
+	cfg := writeRuns(t)
+	cfg.Paths.RunsDir = "runs/2026-01-03"
+	if _, err := LoadFixtures(cfg, nil, 5); err == nil || !strings.Contains(err.Error(), "no runs with a checkpointed chapter") {
+		t.Errorf("LoadFixtures = %v, want no runs found", err)
+	}
+}
+
+func TestConfigFor(t *testing.T) {
+	cfg := &config.Config{Agents: config.AgentsConfig{AgentWriter: {Model: "claude-sonnet-4-5"}}}
+	recorded := Fixture{Provenance: &models.Provenance{Agents: map[string]models.AgentProvenance{AgentWriter: {Model: "claude-opus-4-1"}}}}
+
+	tests := []struct {
+		name    string
+		model   string
+		fixture Fixture
+		want    string
+	}{
+		{"configured without provenance", "", Fixture{}, "claude-sonnet-4-5"},
+		{"recorded", "", recorded, "claude-opus-4-1"},
+		{"alternate over recorded", "ollama:llama3.1:8b", recorded, "ollama:llama3.1:8b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRunner(cfg, nil, nil, tt.model)
+			if got := r.configFor(tt.fixture, AgentWriter).Agents[AgentWriter].Model; got != tt.want {
+				t.Errorf("model = %q, want %q", got, tt.want)
+			}
+			if got := cfg.Agents[AgentWriter].Model; got != "claude-sonnet-4-5" {
+				t.Errorf("runner config model = %q, want it unchanged", got)
+			}
+		})
+	}
+}
+
+func TestCheckAgents(t *testing.T) {
+	if err := CheckAgents(Agents()); err != nil {
+		t.Errorf("CheckAgents(%v) = %v", Agents(), err)
+	}
+	for _, name := range []string{"comment_filter", "canon_checker"} {
+		if err := CheckAgents([]string{AgentWriter, name}); err == nil {
+			t.Errorf("CheckAgents(%s) succeeded, want it rejected", name)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package eval
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the report as markdown: a summary table, then each
+// case's checks and the baseline and candidate outputs in turn.
+func WriteMarkdown(w io.Writer, r *Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Eval report\n\n%s", r.Created.Format("2006-01-02 15:04 MST"))
+	if r.Model != "" {
+		fmt.Fprintf(&b, ", all agents on `%s`", r.Model)
+	}
+	b.WriteString("\n\n| Run | Agent | Model | Baseline | Candidate |\n|---|---|---|---|---|\n")
+	for _, c := range r.Cases {
+		fmt.Fprintf(&b, "| %s | %s | `%s` | %s | %s |\n", c.RunID, c.Agent, c.Model, c.BaselineScore, candidateSummary(c))
+	}
+
+	for _, c := range r.Cases {
+		fmt.Fprintf(&b, "\n## %s: %s\n\n", c.RunID, c.Agent)
+		if c.Error != "" {
+			fmt.Fprintf(&b, "**Error:** %s\n\n", markdownLine(c.Error))
+		}
+		b.WriteString("| Check | Baseline | Candidate |\n|---|---|---|\n")
+		for _, row := range checkRows(c) {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", row.Name, markdownLine(row.Baseline), markdownLine(row.Candidate))
+		}
+		b.WriteString("\n### Baseline\n\n")
+		b.WriteString(fence(outputText(c.Baseline)))
+		b.WriteString("\n### Candidate\n\n")
+		b.WriteString(fence(outputText(c.Candidate)))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteHTML writes the report as a standalone HTML page with the baseline
+// and candidate side by side. Model output is escaped.
+func WriteHTML(w io.Writer, r *Report) error {
+	type htmlCase struct {
+		Case
+		Summary             string
+		Rows                []checkRow
+		Baseline, Candidate string
+	}
+	cases := make([]htmlCase, len(r.Cases))
+	for i, c := range r.Cases {
+		cases[i] = htmlCase{
+			Case:      c,
+			Summary:   candidateSummary(c),
+			Rows:      checkRows(c),
+			Baseline:  outputText(c.Baseline),
+			Candidate: outputText(c.Candidate),
+		}
+	}
+	return htmlReport.Execute(w, struct {
+		*Report
+		Cases []htmlCase
+	}{r, cases})
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Eval report</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; }
+table { border-collapse: collapse; margin-bottom: 1rem; }
+td, th { border: 1px solid #ccc; padding: 0.3rem 0.6rem; text-align: left; vertical-align: top; }
+.fail { color: #b00; }
+.side { display: grid; grid-template-columns: 1fr 1fr; gap: 1rem; }
+pre { white-space: pre-wrap; background: #f6f6f6; padding: 0.8rem; }
+</style>
+</head>
+<body>
+<h1>Eval report</h1>
+<p>{{.Created.Format "2006-01-02 15:04 MST"}}{{if .Model}}, all agents on <code>{{.Model}}</code>{{end}}</p>
+<table>
+<tr><th>Run</th><th>Agent</th><th>Model</th><th>Baseline</th><th>Candidate</th></tr>
+{{range .Cases}}<tr><td>{{.RunID}}</td><td>{{.Agent}}</td><td><code>{{.Model}}</code></td><td>{{.BaselineScore}}</td><td>{{.Summary}}</td></tr>
+{{end}}</table>
+{{range .Cases}}
+<h2>{{.RunID}}: {{.Agent}}</h2>
+{{if .Error}}<p class="fail"><strong>Error:</strong> {{.Error}}</p>{{end}}
+<table>
+<tr><th>Check</th><th>Baseline</th><th>Candidate</th></tr>
+{{range .Rows}}<tr><td>{{.Name}}</td><td>{{.Baseline}}</td><td>{{.Candidate}}</td></tr>
+{{end}}</table>
+<div class="side">
+<div><h3>Baseline</h3><pre>{{.Baseline}}</pre></div>
+<div><h3>Candidate</h3><pre>{{.Candidate}}</pre></div>
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// checkRow is one check with its result on both outputs.
+type checkRow struct {
+	Name, Baseline, Candidate string
+}
+
+func checkRows(c Case) []checkRow {
+	var rows []checkRow
+	index := make(map[string]int)
+	for _, check := range c.BaselineScore.Checks {
+		index[check.Name] = len(rows)
+		rows = append(rows, checkRow{Name: check.Name, Baseline: result(check)})
+	}
+	for _, check := range c.CandidateScore.Checks {
+		i, ok := index[check.Name]
+		if !ok {
+			i = len(rows)
+			rows = append(rows, checkRow{Name: check.Name})
+		}
+		rows[i].Candidate = result(check)
+	}
+	return rows
+}
+
+func result(c Check) string {
+	s := "FAIL"
+	if c.Passed {
+		s = "pass"
+	}
+	if c.Detail != "" {
+		s += " (" + c.Detail + ")"
+	}
+	return s
+}
+
+func candidateSummary(c Case) string {
+	if c.Error != "" && len(c.CandidateScore.Checks) == 0 {
+		return "error"
+	}
+	return c.CandidateScore.String()
+}
+
+func outputText(o Output) string {
+	switch {
+	case o.Chapter != nil:
+		return o.Chapter.Title + "\n\n" + o.Chapter.Text
+	case len(o.Hashtags) > 0:
+		return strings.Join(o.Hashtags, " ")
+	}
+	return "(none)"
+}
+
+// markdownLine keeps a value on one table row.
+func markdownLine(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// fence wraps text in a code fence longer than any backtick run inside it,
+// so model output can't end the block early.
+func fence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	f := strings.Repeat("`", max(3, longest+1))
+	return f + "\n" + text + "\n" + f + "\n"
+}
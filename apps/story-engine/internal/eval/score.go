@@ -0,0 +1,137 @@
+package eval
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// Check is one pass/fail test of an output.
+type Check struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Score is the checks an output was held to.
+type Score struct {
+	Checks []Check `json:"checks"`
+	Passed int     `json:"passed"`
+}
+
+func (s *Score) add(name string, passed bool, detail string) {
+	s.Checks = append(s.Checks, Check{Name: name, Passed: passed, Detail: detail})
+	if passed {
+		s.Passed++
+	}
+}
+
+// Failed returns the details of the checks that failed.
+func (s Score) Failed() []string {
+	var out []string
+	for _, c := range s.Checks {
+		if !c.Passed {
+			out = append(out, c.Name+": "+c.Detail)
+		}
+	}
+	return out
+}
+
+func (s Score) String() string {
+	return fmt.Sprintf("%d/%d", s.Passed, len(s.Checks))
+}
+
+// namedEmotion matches an emotion told rather than shown, which the chapter
+// examiner forbids.
+var namedEmotion = regexp.MustCompile(`(?i)\b(?:felt|feel|feels|feeling|was|were|grew|became)\s+(?:so\s+|very\s+|suddenly\s+)?(?:afraid|scared|frightened|terrified|sad|unhappy|angry|furious|happy|nervous|anxious|worried|lonely|guilty|ashamed|jealous|excited)\b`)
+
+// sentenceEnd splits a paragraph into sentences.
+var sentenceEnd = regexp.MustCompile(`[.!?…]+["'”’)]*\s+`)
+
+// ScoreChapter applies the chapter examiner checks that can be made without
+// a model: the length limits, a short sentence in every paragraph and no
+// named emotions.
+func ScoreChapter(chapter *models.Chapter, story config.StoryConfig) Score {
+	var s Score
+	if chapter == nil {
+		s.add("chapter", false, "no chapter")
+		return s
+	}
+
+	n := utf8.RuneCountInString(chapter.Text)
+	s.add("length", n >= story.MinChapterLength && n <= story.MaxChapterLength,
+		fmt.Sprintf("%d characters, want %d-%d", n, story.MinChapterLength, story.MaxChapterLength))
+
+	var long []string
+	paragraphs := paragraphs(chapter.Text)
+	for i, p := range paragraphs {
+		if !hasShortSentence(p) {
+			long = append(long, fmt.Sprint(i+1))
+		}
+	}
+	detail := fmt.Sprintf("all %d paragraphs", len(paragraphs))
+	if len(long) > 0 {
+		detail = "no sentence under 8 words in paragraph " + strings.Join(long, ", ")
+	}
+	s.add("short_sentences", len(long) == 0, detail)
+
+	named := namedEmotion.FindAllString(chapter.Text, 3)
+	detail = "none"
+	if len(named) > 0 {
+		detail = fmt.Sprintf("%q", named)
+	}
+	s.add("no_named_emotions", len(named) == 0, detail)
+
+	return s
+}
+
+func paragraphs(text string) []string {
+	var out []string
+	for _, p := range strings.Split(text, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func hasShortSentence(paragraph string) bool {
+	for _, sentence := range sentenceEnd.Split(paragraph, -1) {
+		if words := len(strings.Fields(sentence)); words > 0 && words < 8 {
+			return true
+		}
+	}
+	return false
+}
+
+// ScoreHashtags checks the posted tags against pipeline.hashtags: the count,
+// the total length joined with spaces and no duplicates.
+func ScoreHashtags(tags []string, cfg config.HashtagsConfig) Score {
+	var s Score
+	s.add("count", len(tags) >= cfg.CountMin && len(tags) <= cfg.CountMax,
+		fmt.Sprintf("%d tags, want %d-%d", len(tags), cfg.CountMin, cfg.CountMax))
+
+	total := utf8.RuneCountInString(strings.Join(tags, " "))
+	s.add("total_length", total <= cfg.MaxTotalCharacters,
+		fmt.Sprintf("%d characters, max %d", total, cfg.MaxTotalCharacters))
+
+	seen := make(map[string]bool, len(tags))
+	var dupes, malformed []string
+	for _, tag := range tags {
+		key := strings.ToLower(tag)
+		if seen[key] {
+			dupes = append(dupes, tag)
+		}
+		seen[key] = true
+		if !strings.HasPrefix(tag, "#") || strings.ContainsAny(tag, " \t\n") {
+			malformed = append(malformed, tag)
+		}
+	}
+	s.add("unique", len(dupes) == 0, strings.Join(dupes, " "))
+	s.add("well_formed", len(malformed) == 0, strings.Join(malformed, " "))
+	return s
+}
@@ -0,0 +1,142 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// checks returns each check of s by name with whether it passed.
+func checks(s Score) map[string]bool {
+	out := make(map[string]bool, len(s.Checks))
+	for _, c := range s.Checks {
+		out[c.Name] = c.Passed
+	}
+	return out
+}
+
+func TestScoreChapter(t *testing.T) {
+	story := config.StoryConfig{MinChapterLength: 40, MaxChapterLength: 200}
+	short := "Mira ran. The gate was shut behind her."
+	long := "Mira walked slowly along the old mill road towards the ford at dusk"
+
+	tests := []struct {
+		name    string
+		chapter *models.Chapter
+		want    map[string]bool
+		detail  string
+	}{
+		{
+			name:    "no chapter",
+			chapter: nil,
+			want:    map[string]bool{"chapter": false},
+		},
+		{
+			name:    "passes",
+			chapter: &models.Chapter{Text: short + "\n\n" + short},
+			want:    map[string]bool{"length": true, "short_sentences": true, "no_named_emotions": true},
+		},
+		{
+			name:    "too short",
+			chapter: &models.Chapter{Text: "Mira ran."},
+			want:    map[string]bool{"length": false, "short_sentences": true, "no_named_emotions": true},
+			detail:  "9 characters, want 40-200",
+		},
+		{
+			name:    "too long",
+			chapter: &models.Chapter{Text: strings.Repeat(short+"\n", 6)},
+			want:    map[string]bool{"length": false, "short_sentences": true, "no_named_emotions": true},
+		},
+		{
+			name:    "paragraph without a short sentence",
+			chapter: &models.Chapter{Text: short + "\n\n" + long + "."},
+			want:    map[string]bool{"length": true, "short_sentences": false, "no_named_emotions": true},
+			detail:  "no sentence under 8 words in paragraph 2",
+		},
+		{
+			name:    "named emotion",
+			chapter: &models.Chapter{Text: "Mira felt very afraid. The gate was shut behind her."},
+			want:    map[string]bool{"length": true, "short_sentences": true, "no_named_emotions": false},
+			detail:  `["felt very afraid"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := ScoreChapter(tt.chapter, story)
+			got := checks(score)
+			if len(got) != len(tt.want) {
+				t.Fatalf("checks = %v, want %v", got, tt.want)
+			}
+			passed := 0
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s passed = %v, want %v", name, got[name], want)
+				}
+				if want {
+					passed++
+				}
+			}
+			if score.Passed != passed {
+				t.Errorf("Passed = %d, want %d", score.Passed, passed)
+			}
+			if tt.detail != "" && !strings.Contains(strings.Join(score.Failed(), "; "), tt.detail) {
+				t.Errorf("Failed() = %q, want a detail containing %q", score.Failed(), tt.detail)
+			}
+		})
+	}
+}
+
+func TestScoreHashtags(t *testing.T) {
+	cfg := config.HashtagsConfig{CountMin: 2, CountMax: 3, MaxTotalCharacters: 30}
+
+	tests := []struct {
+		name string
+		tags []string
+		want map[string]bool
+	}{
+		{
+			name: "passes",
+			tags: []string{"#thornwood", "#fantasy"},
+			want: map[string]bool{"count": true, "total_length": true, "unique": true, "well_formed": true},
+		},
+		{
+			name: "too few",
+			tags: []string{"#thornwood"},
+			want: map[string]bool{"count": false, "total_length": true, "unique": true, "well_formed": true},
+		},
+		{
+			name: "too many",
+			tags: []string{"#a", "#b", "#c", "#d"},
+			want: map[string]bool{"count": false, "total_length": true, "unique": true, "well_formed": true},
+		},
+		{
+			name: "too long",
+			tags: []string{"#thornwoodchronicles", "#serialfiction"},
+			want: map[string]bool{"count": true, "total_length": false, "unique": true, "well_formed": true},
+		},
+		{
+			name: "duplicate ignoring case",
+			tags: []string{"#Thornwood", "#thornwood"},
+			want: map[string]bool{"count": true, "total_length": true, "unique": false, "well_formed": true},
+		},
+		{
+			name: "malformed",
+			tags: []string{"thornwood", "#dark fantasy"},
+			want: map[string]bool{"count": true, "total_length": true, "unique": true, "well_formed": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checks(ScoreHashtags(tt.tags, cfg))
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s passed = %v, want %v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
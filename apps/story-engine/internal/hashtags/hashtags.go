@@ -0,0 +1,150 @@
+// Package hashtags picks the hashtags posted with each chapter.
+//
+// The hashtag agent proposes candidates in three categories. Select turns
+// them into the final set: cleaned, deduplicated, a mix of the enabled
+// categories, and within the count and character limits of
+// pipeline.hashtags. The story's brand tag is always first.
+
+package hashtags
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Categories of candidate tags.
+const (
+	CategoryStory   = "story"
+	CategoryGenre   = "genre"
+	CategoryGeneral = "general"
+)
+
+// Candidates are the tags proposed for a chapter, best first within each
+// category.
+type Candidates struct {
+	Story   []string `json:"story"`
+	Genre   []string `json:"genre"`
+	General []string `json:"general"`
+}
+
+// Options are the selection constraints.
+type Options struct {
+	CountMin int
+	CountMax int
+	// MaxTotalCharacters is the length of the tags joined with single spaces.
+	MaxTotalCharacters int
+
+	// Brand is always included, first.
+	Brand string
+
+	// Categories lists the enabled categories in the order they take turns.
+	Categories []string
+}
+
+// ErrTooFew is returned with the selection when fewer than CountMin tags fit.
+type ErrTooFew struct {
+	Got, Min int
+}
+
+func (e *ErrTooFew) Error() string {
+	return fmt.Sprintf("only %d hashtags fit the limits, want at least %d", e.Got, e.Min)
+}
+
+// Normalize cleans a tag: a leading # is added and every character that is
+// not a letter, digit or underscore is dropped. It returns "" if nothing
+// usable is left; Instagram ignores tags made only of digits.
+func Normalize(tag string) string {
+	var b strings.Builder
+	for _, r := range tag {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	body := b.String()
+	if strings.TrimFunc(body, unicode.IsDigit) == "" {
+		return ""
+	}
+	return "#" + body
+}
+
+// BrandTag derives the story's brand tag from its title, e.g.
+// "The Cartographer's Silence" becomes #TheCartographersSilence.
+func BrandTag(title string) string {
+	var b strings.Builder
+	for _, word := range strings.Fields(title) {
+		r, size := utf8.DecodeRuneInString(word)
+		b.WriteString(string(unicode.ToUpper(r)) + word[size:])
+	}
+	return Normalize(b.String())
+}
+
+// Select picks the final hashtags. Enabled categories take turns so the set
+// stays a mix; a tag that would overflow the character budget is skipped in
+// favour of later, shorter ones. Tags are compared case-insensitively and
+// the first spelling seen wins.
+func Select(c Candidates, opts Options) ([]string, error) {
+	byCategory := map[string][]string{
+		CategoryStory:   c.Story,
+		CategoryGenre:   c.Genre,
+		CategoryGeneral: c.General,
+	}
+
+	var selected []string
+	seen := make(map[string]bool)
+	length := 0
+
+	add := func(tag string) bool {
+		tag = Normalize(tag)
+		if tag == "" || seen[strings.ToLower(tag)] {
+			return false
+		}
+		n := utf8.RuneCountInString(tag)
+		if len(selected) > 0 {
+			n++ // separating space
+		}
+		if length+n > opts.MaxTotalCharacters {
+			return false
+		}
+		seen[strings.ToLower(tag)] = true
+		selected = append(selected, tag)
+		length += n
+		return true
+	}
+
+	if opts.Brand != "" && opts.CountMax > 0 {
+		add(opts.Brand)
+	}
+
+	queues := make([][]string, 0, len(opts.Categories))
+	for _, category := range opts.Categories {
+		queues = append(queues, byCategory[category])
+	}
+
+	for len(selected) < opts.CountMax {
+		progressed := false
+		for i := range queues {
+			// Take the next tag from this category that fits
+			for len(queues[i]) > 0 {
+				tag := queues[i][0]
+				queues[i] = queues[i][1:]
+				if add(tag) {
+					progressed = true
+					break
+				}
+			}
+			if len(selected) >= opts.CountMax {
+				break
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+
+	if len(selected) < opts.CountMin {
+		return selected, &ErrTooFew{Got: len(selected), Min: opts.CountMin}
+	}
+	return selected, nil
+}
@@ -0,0 +1,151 @@
+package hashtags
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"thornwood", "#thornwood"},
+		{"#Thornwood", "#Thornwood"},
+		{"##lost maps!", "#lostmaps"},
+		{"#serial-fiction", "#serialfiction"},
+		{"#forest_spirits", "#forest_spirits"},
+		{"#café", "#café"},
+		{"#2026", ""},
+		{"#2026reads", "#2026reads"},
+		{"#", ""},
+		{"", ""},
+		{"!!!", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrandTag(t *testing.T) {
+	if got := BrandTag("The Cartographer's Silence"); got != "#TheCartographersSilence" {
+		t.Errorf("BrandTag = %q, want #TheCartographersSilence", got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	all := []string{CategoryStory, CategoryGenre, CategoryGeneral}
+	candidates := Candidates{
+		Story:   []string{"#thornwood", "#lostmaps", "#mira"},
+		Genre:   []string{"#fantasy", "#mystery", "#fantasyreads"},
+		General: []string{"#serialfiction", "#storytelling", "#amreading"},
+	}
+
+	tests := []struct {
+		name       string
+		candidates Candidates
+		opts       Options
+		want       []string
+		wantTooFew bool
+	}{
+		{
+			name:       "categories take turns after the brand",
+			candidates: candidates,
+			opts:       Options{CountMin: 3, CountMax: 5, MaxTotalCharacters: 200, Brand: "#ThornwoodSerial", Categories: all},
+			want:       []string{"#ThornwoodSerial", "#thornwood", "#fantasy", "#serialfiction", "#lostmaps"},
+		},
+		{
+			name:       "category order is the turn order",
+			candidates: candidates,
+			opts:       Options{CountMax: 3, MaxTotalCharacters: 200, Categories: []string{CategoryGeneral, CategoryStory}},
+			want:       []string{"#serialfiction", "#thornwood", "#storytelling"},
+		},
+		{
+			name:       "only one category enabled",
+			candidates: candidates,
+			opts:       Options{CountMin: 2, CountMax: 10, MaxTotalCharacters: 200, Brand: "#ThornwoodSerial", Categories: []string{CategoryGenre}},
+			want:       []string{"#ThornwoodSerial", "#fantasy", "#mystery", "#fantasyreads"},
+		},
+		{
+			name: "case-insensitive dedup keeps the first spelling",
+			candidates: Candidates{
+				Story: []string{"#Thornwood", "#thornwoodserial", "#THORNWOOD", "#mira"},
+				Genre: []string{"#thornwood", "#Fantasy"},
+			},
+			opts: Options{CountMax: 10, MaxTotalCharacters: 200, Brand: "#ThornwoodSerial", Categories: all},
+			want: []string{"#ThornwoodSerial", "#Thornwood", "#Fantasy", "#mira"},
+		},
+		{
+			name:       "invalid characters stripped and empty tags dropped",
+			candidates: Candidates{Story: []string{"lost maps", "#123", "#!!", "#forest-spirits"}},
+			opts:       Options{CountMax: 10, MaxTotalCharacters: 200, Categories: all},
+			want:       []string{"#lostmaps", "#forestspirits"},
+		},
+		{
+			name:       "count max",
+			candidates: candidates,
+			opts:       Options{CountMax: 2, MaxTotalCharacters: 200, Brand: "#ThornwoodSerial", Categories: all},
+			want:       []string{"#ThornwoodSerial", "#thornwood"},
+		},
+		{
+			name:       "long tag skipped for a shorter one",
+			candidates: Candidates{Story: []string{"#averyveryverylongtagindeed", "#mira"}, Genre: []string{"#fantasy"}},
+			// #ThornwoodSerial is 16, + " #mira" 6 = 22, + " #fantasy" 9 = 31
+			opts: Options{CountMax: 10, MaxTotalCharacters: 31, Brand: "#ThornwoodSerial", Categories: all},
+			want: []string{"#ThornwoodSerial", "#mira", "#fantasy"},
+		},
+		{
+			name:       "budget exhausted before count min",
+			candidates: candidates,
+			// " #thornwood" fills the budget exactly
+			opts:       Options{CountMin: 4, CountMax: 10, MaxTotalCharacters: 27, Brand: "#ThornwoodSerial", Categories: all},
+			want:       []string{"#ThornwoodSerial", "#thornwood"},
+			wantTooFew: true,
+		},
+		{
+			name:       "too few candidates",
+			candidates: Candidates{Story: []string{"#thornwood"}},
+			opts:       Options{CountMin: 3, CountMax: 5, MaxTotalCharacters: 200, Brand: "#ThornwoodSerial", Categories: all},
+			want:       []string{"#ThornwoodSerial", "#thornwood"},
+			wantTooFew: true,
+		},
+		{
+			name:       "no categories leaves only the brand",
+			candidates: candidates,
+			opts:       Options{CountMax: 5, MaxTotalCharacters: 200, Brand: "#ThornwoodSerial"},
+			want:       []string{"#ThornwoodSerial"},
+		},
+		{
+			name:       "brand needs room for one tag",
+			candidates: candidates,
+			opts:       Options{CountMax: 0, MaxTotalCharacters: 200, Brand: "#ThornwoodSerial", Categories: all},
+			want:       nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Select(tt.candidates, tt.opts)
+			var tooFew *ErrTooFew
+			if errors.As(err, &tooFew) != tt.wantTooFew {
+				t.Errorf("Select error = %v, want ErrTooFew %v", err, tt.wantTooFew)
+			} else if err != nil && !tt.wantTooFew {
+				t.Errorf("Select: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Select = %v, want %v", got, tt.want)
+			}
+			if n := len(strings.Join(got, " ")); n > tt.opts.MaxTotalCharacters {
+				t.Errorf("selected %d characters, over the %d budget", n, tt.opts.MaxTotalCharacters)
+			}
+			if len(got) > 0 && tt.opts.Brand != "" && got[0] != tt.opts.Brand {
+				t.Errorf("first tag = %s, want the brand %s", got[0], tt.opts.Brand)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+// MaxFailBody is the most bytes of an error sent with a /fail ping.
+const MaxFailBody = 10000
+
+// Pinger signals run progress to a Healthchecks.io style dead man's
+// switch: /start when a run begins, the bare URL on success and /fail with
+// the error on failure. A nil Pinger does nothing, so callers need not
+// check whether healthchecks are enabled.
+//
+// Ping failures are logged and never returned; monitoring must not break a
+// run. The ping URL identifies the check, so it is never logged.
+type Pinger struct {
+	url  string
+	http *http.Client
+}
+
+// NewPinger returns a pinger for cfg, or nil if healthchecks are disabled
+// or no ping URL is set.
+func NewPinger(cfg config.HealthchecksConfig) *Pinger {
+	if !cfg.Enabled || cfg.PingURL == "" {
+		return nil
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	return NewPingerWithClient(cfg.PingURL, &http.Client{Timeout: timeout})
+}
+
+// NewPingerWithClient returns a pinger for url using client, which sets the
+// ping timeout.
+func NewPingerWithClient(url string, client *http.Client) *Pinger {
+	return &Pinger{url: strings.TrimRight(url, "/"), http: client}
+}
+
+// Start signals that a run has begun.
+func (p *Pinger) Start(ctx context.Context) {
+	p.ping(ctx, "start", "/start", "")
+}
+
+// Success signals that a run completed.
+func (p *Pinger) Success(ctx context.Context) {
+	p.ping(ctx, "success", "", "")
+}
+
+// Fail signals that a run failed, sending err as the ping body.
+func (p *Pinger) Fail(ctx context.Context, err error) {
+	body := ""
+	if err != nil {
+		body = truncate(err.Error(), MaxFailBody)
+	}
+	p.ping(ctx, "fail", "/fail", body)
+}
+
+func (p *Pinger) ping(ctx context.Context, signal, suffix, body string) {
+	if p == nil {
+		return
+	}
+	// A run cancelled by shutdown should still report how it ended
+	ctx = context.WithoutCancel(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+suffix, strings.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send healthchecks %s ping: invalid ping URL", signal)
+		return
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := p.http.Do(req)
+	if err != nil {
+		log.Printf("Failed to send healthchecks %s ping: %v", signal, redact(err, p.url))
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 300 {
+		log.Printf("Failed to send healthchecks %s ping: status %d", signal, resp.StatusCode)
+	}
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	const marker = "\n...(truncated)"
+	cut := n - len(marker)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
+
+// redact removes the ping URL from a transport error, which quotes it.
+func redact(err error, url string) string {
+	return strings.ReplaceAll(err.Error(), url, "<ping url>")
+}
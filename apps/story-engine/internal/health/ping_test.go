@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+// ping is one request received by the stand-in server.
+type ping struct {
+	path string
+	body string
+}
+
+// standIn is a local healthchecks server that records the pings it gets.
+func standIn(t *testing.T, handler http.HandlerFunc) (*httptest.Server, func() []ping) {
+	t.Helper()
+	var mu sync.Mutex
+	var pings []ping
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		pings = append(pings, ping{path: r.URL.Path, body: string(body)})
+		mu.Unlock()
+		if handler != nil {
+			handler(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []ping {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]ping(nil), pings...)
+	}
+}
+
+func TestPingerSequence(t *testing.T) {
+	srv, pings := standIn(t, nil)
+	p := NewPinger(config.HealthchecksConfig{Enabled: true, TimeoutSeconds: 5, PingURL: srv.URL + "/check-uuid/"})
+	ctx := context.Background()
+
+	p.Start(ctx)
+	p.Success(ctx)
+	p.Start(ctx)
+	p.Fail(ctx, errors.New("generate stage failed: model timed out"))
+
+	want := []ping{
+		{path: "/check-uuid/start"},
+		{path: "/check-uuid"},
+		{path: "/check-uuid/start"},
+		{path: "/check-uuid/fail", body: "generate stage failed: model timed out"},
+	}
+	got := pings()
+	if len(got) != len(want) {
+		t.Fatalf("got %d pings %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ping %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPingerFailBodyTruncated(t *testing.T) {
+	srv, pings := standIn(t, nil)
+	p := NewPingerWithClient(srv.URL, srv.Client())
+
+	// A multi-byte rune straddles the cut so truncation must back off
+	long := strings.Repeat("é", MaxFailBody)
+	p.Fail(context.Background(), errors.New(long))
+
+	got := pings()
+	if len(got) != 1 {
+		t.Fatalf("got %d pings, want 1", len(got))
+	}
+	body := got[0].body
+	if len(body) > MaxFailBody {
+		t.Errorf("body is %d bytes, want at most %d", len(body), MaxFailBody)
+	}
+	if !strings.HasSuffix(body, "(truncated)") {
+		t.Errorf("body does not end with the truncation marker: %q", body[len(body)-20:])
+	}
+	if !utf8.ValidString(body) {
+		t.Error("body is not valid UTF-8")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{"short", "boom", 10, "boom"},
+		{"exact", "0123456789", 10, "0123456789"},
+		{"long", strings.Repeat("x", 40), 30, strings.Repeat("x", 15) + "\n...(truncated)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.in, tt.n); got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPingerDisabled(t *testing.T) {
+	srv, pings := standIn(t, nil)
+
+	tests := []struct {
+		name string
+		cfg  config.HealthchecksConfig
+	}{
+		{"disabled", config.HealthchecksConfig{Enabled: false, TimeoutSeconds: 5, PingURL: srv.URL}},
+		{"no url", config.HealthchecksConfig{Enabled: true, TimeoutSeconds: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPinger(tt.cfg)
+			if p != nil {
+				t.Fatalf("NewPinger returned a pinger, want nil")
+			}
+			ctx := context.Background()
+			p.Start(ctx)
+			p.Success(ctx)
+			p.Fail(ctx, errors.New("boom"))
+		})
+	}
+	if got := pings(); len(got) != 0 {
+		t.Errorf("got %d pings from disabled pingers, want 0", len(got))
+	}
+}
+
+func TestPingerErrorsDoNotEscape(t *testing.T) {
+	srv, pings := standIn(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/start") {
+			time.Sleep(500 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	p := NewPingerWithClient(srv.URL, &http.Client{Timeout: 100 * time.Millisecond})
+	ctx := context.Background()
+
+	// A slow /start times out and a failing status is only logged
+	began := time.Now()
+	p.Start(ctx)
+	if elapsed := time.Since(began); elapsed > 400*time.Millisecond {
+		t.Errorf("Start took %s, want the 100ms timeout honoured", elapsed)
+	}
+	p.Success(ctx)
+
+	if got := pings(); len(got) != 2 || got[1].path != "/" {
+		t.Errorf("got pings %+v, want the success ping after the timed-out start", got)
+	}
+}
@@ -0,0 +1,360 @@
+// Package health serves the local monitoring endpoints used in daemon mode.
+//
+//	/health/liveness   200 while the process is serving
+//	/health/readiness  200 when the config is valid and every story's data
+//	                   dir is readable, 503 otherwise
+//	/status            the last run, the next scheduled run and today's
+//	                   spend as JSON, per story when several are registered
+//
+// It listens on monitoring.http.host, 127.0.0.1 unless set, so the
+// endpoints are not exposed beyond the machine by default. Error text in
+// the responses has secret values masked, as in the logs.
+//
+// It also pings the Healthchecks.io dead man's switch as runs start and
+// finish; see Pinger.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/pipeline"
+)
+
+// DefaultHost is the address the server binds when monitoring.http.host
+// is not set.
+const DefaultHost = "127.0.0.1"
+
+// Run outcomes reported by /status.
+const (
+	OutcomeRunning   = "running"
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+// SpendSource reports recorded spend; costs.Ledger implements it.
+type SpendSource interface {
+	Spent(since time.Time) (float64, float64, error)
+}
+
+// RunStatus is the state of a pipeline run as shown by /status.
+type RunStatus struct {
+	ID       string                 `json:"id"`
+	Outcome  string                 `json:"outcome"`
+	Error    string                 `json:"error,omitempty"`
+	Started  time.Time              `json:"started"`
+	Finished *time.Time             `json:"finished,omitempty"`
+	Stages   []pipeline.StageResult `json:"stages"`
+	Warnings []string               `json:"warnings"`
+}
+
+// ConfigStatus identifies the loaded config as shown by /status.
+type ConfigStatus struct {
+	Version  int       `json:"version"`
+	Hash     string    `json:"hash"`
+	LoadedAt time.Time `json:"loaded_at"`
+	// ReloadError is why the last change to the config files was rejected.
+	ReloadError string `json:"reload_error,omitempty"`
+}
+
+// StoryStatus is the state of one story as shown by /status.
+type StoryStatus struct {
+	LastRun *RunStatus `json:"last_run"`
+	NextRun *time.Time `json:"next_run"`
+	// TodaySpendUSD is nil when cost tracking is disabled.
+	TodaySpendUSD *float64 `json:"today_spend_usd"`
+	SpendError    string   `json:"spend_error,omitempty"`
+}
+
+// Status is the /status response. A single-story deployment reports its
+// story at the top level; registered stories are reported in Stories.
+type Status struct {
+	Config *ConfigStatus `json:"config"`
+	StoryStatus
+	Stories map[string]*StoryStatus `json:"stories,omitempty"`
+}
+
+// storyState is what the server knows of one story.
+type storyState struct {
+	spend   SpendSource
+	lastRun *RunStatus
+	nextRun time.Time
+}
+
+// Server serves the health and status endpoints. The daemon reports runs
+// to it as they start and finish. Stories are keyed by id, the empty id
+// being the story of a single-story deployment.
+type Server struct {
+	cfg *config.Config
+
+	mu          sync.Mutex
+	config      *config.Snapshot
+	reloadError string
+	stories     map[string]*storyState
+	// redactor masks the secrets of the current config in error text.
+	redactor *logging.Redactor
+}
+
+// NewServer creates a server for cfg. The host and port are read from cfg
+// once; readiness checks use the config set by SetConfig, if any.
+func NewServer(cfg *config.Config) *Server {
+	return &Server{
+		cfg:      cfg,
+		stories:  make(map[string]*storyState),
+		redactor: logging.NewRedactor(cfg.Secrets()),
+	}
+}
+
+// story returns the state of story, creating it. s.mu must be held.
+func (s *Server) story(story string) *storyState {
+	st, ok := s.stories[story]
+	if !ok {
+		st = &storyState{}
+		s.stories[story] = st
+	}
+	return st
+}
+
+// SetSpend sets where story's spend is read from; nil when cost tracking
+// is disabled.
+func (s *Server) SetSpend(story string, spend SpendSource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.story(story).spend = spend
+}
+
+// RemoveStory forgets a story that is no longer scheduled.
+func (s *Server) RemoveStory(story string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.stories, story)
+}
+
+// SetConfig records the config now in use.
+func (s *Server) SetConfig(snapshot *config.Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = snapshot
+	s.reloadError = ""
+	s.redactor = logging.NewRedactor(append(s.cfg.Secrets(), snapshot.Config.Secrets()...))
+}
+
+// ReloadFailed records why a config change was rejected.
+func (s *Server) ReloadFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadError = err.Error()
+}
+
+// currentConfig returns the config readiness checks apply to and the
+// redactor for its secrets.
+func (s *Server) currentConfig() (*config.Config, *logging.Redactor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config != nil {
+		return s.config.Config, s.redactor
+	}
+	return s.cfg, s.redactor
+}
+
+// Handler returns the endpoints' handler.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health/liveness", s.liveness)
+	mux.HandleFunc("GET /health/readiness", s.readiness)
+	mux.HandleFunc("GET /status", s.status)
+	return mux
+}
+
+// Addr is the address the server listens on: monitoring.http.host, or
+// DefaultHost, and monitoring.http.port.
+func (s *Server) Addr() string {
+	host := s.cfg.Monitoring.HTTP.Host
+	if host == "" {
+		host = DefaultHost
+	}
+	return net.JoinHostPort(host, strconv.Itoa(s.cfg.Monitoring.HTTP.Port))
+}
+
+// ListenAndServe serves on Addr until ctx is cancelled, when it returns
+// nil. Any other error, such as the port being in use, is returned for the
+// caller to shut down on.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.Addr(),
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Health server listening on %s", srv.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve health endpoints: %w", err)
+	}
+	return nil
+}
+
+// RunStarted records that run has begun.
+func (s *Server) RunStarted(run *pipeline.Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.story(run.Story).lastRun = &RunStatus{ID: run.ID, Outcome: OutcomeRunning, Started: time.Now()}
+}
+
+// RunFinished records the outcome of run; err is the error Execute returned.
+func (s *Server) RunFinished(run *pipeline.Run, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := &RunStatus{
+		ID:       run.ID,
+		Outcome:  OutcomeSucceeded,
+		Started:  run.Started,
+		Stages:   run.Stages,
+		Warnings: run.Warnings,
+	}
+	if err != nil {
+		status.Outcome = OutcomeFailed
+		status.Error = err.Error()
+	}
+	if !run.Finished.IsZero() {
+		finished := run.Finished
+		status.Finished = &finished
+	}
+	s.story(run.Story).lastRun = status
+}
+
+// SetNextRun records when story's next run is scheduled.
+func (s *Server) SetNextRun(story string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.story(story).nextRun = t
+}
+
+func (s *Server) liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readiness checks the config still validates and the data dir of every
+// story can be read.
+func (s *Server) readiness(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"config": "ok"}
+	ready := true
+
+	cfg, redactor := s.currentConfig()
+	if err := cfg.Validate(); err != nil {
+		checks["config"] = redactor.Redact(err.Error())
+		ready = false
+	}
+	dataDirs := map[string]string{"data_dir": cfg.Paths.DataDir}
+	if len(cfg.Stories) > 0 {
+		dataDirs = make(map[string]string, len(cfg.Stories))
+		for id, story := range cfg.Stories {
+			dataDirs["stories."+id+".data_dir"] = story.DataDir
+		}
+	}
+	for check, dir := range dataDirs {
+		checks[check] = "ok"
+		if _, err := os.ReadDir(dir); err != nil {
+			checks[check] = redactor.Redact(err.Error())
+			ready = false
+		}
+	}
+
+	code, state := http.StatusOK, "ready"
+	if !ready {
+		code, state = http.StatusServiceUnavailable, "not_ready"
+	}
+	writeJSON(w, code, map[string]any{"status": state, "checks": checks})
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var status Status
+	if s.config != nil {
+		status.Config = &ConfigStatus{
+			Version:     s.config.Version,
+			Hash:        s.config.Hash,
+			LoadedAt:    s.config.LoadedAt,
+			ReloadError: s.redactor.Redact(s.reloadError),
+		}
+	}
+	stories := make(map[string]storyState, len(s.stories))
+	for id, st := range s.stories {
+		stories[id] = *st
+	}
+	redactor := s.redactor
+	s.mu.Unlock()
+
+	for id, st := range stories {
+		story := storyStatus(st, redactor)
+		if id == "" {
+			status.StoryStatus = *story
+			continue
+		}
+		if status.Stories == nil {
+			status.Stories = make(map[string]*StoryStatus)
+		}
+		status.Stories[id] = story
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// storyStatus reports st, reading today's spend from its ledger, with
+// secrets masked in error text.
+func storyStatus(st storyState, redactor *logging.Redactor) *StoryStatus {
+	var status StoryStatus
+	if st.lastRun != nil {
+		last := *st.lastRun
+		last.Error = redactor.Redact(last.Error)
+		last.Stages = nil
+		for _, stage := range st.lastRun.Stages {
+			stage.Error = redactor.Redact(stage.Error)
+			last.Stages = append(last.Stages, stage)
+		}
+		last.Warnings = nil
+		for _, w := range st.lastRun.Warnings {
+			last.Warnings = append(last.Warnings, redactor.Redact(w))
+		}
+		status.LastRun = &last
+	}
+	if !st.nextRun.IsZero() {
+		next := st.nextRun
+		status.NextRun = &next
+	}
+	if st.spend != nil {
+		if _, today, err := st.spend.Spent(time.Now()); err != nil {
+			status.SpendError = redactor.Redact(err.Error())
+		} else {
+			status.TodaySpendUSD = &today
+		}
+	}
+	return &status
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Failed to write health response: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package health
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/pipeline"
+)
+
+func TestServerAddr(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"", "127.0.0.1:8080"},
+		{"0.0.0.0", "0.0.0.0:8080"},
+		{"::1", "[::1]:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Monitoring.HTTP = config.HTTPConfig{Enabled: true, Host: tt.host, Port: 8080}
+			if got := NewServer(cfg).Addr(); got != tt.want {
+				t.Errorf("Addr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusRedactsSecrets(t *testing.T) {
+	const secret = "sk-ant-very-secret-key"
+	cfg := &config.Config{}
+	cfg.Anthropic.APIKey = secret
+	s := NewServer(cfg)
+
+	run := &pipeline.Run{
+		ID:       "2026-10-19",
+		Started:  time.Now(),
+		Finished: time.Now(),
+		Stages: []pipeline.StageResult{
+			{Name: "canon_check", Status: pipeline.StatusFailed, Error: "request with key " + secret + " rejected"},
+		},
+		Warnings: []string{"retrying after 401 for " + secret},
+	}
+	s.RunStarted(run)
+	s.RunFinished(run, errors.New("stage canon_check failed: request with key "+secret+" rejected"))
+	s.ReloadFailed(errors.New("bad key " + secret))
+	s.SetSpend("", failingSpend{secret})
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if strings.Contains(string(body), secret) {
+		t.Errorf("/status leaks the secret:\n%s", body)
+	}
+	if got := strings.Count(string(body), "[REDACTED]"); got < 4 {
+		t.Errorf("/status has %d redactions, want the run, stage, warning and spend errors masked:\n%s", got, body)
+	}
+}
+
+type failingSpend struct{ secret string }
+
+func (f failingSpend) Spent(since time.Time) (float64, float64, error) {
+	return 0, 0, errors.New("failed to read ledger with token " + f.secret)
+}
@@ -0,0 +1,230 @@
+// Package logging builds the engine's structured logger from the logging
+// configuration.
+//
+// Output is JSON or text at the configured level. During a run every line
+// carries the run's correlation id and, inside the pipeline, the stage and
+// agent, and is also written to {runs_dir}/{date}/logs/. Secret values
+// from the config are masked in everything written, including the
+// standard library log calls routed through the default logger.
+
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+// Redacted replaces secret values in log output.
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute key suffixes whose values are always masked.
+var secretKeys = []string{"api_key", "apikey", "access_token", "password", "secret", "authorization", "ping_url"}
+
+// New creates a logger writing to w. Every write has the secret values
+// replaced and attributes with secret-looking keys masked.
+func New(cfg config.LoggingConfig, w io.Writer, secrets []string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       parseLevel(cfg.Level),
+		ReplaceAttr: maskSecretAttr,
+	}
+	w = newRedactWriter(w, secrets)
+
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(handler)
+}
+
+// Setup installs the process logger, writing to stdout, as the slog and
+// log package default and returns it.
+func Setup(cfg *config.Config) *slog.Logger {
+	logger := New(cfg.Logging, os.Stdout, cfg.Secrets())
+	slog.SetDefault(logger)
+	return logger
+}
+
+// RunLog is the logger for one pipeline run. The default logger is left
+// alone, so runs of different stories can log at the same time; the run's
+// code gets the logger from the run or its context.
+type RunLog struct {
+	Logger *slog.Logger
+	Path   string
+
+	file *os.File
+}
+
+// StartRun creates the logger for a run, tagged with its correlation id.
+// With logging.file enabled it also appends to the run's log file in
+// runDir/logs.
+func StartRun(cfg *config.Config, runDir, correlationID string) (*RunLog, error) {
+	rl := &RunLog{}
+
+	var w io.Writer = os.Stdout
+	if cfg.Logging.File.Enabled {
+		name := strings.ReplaceAll(cfg.Logging.File.FilenamePattern, "{run_id}", correlationID)
+		dir := filepath.Join(runDir, "logs")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		rl.Path = filepath.Join(dir, name)
+		f, err := os.OpenFile(rl.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open run log: %w", err)
+		}
+		rl.file = f
+		w = io.MultiWriter(os.Stdout, f)
+	}
+
+	rl.Logger = New(cfg.Logging, w, cfg.Secrets()).With("run_id", correlationID)
+	return rl, nil
+}
+
+// Close closes the run log file. Closing a nil RunLog does nothing.
+func (rl *RunLog) Close() error {
+	if rl == nil {
+		return nil
+	}
+	if rl.file == nil {
+		return nil
+	}
+	return rl.file.Close()
+}
+
+// NewCorrelationID returns an id for one run, unique even when a date's run
+// is repeated: the run id followed by random hex.
+func NewCorrelationID(runID string) string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return runID + "-" + hex.EncodeToString(b)
+}
+
+type contextKey struct{}
+
+// WithLogger returns a context carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func parseLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func maskSecretAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, suffix := range secretKeys {
+		if strings.HasSuffix(key, suffix) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	return a
+}
+
+// Redactor masks secret values in text, as the logger does in everything
+// it writes. A nil Redactor returns text unchanged.
+type Redactor struct {
+	replacer *strings.Replacer
+}
+
+// NewRedactor creates a redactor for the secret values, such as those of
+// config.Config.Secrets.
+func NewRedactor(secrets []string) *Redactor {
+	// Longest first, so a secret containing another is masked whole
+	secrets = append([]string(nil), secrets...)
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	var pairs []string
+	for _, s := range secrets {
+		// config.Validate refuses shorter secrets; skipping them here keeps
+		// an unvalidated config from masking ordinary text
+		if len(s) < config.MinSecretLength {
+			continue
+		}
+		pairs = append(pairs, s, Redacted)
+		// Quoted output escapes some characters, so mask those forms too
+		for _, escaped := range escapedForms(s) {
+			pairs = append(pairs, escaped, Redacted)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	return &Redactor{replacer: strings.NewReplacer(pairs...)}
+}
+
+// Redact returns text with every secret value masked.
+func (r *Redactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	return r.replacer.Replace(text)
+}
+
+// redactWriter masks secret values in everything written through it. slog
+// handlers write each record in a single call, so a secret is never split
+// across writes.
+type redactWriter struct {
+	w        io.Writer
+	redactor *Redactor
+}
+
+func newRedactWriter(w io.Writer, secrets []string) io.Writer {
+	redactor := NewRedactor(secrets)
+	if redactor == nil {
+		return w
+	}
+	return &redactWriter{w: w, redactor: redactor}
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, r.redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// escapedForms returns s as it appears inside a JSON string and inside a
+// quoted text value, where those differ from s.
+func escapedForms(s string) []string {
+	var out []string
+	if b, err := json.Marshal(s); err == nil {
+		if e := string(b[1 : len(b)-1]); e != s {
+			out = append(out, e)
+		}
+	}
+	if q := strconv.Quote(s); q[1:len(q)-1] != s {
+		out = append(out, q[1:len(q)-1])
+	}
+	return out
+}
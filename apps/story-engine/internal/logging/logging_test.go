@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+)
+
+func TestStartRunConcurrent(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Logging = config.LoggingConfig{Level: "info", Format: "json"}
+	cfg.Logging.File = config.FileConfig{Enabled: true, FilenamePattern: "{run_id}.log"}
+	before := slog.Default()
+
+	runs := []string{"night_train-2026-10-19-aaaa", "lighthouse-2026-10-19-bbbb"}
+	logs := make([]*RunLog, len(runs))
+	for i, id := range runs {
+		rl, err := StartRun(cfg, t.TempDir(), id)
+		if err != nil {
+			t.Fatalf("StartRun: %v", err)
+		}
+		logs[i] = rl
+	}
+	if slog.Default() != before {
+		t.Error("StartRun replaced the default logger")
+	}
+
+	var wg sync.WaitGroup
+	for _, rl := range logs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				rl.Logger.Info("stage completed")
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, rl := range logs {
+		if err := rl.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		data, err := os.ReadFile(rl.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != 50 {
+			t.Errorf("%s has %d lines, want 50", rl.Path, len(lines))
+		}
+		for _, line := range lines {
+			if !strings.Contains(line, `"run_id":"`+runs[i]+`"`) {
+				t.Fatalf("%s has a line from another run: %s", rl.Path, line)
+			}
+		}
+	}
+	if slog.Default() != before {
+		t.Error("Close replaced the default logger")
+	}
+}
+
+func TestNewRedacts(t *testing.T) {
+	const key = "sk-ant-api03-secret"
+	// Escaped differently by each handler: \" and \u003c in JSON, \" in text
+	const quoted = `pa"ss<word>`
+
+	tests := []struct {
+		name   string
+		format string
+		log    func(l *slog.Logger)
+	}{
+		{"json attribute", "json", func(l *slog.Logger) { l.Info("calling", "header", "Bearer "+key) }},
+		{"json error", "json", func(l *slog.Logger) { l.Error("call failed", "error", errors.New("401 for key "+key)) }},
+		{"json message", "json", func(l *slog.Logger) { l.Warn("retrying with " + key) }},
+		{"json escaped", "json", func(l *slog.Logger) { l.Info("smtp", "auth", "user:"+quoted) }},
+		{"text attribute", "text", func(l *slog.Logger) { l.Info("calling", "header", "Bearer "+key) }},
+		{"text error", "text", func(l *slog.Logger) { l.Error("call failed", "error", errors.New("401 for key "+key)) }},
+		{"text escaped", "text", func(l *slog.Logger) { l.Info("smtp", "auth", "user:"+quoted) }},
+		{"secret key name", "json", func(l *slog.Logger) { l.Info("config", "smtp_password", "not-in-the-list") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(New(config.LoggingConfig{Level: "debug", Format: tt.format}, &buf, []string{key, quoted}))
+			out := buf.String()
+
+			// The quoted secret in any escaping keeps ss<word or ss\u003cword
+			for _, leak := range []string{key, "ss<word", `ss\u003cword`, "not-in-the-list"} {
+				if strings.Contains(out, leak) {
+					t.Errorf("output leaks %q: %s", leak, out)
+				}
+			}
+			if !strings.Contains(out, Redacted) {
+				t.Errorf("output has no %s: %s", Redacted, out)
+			}
+		})
+	}
+}
+
+func TestSetupRedactsStandardLog(t *testing.T) {
+	const key = "sk-ant-api03-secret"
+	cfg := &config.Config{}
+	cfg.Logging = config.LoggingConfig{Level: "info", Format: "text"}
+	cfg.Anthropic.APIKey = key
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, prev := os.Stdout, slog.Default()
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+		slog.SetDefault(prev)
+	}()
+
+	Setup(cfg)
+	log.Printf("request failed with key %s", key)
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(out), key) || !strings.Contains(string(out), Redacted) {
+		t.Errorf("log.Printf after Setup wrote %q, want the key masked", out)
+	}
+}
+
+func TestRedactorSkipsShortValues(t *testing.T) {
+	r := NewRedactor([]string{"abc", "sk-ant-api03-secret"})
+	if got := r.Redact("abc key sk-ant-api03-secret"); got != "abc key "+Redacted {
+		t.Errorf("Redact = %q, want only the long secret masked", got)
+	}
+	if NewRedactor([]string{"abc"}) != nil {
+		t.Error("NewRedactor with only short values should be nil")
+	}
+}
@@ -0,0 +1,36 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// ArchiveStage writes the chapter's archive record with the run's
+// provenance, so `storygen prompts diff` can tell which prompts changed
+// between chapters.
+type ArchiveStage struct{}
+
+func (ArchiveStage) Name() string { return "archive" }
+
+func (ArchiveStage) Run(ctx context.Context, run *Run) error {
+	if run.Chapter == nil {
+		logging.FromContext(ctx).Info("no chapter drafted, skipping archive")
+		return nil
+	}
+
+	record := &models.ChapterRecord{
+		ChapterNumber: run.Chapter.ChapterNumber,
+		Title:         run.Chapter.Title,
+		EmotionalBeat: run.Chapter.EmotionalBeatAchieved,
+		FullText:      run.Chapter.Text,
+		Provenance:    run.Provenance,
+	}
+	if err := run.Store.SaveChapterRecord(record); err != nil {
+		return fmt.Errorf("failed to archive chapter %d: %w", record.ChapterNumber, err)
+	}
+	run.AddArtifact(run.Store.ChapterRecordPath(record.ChapterNumber))
+	return nil
+}
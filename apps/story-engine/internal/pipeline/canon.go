@@ -0,0 +1,82 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// CanonChecker finds canon violations in a drafted chapter.
+type CanonChecker interface {
+	Check(ctx context.Context, bible *models.StoryBible, chapter *models.Chapter) ([]agents.CanonViolation, error)
+}
+
+// ChapterReviser rewrites a drafted chapter to address feedback.
+type ChapterReviser interface {
+	Revise(ctx context.Context, draft *models.Chapter, feedback []string) (*models.Chapter, error)
+}
+
+// CanonStage checks the drafted chapter against the story bible's universe
+// rules. With canon_check_action "rewrite" it sends the chapter back to the
+// writer up to canon_retry_attempts times; anything still flagged after that,
+// or every flag with action "flag", is reported in the daily email.
+type CanonStage struct {
+	Checker CanonChecker
+	Writer  ChapterReviser
+}
+
+func (CanonStage) Name() string { return "canon_check" }
+
+func (s CanonStage) Run(ctx context.Context, run *Run) error {
+	if run.Chapter == nil {
+		logging.FromContext(ctx).Info("no chapter drafted, skipping canon check")
+		return nil
+	}
+
+	bible, err := run.Store.LoadStoryBible()
+	if err != nil {
+		return fmt.Errorf("failed to load story bible: %w", err)
+	}
+
+	validation := run.Config.Pipeline.Validation
+	rewrites := 0
+	if validation.CanonCheckAction == "rewrite" && s.Writer != nil {
+		rewrites = validation.CanonRetryAttempts
+	}
+
+	for attempt := 0; ; attempt++ {
+		violations, err := s.Checker.Check(ctx, bible, run.Chapter)
+		if err != nil {
+			return err
+		}
+		if len(violations) == 0 {
+			run.CanonViolations = nil
+			return nil
+		}
+
+		if attempt >= rewrites {
+			run.CanonViolations = violations
+			for _, v := range violations {
+				run.Warn("canon: %s", v)
+			}
+			return nil
+		}
+
+		logging.FromContext(ctx).Info("canon check found violations, sending chapter back to the writer",
+			"violations", len(violations), "rewrite", attempt+1, "max_rewrites", rewrites)
+
+		feedback := make([]string, 0, len(violations))
+		for _, v := range violations {
+			feedback = append(feedback, fmt.Sprintf("This sentence breaks the rule %q (%s): %q. %s",
+				v.Rule, v.RuleText, v.Quote, v.Explanation))
+		}
+		revised, err := s.Writer.Revise(ctx, run.Chapter, feedback)
+		if err != nil {
+			return fmt.Errorf("failed to revise chapter: %w", err)
+		}
+		run.Chapter = revised
+	}
+}
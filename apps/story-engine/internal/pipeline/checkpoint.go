@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// CheckpointsDir is the directory of a run's checkpoints.
+const CheckpointsDir = "checkpoints"
+
+// Checkpoint is the run's output after a stage completed. With
+// pipeline.checkpoints.enabled one is written after every stage, to
+// checkpoints/<NN>_<stage>.json in the run directory.
+type Checkpoint struct {
+	Stage           string                  `json:"stage"`
+	Time            time.Time               `json:"time"`
+	Chapter         *models.Chapter         `json:"chapter,omitempty"`
+	CanonViolations []agents.CanonViolation `json:"canon_violations,omitempty"`
+	Hashtags        []string                `json:"hashtags,omitempty"`
+	ImagePrompts    []agents.ImagePrompt    `json:"image_prompts,omitempty"`
+	Warnings        []string                `json:"warnings,omitempty"`
+}
+
+// writeCheckpoint records the run's output after its latest stage.
+func (r *Run) writeCheckpoint(stage string) error {
+	c := Checkpoint{
+		Stage:           stage,
+		Time:            time.Now().UTC(),
+		Chapter:         r.Chapter,
+		CanonViolations: r.CanonViolations,
+		Hashtags:        r.Hashtags,
+		ImagePrompts:    r.ImagePrompts,
+		Warnings:        r.Warnings,
+	}
+	path := filepath.Join(r.Dir, CheckpointsDir, fmt.Sprintf("%02d_%s.json", len(r.Stages), stage))
+	if err := storage.WriteJSON(path, c); err != nil {
+		return err
+	}
+	r.AddArtifact(path)
+	return nil
+}
+
+// LoadLatestCheckpoint reads the checkpoint of the last stage completed by
+// the run in runDir.
+func LoadLatestCheckpoint(runDir string) (*Checkpoint, error) {
+	files, err := filepath.Glob(filepath.Join(runDir, CheckpointsDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no checkpoints in %s: %w", runDir, os.ErrNotExist)
+	}
+	sort.Strings(files)
+
+	var c Checkpoint
+	if err := storage.ReadJSON(files[len(files)-1], &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,29 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/continuity"
+)
+
+// ContinuityStage cross-checks entity files, world_state and world_map after
+// a chapter's updates have been written. Issues are reported as run warnings
+// for the daily email; they do not fail the run, since the chapter is
+// already written and a human needs to decide which file is right.
+type ContinuityStage struct{}
+
+func (ContinuityStage) Name() string { return "continuity_check" }
+
+func (ContinuityStage) Run(ctx context.Context, run *Run) error {
+	in, err := continuity.LoadInput(run.Store, false)
+	if err != nil {
+		return fmt.Errorf("failed to load story data: %w", err)
+	}
+
+	report := continuity.Validate(in)
+	for _, issue := range report.Issues {
+		run.Warn("continuity: %s", issue)
+	}
+	return nil
+}
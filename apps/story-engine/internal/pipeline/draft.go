@@ -0,0 +1,47 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// DraftStage supplies today's chapter to the stages after it.
+//
+// The planner and writer stages that will draft the chapter are not built
+// yet, so for now the chapter is read from Path, a chapter drafted outside
+// the engine in the writer's JSON output format (storygen run --chapter).
+// Without one the run records a warning and the chapter stages (canon
+// check, hashtags, image prompts, archive) skip.
+type DraftStage struct {
+	Path string
+}
+
+func (DraftStage) Name() string { return "draft" }
+
+func (s DraftStage) Run(ctx context.Context, run *Run) error {
+	if s.Path == "" {
+		run.Warn("no chapter drafted: the planner and writer stages are not built yet, so the chapter stages skip; pass a drafted chapter with storygen run --chapter")
+		return nil
+	}
+
+	var chapter models.Chapter
+	if err := storage.ReadJSON(s.Path, &chapter); err != nil {
+		return fmt.Errorf("failed to load drafted chapter: %w", err)
+	}
+	if chapter.Text == "" {
+		return fmt.Errorf("drafted chapter %s has no text", s.Path)
+	}
+	if chapter.ChapterNumber <= 0 {
+		return fmt.Errorf("drafted chapter %s has no chapter_number", s.Path)
+	}
+	chapter.CharacterCount = utf8.RuneCountInString(chapter.Text)
+
+	run.Chapter = &chapter
+	logging.FromContext(ctx).Info("loaded drafted chapter", "chapter", chapter.ChapterNumber, "path", s.Path)
+	return nil
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/hashtags"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// HashtagSource proposes hashtag candidates for a chapter.
+type HashtagSource interface {
+	Categories() []string
+	Candidates(ctx context.Context, bible *models.StoryBible, chapter *models.Chapter) (*hashtags.Candidates, error)
+}
+
+// HashtagStage picks the hashtags posted with the chapter. The agent
+// proposes candidates; hashtags.Select enforces pipeline.hashtags.
+type HashtagStage struct {
+	Source HashtagSource
+}
+
+func (HashtagStage) Name() string { return "hashtags" }
+
+func (s HashtagStage) Run(ctx context.Context, run *Run) error {
+	if run.Chapter == nil {
+		logging.FromContext(ctx).Info("no chapter drafted, skipping hashtags")
+		return nil
+	}
+
+	bible, err := run.Store.LoadStoryBible()
+	if err != nil {
+		return fmt.Errorf("failed to load story bible: %w", err)
+	}
+
+	tags, err := SelectHashtags(ctx, run.Config.Pipeline.Hashtags, s.Source, bible, run.Chapter)
+	var tooFew *hashtags.ErrTooFew
+	if errors.As(err, &tooFew) {
+		// Posting with fewer tags is better than not posting
+		run.Warn("hashtags: %v", err)
+	} else if err != nil {
+		return err
+	}
+
+	run.Hashtags = tags
+	logging.FromContext(ctx).Info("selected hashtags", "count", len(tags), "hashtags", strings.Join(tags, " "))
+	return nil
+}
+
+// SelectHashtags asks source for candidates for chapter and picks the tags
+// to post under cfg. When too few qualify it returns them with an
+// *hashtags.ErrTooFew.
+func SelectHashtags(ctx context.Context, cfg config.HashtagsConfig, source HashtagSource, bible *models.StoryBible, chapter *models.Chapter) ([]string, error) {
+	candidates, err := source.Candidates(ctx, bible, chapter)
+	if err != nil {
+		return nil, err
+	}
+
+	brand := cfg.BrandTag
+	if brand == "" {
+		brand = hashtags.BrandTag(bible.Meta.StoryTitle)
+	}
+	return hashtags.Select(*candidates, hashtags.Options{
+		CountMin:           cfg.CountMin,
+		CountMax:           cfg.CountMax,
+		MaxTotalCharacters: cfg.MaxTotalCharacters,
+		Brand:              brand,
+		Categories:         source.Categories(),
+	})
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// ImagePromptWriter writes image prompts for a chapter.
+type ImagePromptWriter interface {
+	Generate(ctx context.Context, chapter *models.Chapter, visuals []agents.EntityVisual, count int) ([]agents.ImagePrompt, error)
+}
+
+// ImagePromptStage writes the prompts for the chapter's images. Entities
+// named in the chapter are passed along with their visual descriptions so
+// recurring characters look the same from chapter to chapter. The number of
+// images may be lowered to stay within budget.
+type ImagePromptStage struct {
+	Writer ImagePromptWriter
+}
+
+func (ImagePromptStage) Name() string { return "image_prompts" }
+
+func (s ImagePromptStage) Run(ctx context.Context, run *Run) error {
+	if run.Chapter == nil {
+		logging.FromContext(ctx).Info("no chapter drafted, skipping image prompts")
+		return nil
+	}
+
+	count := run.Config.ImageGeneration.ImagesPerChapter
+	if run.Budget != nil {
+		allowed, err := run.Budget.AllowImages(run.Config.ImageGeneration.Model, count)
+		if err != nil {
+			return err
+		}
+		if allowed < count {
+			run.Warn("budget: generating %d of %d images", allowed, count)
+		}
+		count = allowed
+	}
+
+	prompts, err := s.Writer.Generate(ctx, run.Chapter, chapterVisuals(run), count)
+	if err != nil {
+		return err
+	}
+	run.ImagePrompts = prompts
+
+	for _, p := range prompts {
+		logging.FromContext(ctx).Info("image prompt", "shot", p.Shot, "chars", len([]rune(p.Prompt)), "rationale", p.Rationale)
+	}
+	return nil
+}
+
+// chapterVisuals returns the visual descriptions of entities named in the
+// chapter text, up to context.max_entities.
+func chapterVisuals(run *Run) []agents.EntityVisual {
+	files, err := run.Store.EntityFiles(false)
+	if err != nil {
+		run.Warn("image prompts: %v", err)
+		return nil
+	}
+
+	var visuals []agents.EntityVisual
+	for _, path := range files {
+		e, err := storage.LoadEntity(path)
+		if err != nil {
+			run.Warn("image prompts: %v", err)
+			continue
+		}
+		if e.Visual == nil || !mentions(run.Chapter.Text, e) {
+			continue
+		}
+		visuals = append(visuals, agents.EntityVisual{ID: e.ID, Name: e.Name, Type: e.Type, Visual: e.Visual})
+		if len(visuals) == run.Config.Pipeline.Context.MaxEntities {
+			break
+		}
+	}
+	return visuals
+}
+
+// mentions reports whether text names the entity, by full name, by name
+// without a leading "The", or for characters by first name.
+func mentions(text string, e *models.Entity) bool {
+	if e.Name == "" {
+		return false
+	}
+	names := []string{e.Name, strings.TrimPrefix(e.Name, "The ")}
+	if e.Type == models.TypeCharacter {
+		names = append(names, strings.Fields(e.Name)[0])
+	}
+	for _, name := range names {
+		re := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`)
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
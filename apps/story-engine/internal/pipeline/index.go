@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+)
+
+// IndexFile is the runs index in the runs directory: a summary of every
+// run's manifest, newest first, so past runs can be listed without
+// reading each run directory. A date run more than once has an entry per
+// attempt.
+const IndexFile = "index.json"
+
+// IndexEntry summarises one run's manifest.
+type IndexEntry struct {
+	RunID         string        `json:"run_id"`
+	CorrelationID string        `json:"correlation_id,omitempty"`
+	Story         string        `json:"story,omitempty"`
+	Status        string        `json:"status"`
+	ChapterNumber int           `json:"chapter_number,omitempty"`
+	Started       time.Time     `json:"started"`
+	Duration      time.Duration `json:"duration_ns"`
+	CostUSD       float64       `json:"cost_usd"`
+	Retries       int           `json:"retries,omitempty"`
+	Warnings      int           `json:"warnings,omitempty"`
+	FailedStage   string        `json:"failed_stage,omitempty"`
+	// Error is the failed stage's error, or the run's if no stage failed.
+	Error string `json:"error,omitempty"`
+}
+
+func indexEntry(m *Manifest) IndexEntry {
+	e := IndexEntry{
+		RunID:         m.RunID,
+		CorrelationID: m.CorrelationID,
+		Story:         m.Story,
+		Status:        m.Status,
+		ChapterNumber: m.ChapterNumber,
+		Started:       m.Started,
+		Duration:      m.Duration,
+		CostUSD:       m.CostUSD,
+		Warnings:      len(m.Warnings),
+		FailedStage:   m.FailedStage(),
+		Error:         m.Error,
+	}
+	for _, s := range m.Stages {
+		e.Retries += s.Retries
+		// The stage's own error, without the run's wrapping
+		if s.Status == StatusFailed {
+			e.Error = s.Error
+		}
+	}
+	return e
+}
+
+// LoadIndex reads the index of the runs in runsDir. A missing index is
+// rebuilt from the manifests.
+func LoadIndex(runsDir string) ([]IndexEntry, error) {
+	var entries []IndexEntry
+	err := storage.ReadJSON(filepath.Join(runsDir, IndexFile), &entries)
+	if errors.Is(err, fs.ErrNotExist) {
+		return RebuildIndex(runsDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs index: %w", err)
+	}
+	return entries, nil
+}
+
+// UpdateIndex adds m to the index of the runs in runsDir. An earlier
+// attempt of the same date keeps its own entry; only an entry of the same
+// attempt is replaced.
+func UpdateIndex(runsDir string, m *Manifest) error {
+	entries, err := LoadIndex(runsDir)
+	if err != nil {
+		return err
+	}
+	entry := indexEntry(m)
+	replaced := false
+	for i := range entries {
+		if entries[i].RunID == entry.RunID && entries[i].CorrelationID == entry.CorrelationID {
+			entries[i], replaced = entry, true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+	return writeIndex(runsDir, entries)
+}
+
+// RebuildIndex rebuilds the index of the runs in runsDir from the
+// manifests of every attempt. Runs without a manifest are left out.
+func RebuildIndex(runsDir string) ([]IndexEntry, error) {
+	dirs, err := os.ReadDir(runsDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+
+	var entries []IndexEntry
+	for _, d := range dirs {
+		// Run ids are dates; anything else is not a run directory
+		if _, err := time.Parse(RunDateFormat, d.Name()); !d.IsDir() || err != nil {
+			continue
+		}
+		manifests, err := LoadManifests(filepath.Join(runsDir, d.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load run %s: %w", d.Name(), err)
+		}
+		for _, m := range manifests {
+			entries = append(entries, indexEntry(m))
+		}
+	}
+	if err := writeIndex(runsDir, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// writeIndex writes entries newest first: by date, then by start time.
+func writeIndex(runsDir string, entries []IndexEntry) error {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].RunID != entries[j].RunID {
+			return entries[i].RunID > entries[j].RunID
+		}
+		return entries[i].Started.After(entries[j].Started)
+	})
+	if err := storage.WriteJSON(filepath.Join(runsDir, IndexFile), entries); err != nil {
+		return fmt.Errorf("failed to write runs index: %w", err)
+	}
+	return nil
+}
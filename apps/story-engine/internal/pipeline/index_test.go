@@ -0,0 +1,111 @@
+package pipeline
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// attempt writes the manifest of one attempt of run, started at hour.
+func attempt(t *testing.T, run *Run, correlationID string, hour int, runErr error) {
+	t.Helper()
+	run.CorrelationID = correlationID
+	run.Started = time.Date(2026, 10, 19, hour, 0, 0, 0, time.UTC)
+	run.Finished = run.Started.Add(time.Minute)
+	if err := run.writeManifest(nil, runErr); err != nil {
+		t.Fatalf("writeManifest: %v", err)
+	}
+}
+
+func indexed(entries []IndexEntry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.RunID+" "+e.CorrelationID+" "+e.Status)
+	}
+	return out
+}
+
+func TestWriteManifestKeepsEveryAttempt(t *testing.T) {
+	run := newTestRun(t)
+	runsDir := filepath.Dir(run.Dir)
+
+	attempt(t, run, "2026-10-19-aaaa", 6, errors.New("canon check failed"))
+	attempt(t, run, "2026-10-19-bbbb", 7, nil)
+	// Writing the same attempt again replaces its manifest and entry
+	attempt(t, run, "2026-10-19-bbbb", 7, nil)
+
+	entries, err := LoadIndex(runsDir)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+	want := []string{"2026-10-19 2026-10-19-bbbb succeeded", "2026-10-19 2026-10-19-aaaa failed"}
+	if got := indexed(entries); !slices.Equal(got, want) {
+		t.Errorf("index = %q, want %q", got, want)
+	}
+
+	latest, err := LoadManifest(run.Dir)
+	if err != nil || latest.CorrelationID != "2026-10-19-bbbb" {
+		t.Fatalf("LoadManifest = %+v, %v, want the latest attempt", latest, err)
+	}
+	first, err := LoadAttemptManifest(run.Dir, "2026-10-19-aaaa")
+	if err != nil || first.Status != StatusFailed || first.Error != "canon check failed" {
+		t.Fatalf("LoadAttemptManifest = %+v, %v, want the failed first attempt", first, err)
+	}
+	if _, err := LoadAttemptManifest(run.Dir, "2026-10-19-bbbb"); err != nil {
+		t.Errorf("LoadAttemptManifest of the latest attempt: %v", err)
+	}
+	if _, err := LoadAttemptManifest(run.Dir, "2026-10-19-cccc"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadAttemptManifest of an unknown attempt = %v, want not exist", err)
+	}
+	for _, id := range []string{"", "../2026-10-19", "a/b"} {
+		if _, err := LoadAttemptManifest(run.Dir, id); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("LoadAttemptManifest(%q) = %v, want it rejected", id, err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(run.Dir, "manifest*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("manifests = %v, want the latest and one kept", files)
+	}
+}
+
+func TestRebuildIndexReadsEveryAttempt(t *testing.T) {
+	run := newTestRun(t)
+	runsDir := filepath.Dir(run.Dir)
+
+	// A manifest from before correlation ids were recorded is kept under
+	// its start time
+	attempt(t, run, "", 5, nil)
+	attempt(t, run, "2026-10-19-aaaa", 6, errors.New("canon check failed"))
+	attempt(t, run, "2026-10-19-bbbb", 7, nil)
+	if _, err := os.Stat(filepath.Join(run.Dir, "manifest.20261019T050000Z.json")); err != nil {
+		t.Errorf("manifest without a correlation id not kept: %v", err)
+	}
+
+	other := NewRun(run.Config, run.Store, time.Date(2026, 10, 18, 6, 0, 0, 0, time.UTC))
+	attempt(t, other, "2026-10-18-cccc", 6, nil)
+
+	if err := os.Remove(filepath.Join(runsDir, IndexFile)); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := LoadIndex(runsDir)
+	if err != nil {
+		t.Fatalf("LoadIndex: %v", err)
+	}
+	want := []string{
+		"2026-10-19 2026-10-19-bbbb succeeded",
+		"2026-10-19 2026-10-19-aaaa failed",
+		"2026-10-19  succeeded",
+		"2026-10-18 2026-10-18-cccc succeeded",
+	}
+	if got := indexed(entries); !slices.Equal(got, want) {
+		t.Errorf("rebuilt index = %q, want %q", got, want)
+	}
+}
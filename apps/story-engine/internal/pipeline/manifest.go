@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// ManifestFile is the name of the manifest in a run's directory. It records
+// the date's latest attempt; each earlier attempt's manifest is kept beside
+// it as manifest.<correlation_id>.json.
+const ManifestFile = "manifest.json"
+
+// Manifest is the record of a run written to its directory.
+type Manifest struct {
+	RunID         string `json:"run_id"`
+	Story         string `json:"story,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ChapterNumber int    `json:"chapter_number,omitempty"`
+
+	// Status is StatusSucceeded or StatusFailed; Error is why it failed.
+	Status   string        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+	Duration time.Duration `json:"duration_ns"`
+
+	// Stages are the stages that ran, in order; NotRun are those after a
+	// failure.
+	Stages []StageResult `json:"stages"`
+	NotRun []string      `json:"not_run,omitempty"`
+
+	// Models and CostUSD total the model calls of every stage.
+	Models  []string `json:"models,omitempty"`
+	CostUSD float64  `json:"cost_usd"`
+
+	// Artifacts are the files the run wrote, relative to the data dir.
+	Artifacts []string `json:"artifacts,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+
+	Provenance *models.Provenance `json:"provenance,omitempty"`
+}
+
+// FailedStage returns the name of the stage that failed, if any.
+func (m *Manifest) FailedStage() string {
+	for _, s := range m.Stages {
+		if s.Status == StatusFailed {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// writeManifest writes the run's manifest, keeping the one of an earlier
+// attempt of the same date under that attempt's name, and updates the runs
+// index. notRun are the stages skipped after runErr.
+func (r *Run) writeManifest(notRun []string, runErr error) error {
+	m := Manifest{
+		RunID:         r.ID,
+		Story:         r.Story,
+		CorrelationID: r.CorrelationID,
+		Status:        StatusSucceeded,
+		Started:       r.Started,
+		Finished:      r.Finished,
+		Duration:      r.Finished.Sub(r.Started),
+		Stages:        r.Stages,
+		NotRun:        notRun,
+		Artifacts:     r.Artifacts,
+		Warnings:      r.Warnings,
+		Provenance:    r.Provenance,
+	}
+	if r.Chapter != nil {
+		m.ChapterNumber = r.Chapter.ChapterNumber
+	}
+	if runErr != nil {
+		m.Status = StatusFailed
+		m.Error = runErr.Error()
+	}
+	for _, s := range r.Stages {
+		m.CostUSD += s.CostUSD
+		for _, model := range s.Models {
+			if !slices.Contains(m.Models, model) {
+				m.Models = append(m.Models, model)
+			}
+		}
+	}
+	sort.Strings(m.Models)
+
+	if err := keepManifest(r.Dir, r.CorrelationID); err != nil {
+		return err
+	}
+	if err := storage.WriteJSON(filepath.Join(r.Dir, ManifestFile), m); err != nil {
+		return err
+	}
+	return UpdateIndex(filepath.Dir(r.Dir), &m)
+}
+
+// keepManifest renames the manifest in runDir, if it is another attempt's,
+// to that attempt's file so the next manifest does not replace it.
+func keepManifest(runDir, correlationID string) error {
+	prev, err := LoadManifest(runDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to keep the earlier manifest: %w", err)
+	}
+	if prev.CorrelationID != "" && prev.CorrelationID == correlationID {
+		return nil
+	}
+	if err := os.Rename(filepath.Join(runDir, ManifestFile), filepath.Join(runDir, attemptManifestFile(prev))); err != nil {
+		return fmt.Errorf("failed to keep the earlier manifest: %w", err)
+	}
+	return nil
+}
+
+// attemptManifestFile is the name an attempt's manifest is kept under once
+// a later attempt has run. Manifests from before correlation ids were
+// recorded are named by their start time.
+func attemptManifestFile(m *Manifest) string {
+	id := m.CorrelationID
+	if id == "" {
+		id = m.Started.UTC().Format("20060102T150405Z")
+	}
+	return "manifest." + id + ".json"
+}
+
+// LoadManifest reads the manifest of the latest attempt of the run in
+// runDir.
+func LoadManifest(runDir string) (*Manifest, error) {
+	var m Manifest
+	if err := storage.ReadJSON(filepath.Join(runDir, ManifestFile), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// LoadAttemptManifest reads the manifest of the attempt with the given
+// correlation id of the run in runDir.
+func LoadAttemptManifest(runDir, correlationID string) (*Manifest, error) {
+	if !filepath.IsLocal(correlationID) || strings.ContainsAny(correlationID, `/\`) {
+		return nil, fmt.Errorf("invalid correlation id %q", correlationID)
+	}
+	m, err := LoadManifest(runDir)
+	if err == nil && m.CorrelationID == correlationID {
+		return m, nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	var kept Manifest
+	if err := storage.ReadJSON(filepath.Join(runDir, "manifest."+correlationID+".json"), &kept); err != nil {
+		return nil, err
+	}
+	return &kept, nil
+}
+
+// LoadManifests reads the manifest of every attempt of the run in runDir,
+// oldest first.
+func LoadManifests(runDir string) ([]*Manifest, error) {
+	files, err := filepath.Glob(filepath.Join(runDir, "manifest.*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var manifests []*Manifest
+	for _, path := range append(files, filepath.Join(runDir, ManifestFile)) {
+		var m Manifest
+		err := storage.ReadJSON(path, &m)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, &m)
+	}
+	sort.SliceStable(manifests, func(i, j int) bool { return manifests[i].Started.Before(manifests[j].Started) })
+	return manifests, nil
+}
@@ -0,0 +1,211 @@
+// Package pipeline orchestrates the daily story run.
+//
+// A run is a sequence of stages executed in order against a shared Run.
+// Each stage reads what earlier stages produced from the Run and adds its
+// own output. The first stage to return an error stops the run.
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/costs"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// RunDateFormat is the layout of run ids and run directory names.
+const RunDateFormat = "2006-01-02"
+
+// Stage is one step of the daily pipeline.
+type Stage interface {
+	Name() string
+	Run(ctx context.Context, run *Run) error
+}
+
+// Run is the shared state of a single pipeline run.
+type Run struct {
+	ID string
+	// Story is the id of the registered story the run is for, empty in a
+	// single-story deployment.
+	Story  string
+	Date   time.Time
+	Dir    string
+	Config *config.Config
+	Store  *storage.Store
+
+	// CorrelationID tells apart repeated runs of the same date in logs.
+	CorrelationID string
+	// Logger is the run's logger; stages get it, with a stage attribute,
+	// from logging.FromContext.
+	Logger *slog.Logger
+
+	// Budget, when set, caps spend; see costs.Guard.
+	Budget *costs.Guard
+
+	// Provenance is the prompts and agent settings the run uses, recorded
+	// in the manifest and the chapter's archive record.
+	Provenance *models.Provenance
+
+	// Chapter is the current draft of today's chapter, once written.
+	Chapter *models.Chapter
+
+	// CanonViolations are canon check flags left unresolved by rewrites.
+	CanonViolations []agents.CanonViolation
+
+	// Hashtags are posted with the chapter, brand tag first.
+	Hashtags []string
+
+	// ImagePrompts are the prompts for the chapter's images, with the
+	// rationale for each shown in the daily email.
+	ImagePrompts []agents.ImagePrompt
+
+	// Warnings are problems that did not stop the run but should be
+	// surfaced in the daily email.
+	Warnings []string
+
+	// Artifacts are the files the run wrote, relative to the data dir,
+	// listed in its manifest.
+	Artifacts []string
+
+	// Started and Finished bound the run's execution; Stages records each
+	// stage that ran, in order, including the one that failed.
+	Started  time.Time
+	Finished time.Time
+	Stages   []StageResult
+}
+
+// Stage and run outcomes recorded in StageResult and the manifest.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// StageResult is the timing and outcome of one executed stage, with the
+// model calls it made. Cost is priced from monitoring.cost_tracking's
+// model_prices.
+type StageResult struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration_ns"`
+	Error    string        `json:"error,omitempty"`
+
+	Calls   int      `json:"calls,omitempty"`
+	Retries int      `json:"retries,omitempty"`
+	Models  []string `json:"models,omitempty"`
+	CostUSD float64  `json:"cost_usd,omitempty"`
+}
+
+// NewRun creates the state for a run on the given date.
+func NewRun(cfg *config.Config, store *storage.Store, date time.Time) *Run {
+	id := date.Format(RunDateFormat)
+	correlationID := logging.NewCorrelationID(id)
+	return &Run{
+		ID:            id,
+		Date:          date,
+		Dir:           store.DataPath(cfg.Paths.RunsDir, id),
+		Config:        cfg,
+		Store:         store,
+		CorrelationID: correlationID,
+		Logger:        slog.Default().With("run_id", correlationID),
+	}
+}
+
+// AddArtifact records a file the run wrote. Paths inside the data dir are
+// recorded relative to it.
+func (r *Run) AddArtifact(path string) {
+	if rel, err := filepath.Rel(r.Store.DataPath(), path); err == nil && filepath.IsLocal(rel) {
+		path = filepath.ToSlash(rel)
+	}
+	if !slices.Contains(r.Artifacts, path) {
+		r.Artifacts = append(r.Artifacts, path)
+	}
+}
+
+// Warn records a non-fatal problem for the daily report.
+func (r *Run) Warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	r.Warnings = append(r.Warnings, msg)
+	r.Logger.Warn(msg)
+}
+
+// Pipeline runs stages in order.
+type Pipeline struct {
+	stages []Stage
+}
+
+// New creates a pipeline from stages, run in the order given.
+func New(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// Execute runs each stage in turn, stopping at the first error. Stage
+// timings and calls are recorded in run.Stages, a checkpoint is written
+// after each stage that completes, and when the run ends, whether or not
+// it failed, its manifest is written and the runs index updated.
+func (p *Pipeline) Execute(ctx context.Context, run *Run) (err error) {
+	run.Started = time.Now()
+	defer func() {
+		run.Finished = time.Now()
+		var notRun []string
+		for _, stage := range p.stages[len(run.Stages):] {
+			notRun = append(notRun, stage.Name())
+		}
+		if manifestErr := run.writeManifest(notRun, err); manifestErr != nil {
+			run.Logger.Error("failed to write run manifest", "error", manifestErr)
+		}
+	}()
+
+	for _, stage := range p.stages {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("run cancelled before stage %s: %w", stage.Name(), err)
+		}
+
+		logger := run.Logger.With("stage", stage.Name())
+		start := time.Now()
+		logger.Info("stage starting")
+		calls := &agents.CallLog{}
+		err := stage.Run(agents.WithCallLog(logging.WithLogger(ctx, logger), calls), run)
+		result := StageResult{Name: stage.Name(), Status: StatusSucceeded, Started: start, Duration: time.Since(start)}
+		if err != nil {
+			result.Status = StatusFailed
+			result.Error = err.Error()
+		}
+		result.addCalls(calls.Calls(), run.Config.Monitoring.CostTracking)
+		run.Stages = append(run.Stages, result)
+		if err != nil {
+			return fmt.Errorf("stage %s failed: %w", stage.Name(), err)
+		}
+		logger.Info("stage completed", "duration", result.Duration.Round(time.Millisecond).String())
+
+		if run.Config.Pipeline.Checkpoints.Enabled {
+			if err := run.writeCheckpoint(stage.Name()); err != nil {
+				logger.Error("failed to write checkpoint", "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// addCalls adds the stage's model calls to its result.
+func (r *StageResult) addCalls(calls []agents.Call, prices config.CostTrackingConfig) {
+	for _, c := range calls {
+		r.Calls++
+		r.Retries += max(c.Attempts-1, 0)
+		r.CostUSD += costs.LLMCost(prices, c.Model, c.Usage)
+		if !slices.Contains(r.Models, c.Model) {
+			r.Models = append(r.Models, c.Model)
+		}
+	}
+	sort.Strings(r.Models)
+}
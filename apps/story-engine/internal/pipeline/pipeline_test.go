@@ -0,0 +1,223 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/agents"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/hashtags"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// printingPress is the violation the fake checker reports until the
+// chapter no longer mentions a printing press.
+var printingPress = agents.CanonViolation{
+	Rule:        "technology_level",
+	RuleText:    "No printing press exists",
+	Quote:       "The printing press clattered.",
+	Explanation: "Books are copied by hand.",
+}
+
+type fakeChecker struct{ calls int }
+
+func (c *fakeChecker) Check(ctx context.Context, bible *models.StoryBible, chapter *models.Chapter) ([]agents.CanonViolation, error) {
+	c.calls++
+	if strings.Contains(chapter.Text, "printing press") {
+		return []agents.CanonViolation{printingPress}, nil
+	}
+	return nil, nil
+}
+
+// fakeReviser "revises" a chapter without changing it, so the flag stays.
+type fakeReviser struct{ feedback []string }
+
+func (r *fakeReviser) Revise(ctx context.Context, draft *models.Chapter, feedback []string) (*models.Chapter, error) {
+	r.feedback = append(r.feedback, feedback...)
+	revised := *draft
+	return &revised, nil
+}
+
+type fakeHashtags struct{}
+
+func (fakeHashtags) Categories() []string { return []string{"story", "genre", "general"} }
+
+func (fakeHashtags) Candidates(ctx context.Context, bible *models.StoryBible, chapter *models.Chapter) (*hashtags.Candidates, error) {
+	return &hashtags.Candidates{
+		Story:   []string{"#thornwood", "#mirathorne"},
+		Genre:   []string{"#fantasy", "#serialfiction"},
+		General: []string{"#amreading", "#bookstagram"},
+	}, nil
+}
+
+type fakeImages struct{}
+
+func (fakeImages) Generate(ctx context.Context, chapter *models.Chapter, visuals []agents.EntityVisual, count int) ([]agents.ImagePrompt, error) {
+	prompts := make([]agents.ImagePrompt, count)
+	for i := range prompts {
+		prompts[i] = agents.ImagePrompt{Shot: "establishing", Prompt: "a forest at dusk", Rationale: "sets the scene"}
+	}
+	return prompts, nil
+}
+
+type fakeReporter struct {
+	subject, body string
+	sent          int
+}
+
+func (r *fakeReporter) Report(ctx context.Context, subject, body string) error {
+	r.subject, r.body = subject, body
+	r.sent++
+	return nil
+}
+
+// newTestRun creates a run over a data dir holding a minimal story bible.
+func newTestRun(t *testing.T) *Run {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.Paths = config.PathsConfig{
+		DataDir:     dir,
+		StoryBible:  "story_bible.json",
+		EntitiesDir: "entities",
+		ChaptersDir: "archive/chapters",
+		RunsDir:     "runs",
+	}
+	cfg.Pipeline.Validation = config.ValidationConfig{CanonCheckEnabled: true, CanonCheckAction: "rewrite", CanonRetryAttempts: 1}
+	cfg.Pipeline.Hashtags = config.HashtagsConfig{CountMin: 3, CountMax: 5, MaxTotalCharacters: 200, BrandTag: "#thornwoodserial"}
+	cfg.Pipeline.Context.MaxEntities = 5
+	cfg.ImageGeneration.ImagesPerChapter = 2
+
+	if err := os.MkdirAll(filepath.Join(dir, "entities"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "story_bible.json"), []byte(`{"meta": {"story_title": "Thornwood"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return NewRun(cfg, storage.New(cfg.Paths), time.Date(2026, 10, 19, 6, 0, 0, 0, time.UTC))
+}
+
+func writeChapter(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chapter.json")
+	// Drafts are hand-edited, so comments are allowed
+	data := `{
+		// drafted outside the engine
+		"chapter_number": 16,
+		"title": "The Letter",
+		"text": "` + text + `",
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecuteEndToEnd(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantFlags    int
+		wantRevision bool
+		wantInReport []string
+	}{
+		{
+			name:         "clean chapter",
+			text:         "Mira read the letter by candlelight.",
+			wantInReport: []string{"chapter 16", "#thornwoodserial", "sets the scene"},
+		},
+		{
+			name:         "canon flag survives rewrite",
+			text:         "The printing press clattered. Mira read the letter.",
+			wantFlags:    1,
+			wantRevision: true,
+			wantInReport: []string{"1 canon flags", "technology_level", printingPress.Quote, "Books are copied by hand."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run := newTestRun(t)
+			checker, reviser, reporter := &fakeChecker{}, &fakeReviser{}, &fakeReporter{}
+			p := New(
+				DraftStage{Path: writeChapter(t, tt.text)},
+				CanonStage{Checker: checker, Writer: reviser},
+				HashtagStage{Source: fakeHashtags{}},
+				ImagePromptStage{Writer: fakeImages{}},
+				ArchiveStage{},
+				ReportStage{Reporter: reporter},
+			)
+
+			if err := p.Execute(context.Background(), run); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+
+			if run.Chapter == nil || run.Chapter.ChapterNumber != 16 {
+				t.Fatalf("Chapter = %+v, want chapter 16 from the draft", run.Chapter)
+			}
+			if got := len(run.CanonViolations); got != tt.wantFlags {
+				t.Errorf("got %d canon flags, want %d", got, tt.wantFlags)
+			}
+			if got := len(reviser.feedback) > 0; got != tt.wantRevision {
+				t.Errorf("chapter sent back to the writer = %v, want %v", got, tt.wantRevision)
+			}
+			if len(run.Hashtags) == 0 || run.Hashtags[0] != "#thornwoodserial" {
+				t.Errorf("Hashtags = %v, want the brand tag first", run.Hashtags)
+			}
+			if len(run.ImagePrompts) != 2 {
+				t.Errorf("got %d image prompts, want 2", len(run.ImagePrompts))
+			}
+			if _, err := run.Store.LoadChapterRecord(16); err != nil {
+				t.Errorf("chapter not archived: %v", err)
+			}
+
+			if reporter.sent != 1 {
+				t.Fatalf("daily report sent %d times, want 1", reporter.sent)
+			}
+			report := reporter.subject + "\n" + reporter.body
+			for _, want := range tt.wantInReport {
+				if !strings.Contains(report, want) {
+					t.Errorf("daily report does not contain %q:\n%s", want, report)
+				}
+			}
+
+			m, err := LoadManifest(run.Dir)
+			if err != nil {
+				t.Fatalf("LoadManifest: %v", err)
+			}
+			if m.Status != StatusSucceeded || len(m.Stages) != 6 || m.ChapterNumber != 16 {
+				t.Errorf("manifest = %s with %d stages for chapter %d, want succeeded with 6 for chapter 16", m.Status, len(m.Stages), m.ChapterNumber)
+			}
+		})
+	}
+}
+
+func TestExecuteWithoutChapter(t *testing.T) {
+	run := newTestRun(t)
+	checker, reporter := &fakeChecker{}, &fakeReporter{}
+	p := New(
+		DraftStage{},
+		CanonStage{Checker: checker},
+		HashtagStage{Source: fakeHashtags{}},
+		ArchiveStage{},
+		ReportStage{Reporter: reporter},
+	)
+
+	if err := p.Execute(context.Background(), run); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if checker.calls != 0 || len(run.Hashtags) != 0 {
+		t.Errorf("chapter stages ran without a chapter: %d canon checks, hashtags %v", checker.calls, run.Hashtags)
+	}
+	if len(run.Warnings) != 1 || !strings.Contains(run.Warnings[0], "no chapter drafted") {
+		t.Errorf("Warnings = %q, want the missing writer stage reported", run.Warnings)
+	}
+	if !strings.Contains(reporter.subject, "no chapter") || !strings.Contains(reporter.body, "planner and writer stages are not built yet") {
+		t.Errorf("daily report = %q\n%s, want the missing chapter explained", reporter.subject, reporter.body)
+	}
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/logging"
+)
+
+// Reporter sends the daily report.
+type Reporter interface {
+	Report(ctx context.Context, subject, body string) error
+}
+
+// ReportStage emails the daily report: the chapter, canon flags left after
+// rewrites with the rule and quote, hashtags, image prompts with their
+// rationale, and the run's warnings. A report that fails to send is a
+// warning, not a failed run.
+type ReportStage struct {
+	Reporter Reporter
+}
+
+func (ReportStage) Name() string { return "report" }
+
+func (s ReportStage) Run(ctx context.Context, run *Run) error {
+	subject, body := DailyReport(run)
+	if err := s.Reporter.Report(ctx, subject, body); err != nil {
+		run.Warn("report: %v", err)
+		return nil
+	}
+	logging.FromContext(ctx).Info("sent daily report", "canon_flags", len(run.CanonViolations), "warnings", len(run.Warnings))
+	return nil
+}
+
+// DailyReport renders the run's daily report.
+func DailyReport(run *Run) (subject, body string) {
+	var b strings.Builder
+	name := "Daily report"
+	if run.Story != "" {
+		name += " (" + run.Story + ")"
+	}
+
+	if run.Chapter == nil {
+		subject = fmt.Sprintf("%s %s: no chapter", name, run.ID)
+		b.WriteString("No chapter was drafted today.\n")
+	} else {
+		subject = fmt.Sprintf("%s %s: chapter %d", name, run.ID, run.Chapter.ChapterNumber)
+		if len(run.CanonViolations) > 0 {
+			subject += fmt.Sprintf(", %d canon flags", len(run.CanonViolations))
+		}
+		fmt.Fprintf(&b, "Chapter %d: %s\n%d characters\n", run.Chapter.ChapterNumber, run.Chapter.Title, run.Chapter.CharacterCount)
+	}
+
+	if len(run.CanonViolations) > 0 {
+		b.WriteString("\nCanon flags (review before posting):\n")
+		for _, v := range run.CanonViolations {
+			fmt.Fprintf(&b, "- %s: %s\n  Quote: %q\n  %s\n", v.Rule, v.RuleText, v.Quote, v.Explanation)
+		}
+	}
+	if len(run.Hashtags) > 0 {
+		fmt.Fprintf(&b, "\nHashtags: %s\n", strings.Join(run.Hashtags, " "))
+	}
+	if len(run.ImagePrompts) > 0 {
+		b.WriteString("\nImages:\n")
+		for _, p := range run.ImagePrompts {
+			fmt.Fprintf(&b, "- %s: %s\n", p.Shot, p.Rationale)
+		}
+	}
+	if len(run.Warnings) > 0 {
+		b.WriteString("\nWarnings:\n")
+		for _, w := range run.Warnings {
+			fmt.Fprintf(&b, "- %s\n", w)
+		}
+	}
+	fmt.Fprintf(&b, "\nRun %s\n", run.CorrelationID)
+
+	return subject, b.String()
+}
@@ -0,0 +1,160 @@
+// Package prompts renders agent system prompts from templates.
+//
+// Prompt files in paths.prompts_dir are Go text/templates. A prompt can pull
+// in a shared partial with {{include "craft_rules.md"}} and use typed values
+// from the config and the story bible:
+//
+//	{{.Story.TargetChapterLength}}  pipeline.story.target_chapter_length
+//	{{.Hashtags.CountMax}}          pipeline.hashtags.count_max
+//	{{.Bible.Meta.StoryTitle}}      the story bible's title
+//
+// A file of the same name in paths.prompts_override_dir, which each story
+// of a multi-story deployment can set, replaces the one in prompts_dir, for
+// prompts and partials alike.
+//
+// Rendering is strict: an unknown field, a missing map key, a missing
+// partial or a nil bible is an error, so a broken prompt fails at startup
+// instead of reaching the model.
+
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/internal/storage"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// maxIncludeDepth bounds nested includes; deeper nesting is almost
+// certainly a mistake.
+const maxIncludeDepth = 8
+
+// ErrNoBible is returned when a prompt uses the story bible before the
+// story has been created.
+var ErrNoBible = errors.New("there is no story bible yet, create one with storygen init")
+
+// Vars are the values a prompt template can use.
+type Vars struct {
+	Story    config.StoryConfig
+	Hashtags config.HashtagsConfig
+
+	bible *models.StoryBible
+}
+
+// Bible returns the story bible. It is ErrNoBible until the story has been
+// created, so only prompts of agents that run on an existing story may use
+// it.
+func (v Vars) Bible() (*models.StoryBible, error) {
+	if v.bible == nil {
+		return nil, ErrNoBible
+	}
+	return v.bible, nil
+}
+
+// NewVars collects the template values from the config and the story bible.
+func NewVars(cfg *config.Config) (Vars, error) {
+	vars := Vars{Story: cfg.Pipeline.Story, Hashtags: cfg.Pipeline.Hashtags}
+	bible, err := storage.New(cfg.Paths).LoadStoryBible()
+	switch {
+	case err == nil:
+		vars.bible = bible
+	case !errors.Is(err, fs.ErrNotExist):
+		return Vars{}, fmt.Errorf("failed to load story bible: %w", err)
+	}
+	return vars, nil
+}
+
+// Engine renders the templates in a prompts directory and its overrides.
+type Engine struct {
+	paths config.PathsConfig
+	vars  Vars
+}
+
+// New creates an engine for the templates in paths.prompts_dir and
+// paths.prompts_override_dir.
+func New(paths config.PathsConfig, vars Vars) *Engine {
+	return &Engine{paths: paths, vars: vars}
+}
+
+// Render renders the named prompt and the partials it includes.
+func (e *Engine) Render(name string) (string, error) {
+	return e.render(name, nil)
+}
+
+// render renders name; stack holds the prompts that are including it.
+func (e *Engine) render(name string, stack []string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("prompt %q must be a relative path inside the prompts directory", name)
+	}
+	if slices.Contains(stack, name) {
+		return "", fmt.Errorf("include cycle: %s", strings.Join(append(stack, name), " -> "))
+	}
+	if len(stack) >= maxIncludeDepth {
+		return "", fmt.Errorf("includes nested more than %d deep at %s", maxIncludeDepth, name)
+	}
+	stack = append(slices.Clone(stack), name)
+
+	data, err := os.ReadFile(e.paths.PromptPath(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt %s: %w", name, err)
+	}
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"include": func(partial string) (string, error) { return e.render(partial, stack) },
+			"join":    strings.Join,
+		}).
+		Parse(string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt %s: %w", name, err)
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, e.vars); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+	return out.String(), nil
+}
+
+// Render renders an agent prompt with the values of cfg and the current
+// story bible.
+func Render(cfg *config.Config, name string) (string, error) {
+	vars, err := NewVars(cfg)
+	if err != nil {
+		return "", err
+	}
+	return New(cfg.Paths, vars).Render(name)
+}
+
+// Check renders the prompt of every configured agent and returns every
+// failure, so a broken template is caught before a run starts.
+func Check(cfg *config.Config) error {
+	vars, err := NewVars(cfg)
+	if err != nil {
+		return err
+	}
+	engine := New(cfg.Paths, vars)
+
+	names := make([]string, 0, len(cfg.Agents))
+	for name := range cfg.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if _, err := engine.Render(cfg.Agents[name].Prompt); err != nil {
+			errs = append(errs, fmt.Errorf("agents.%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
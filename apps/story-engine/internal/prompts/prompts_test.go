@@ -0,0 +1,215 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// writeFiles writes each name: content pair under dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// includeChain returns n prompts, c0.md to c<n-1>.md, each including the
+// next.
+func includeChain(n int) map[string]string {
+	files := make(map[string]string, n)
+	for i := range n - 1 {
+		files[fmt.Sprintf("c%d.md", i)] = fmt.Sprintf(`{{include "c%d.md"}}`, i+1)
+	}
+	files[fmt.Sprintf("c%d.md", n-1)] = "end"
+	return files
+}
+
+func TestRender(t *testing.T) {
+	bible := &models.StoryBible{
+		Meta:     models.StoryMeta{StoryTitle: "The Thornwood Chronicles"},
+		Universe: models.Universe{Rules: map[string]any{"magic": "rare"}},
+	}
+
+	tests := []struct {
+		name      string
+		files     map[string]string
+		overrides map[string]string
+		bible     *models.StoryBible
+		render    string
+		want      string
+		wantErr   string
+		wantIs    error
+	}{
+		{
+			name:   "config values",
+			files:  map[string]string{"p.md": "{{.Story.TargetChapterLength}} chars, {{.Hashtags.CountMax}} tags"},
+			render: "p.md",
+			want:   "1800 chars, 5 tags",
+		},
+		{
+			name:   "include",
+			files:  map[string]string{"p.md": `Rules: {{include "rules.md"}}`, "rules.md": "show, don't tell"},
+			render: "p.md",
+			want:   "Rules: show, don't tell",
+		},
+		{
+			name:   "bible",
+			files:  map[string]string{"p.md": "{{.Bible.Meta.StoryTitle}}: magic is {{.Bible.Universe.Rules.magic}}"},
+			bible:  bible,
+			render: "p.md",
+			want:   "The Thornwood Chronicles: magic is rare",
+		},
+		{
+			name:   "nil bible",
+			files:  map[string]string{"p.md": "{{.Bible.Meta.StoryTitle}}"},
+			render: "p.md",
+			wantIs: ErrNoBible,
+		},
+		{
+			name:   "nil bible in a partial",
+			files:  map[string]string{"p.md": `{{include "tone.md"}}`, "tone.md": "{{with .Bible.Meta.Tone}}{{join . \", \"}}{{end}}"},
+			render: "p.md",
+			wantIs: ErrNoBible,
+		},
+		{
+			name:    "missing variable",
+			files:   map[string]string{"p.md": "{{.Story.ChapterLength}}"},
+			render:  "p.md",
+			wantErr: "can't evaluate field ChapterLength",
+		},
+		{
+			name:    "missing map key",
+			files:   map[string]string{"p.md": "{{.Bible.Universe.Rules.technology}}"},
+			bible:   bible,
+			render:  "p.md",
+			wantErr: `map has no entry for key "technology"`,
+		},
+		{
+			name:    "missing include",
+			files:   map[string]string{"p.md": `{{include "missing.md"}}`},
+			render:  "p.md",
+			wantErr: "failed to read prompt missing.md",
+		},
+		{
+			name:    "missing prompt",
+			render:  "p.md",
+			wantErr: "failed to read prompt p.md",
+		},
+		{
+			name:    "include cycle",
+			files:   map[string]string{"a.md": `{{include "b.md"}}`, "b.md": `{{include "a.md"}}`},
+			render:  "a.md",
+			wantErr: "include cycle: a.md -> b.md -> a.md",
+		},
+		{
+			name:   "includes at the depth limit",
+			files:  includeChain(maxIncludeDepth),
+			render: "c0.md",
+			want:   "end",
+		},
+		{
+			name:    "includes past the depth limit",
+			files:   includeChain(maxIncludeDepth + 1),
+			render:  "c0.md",
+			wantErr: fmt.Sprintf("includes nested more than %d deep at c%d.md", maxIncludeDepth, maxIncludeDepth),
+		},
+		{
+			name:    "include outside the prompts directory",
+			files:   map[string]string{"p.md": `{{include "../secrets.json"}}`},
+			render:  "p.md",
+			wantErr: "must be a relative path inside the prompts directory",
+		},
+		{
+			name:      "override replaces a prompt",
+			files:     map[string]string{"p.md": "base"},
+			overrides: map[string]string{"p.md": "override"},
+			render:    "p.md",
+			want:      "override",
+		},
+		{
+			name:      "override replaces a partial of a base prompt",
+			files:     map[string]string{"p.md": `Rules: {{include "rules.md"}}`, "rules.md": "base rules"},
+			overrides: map[string]string{"rules.md": "story rules"},
+			render:    "p.md",
+			want:      "Rules: story rules",
+		},
+		{
+			name:      "base partial of an override prompt",
+			files:     map[string]string{"p.md": "base", "rules.md": "base rules"},
+			overrides: map[string]string{"p.md": `Story: {{include "rules.md"}}`},
+			render:    "p.md",
+			want:      "Story: base rules",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := config.PathsConfig{PromptsDir: t.TempDir()}
+			writeFiles(t, paths.PromptsDir, tt.files)
+			if tt.overrides != nil {
+				paths.PromptsOverrideDir = t.TempDir()
+				writeFiles(t, paths.PromptsOverrideDir, tt.overrides)
+			}
+			vars := Vars{
+				Story:    config.StoryConfig{TargetChapterLength: 1800},
+				Hashtags: config.HashtagsConfig{CountMax: 5},
+				bible:    tt.bible,
+			}
+
+			got, err := New(paths, vars).Render(tt.render)
+			switch {
+			case tt.wantIs != nil:
+				if !errors.Is(err, tt.wantIs) {
+					t.Fatalf("Render = %q, %v, want %v", got, err, tt.wantIs)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render = %q, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("Render: %v", err)
+			case got != tt.want:
+				t.Errorf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewVarsBible(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Paths: config.PathsConfig{DataDir: dir, StoryBible: "story_bible.json"}}
+
+	vars, err := NewVars(cfg)
+	if err != nil {
+		t.Fatalf("NewVars without a bible: %v", err)
+	}
+	if _, err := vars.Bible(); !errors.Is(err, ErrNoBible) {
+		t.Errorf("Bible() error = %v, want ErrNoBible", err)
+	}
+
+	writeFiles(t, dir, map[string]string{"story_bible.json": `{"meta": {"story_title": "The Thornwood Chronicles"}}`})
+	if vars, err = NewVars(cfg); err != nil {
+		t.Fatalf("NewVars: %v", err)
+	}
+	if bible, err := vars.Bible(); err != nil || bible.Meta.StoryTitle != "The Thornwood Chronicles" {
+		t.Errorf("Bible() = %+v, %v, want the story bible", bible, err)
+	}
+
+	writeFiles(t, dir, map[string]string{"story_bible.json": "{"})
+	if _, err := NewVars(cfg); err == nil {
+		t.Error("NewVars with a malformed bible succeeded, want an error")
+	}
+}
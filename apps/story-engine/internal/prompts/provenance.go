@@ -0,0 +1,178 @@
+package prompts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// Provenance hashes every prompt file, overrides in place of the files they
+// replace, and records the rendered prompt, model, temperature and thinking
+// budget of each configured agent. Models are the configured ones; a budget
+// downgrade during the run is not reflected.
+func Provenance(cfg *config.Config) (*models.Provenance, error) {
+	p := &models.Provenance{
+		Prompts: make(map[string]string),
+		Agents:  make(map[string]models.AgentProvenance),
+	}
+
+	for _, dir := range []string{cfg.Paths.PromptsDir, cfg.Paths.PromptsOverrideDir} {
+		if dir == "" {
+			continue
+		}
+		if err := hashDir(dir, p.Prompts); err != nil {
+			return nil, fmt.Errorf("failed to hash prompts: %w", err)
+		}
+	}
+
+	vars, err := NewVars(cfg)
+	if err != nil {
+		return nil, err
+	}
+	engine := New(cfg.Paths, vars)
+	for name, agent := range cfg.Agents {
+		prompt, err := engine.Render(agent.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("agents.%s: %w", name, err)
+		}
+		a := models.AgentProvenance{
+			Prompt:     agent.Prompt,
+			PromptHash: hash([]byte(prompt)),
+			Model:      cfg.AgentModel(agent),
+		}
+		// Mirrors how agents build their requests
+		if agent.UseThinking && cfg.Anthropic.Thinking.Enabled {
+			a.ThinkingBudget = agent.ThinkingBudget
+		} else {
+			a.Temperature = agent.Temperature
+		}
+		p.Agents[name] = a
+	}
+	return p, nil
+}
+
+// hashDir records the hash of every file in dir by its slash-separated
+// path relative to dir, replacing any already recorded.
+func hashDir(dir string, hashes map[string]string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(rel)] = hash(data)
+		return nil
+	})
+}
+
+// Change is one difference between two provenances: a prompt file that was
+// added, removed or edited, or an agent setting that changed.
+type Change struct {
+	// Subject is a prompt file, or agents.<name> for an agent.
+	Subject string `json:"subject"`
+	// Field is the agent setting that changed, empty for a prompt file or
+	// an agent that was added or removed.
+	Field string `json:"field,omitempty"`
+	// From and To are the old and new values; From is empty for an
+	// addition and To for a removal.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+func (c Change) String() string {
+	key := c.Subject
+	if c.Field != "" {
+		key += "." + c.Field
+	}
+	switch {
+	case c.From == "":
+		return key + ": added"
+	case c.To == "":
+		return key + ": removed"
+	}
+	return fmt.Sprintf("%s: %s -> %s", key, short(c.From), short(c.To))
+}
+
+// Diff lists what changed from a to b, prompt files first, each group
+// sorted by name.
+func Diff(a, b *models.Provenance) []Change {
+	var changes []Change
+	for _, file := range unionKeys(a.Prompts, b.Prompts) {
+		if from, to := a.Prompts[file], b.Prompts[file]; from != to {
+			changes = append(changes, Change{Subject: file, From: from, To: to})
+		}
+	}
+
+	for _, name := range unionKeys(a.Agents, b.Agents) {
+		subject := "agents." + name
+		from, inA := a.Agents[name]
+		to, inB := b.Agents[name]
+		switch {
+		case !inA:
+			changes = append(changes, Change{Subject: subject, To: to.Model})
+			continue
+		case !inB:
+			changes = append(changes, Change{Subject: subject, From: from.Model})
+			continue
+		}
+		for _, f := range []struct{ field, from, to string }{
+			{"prompt", from.Prompt, to.Prompt},
+			{"prompt_hash", from.PromptHash, to.PromptHash},
+			{"model", from.Model, to.Model},
+			{"temperature", formatFloat(from.Temperature), formatFloat(to.Temperature)},
+			{"thinking_budget", strconv.Itoa(from.ThinkingBudget), strconv.Itoa(to.ThinkingBudget)},
+		} {
+			if f.from != f.to {
+				changes = append(changes, Change{Subject: subject, Field: f.field, From: f.from, To: f.to})
+			}
+		}
+	}
+	return changes
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		seen[k] = true
+	}
+	for k := range b {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// short abbreviates a SHA-256 hash for display.
+func short(s string) string {
+	if len(s) == sha256.Size*2 {
+		return s[:12]
+	}
+	return s
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"0 6 * *", "want 5 fields, got 4"},
+		{"0 6 * * * *", "want 5 fields, got 6"},
+		{"60 6 * * *", "minute"},
+		{"0 24 * * *", "hour"},
+		{"0 6 0 * *", "day of month"},
+		{"0 6 * 13 *", "month"},
+		{"0 6 * * 8", "day of week"},
+		{"0 6 * * mon", "day of week: invalid value"},
+		{"*/0 6 * * *", "invalid step"},
+		{"0 9-5 * * *", "outside"},
+		{"0 6 * * 1-", "invalid value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// 2026-10-19 is a Monday
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"later today", "0 6 * * *", at("2026-10-19 05:59"), at("2026-10-19 06:00")},
+		{"strictly after", "0 6 * * *", at("2026-10-19 06:00"), at("2026-10-20 06:00")},
+		{"seconds dropped", "0 6 * * *", at("2026-10-19 05:59").Add(30 * time.Second), at("2026-10-19 06:00")},
+		{"step", "*/15 * * * *", at("2026-10-19 06:16"), at("2026-10-19 06:30")},
+		{"range with step", "0-30/10 9 * * *", at("2026-10-19 09:21"), at("2026-10-19 09:30")},
+		{"list", "0 8,20 * * *", at("2026-10-19 08:01"), at("2026-10-19 20:00")},
+		{"hour rollover", "0 6 * * *", at("2026-10-19 23:59"), at("2026-10-20 06:00")},
+		{"weekdays from saturday", "0 7 * * 1-5", at("2026-10-24 08:00"), at("2026-10-26 07:00")},
+		{"sunday as 7", "0 7 * * 7", at("2026-10-19 08:00"), at("2026-10-25 07:00")},
+		{"sunday as 0", "0 7 * * 0", at("2026-10-19 08:00"), at("2026-10-25 07:00")},
+		{"month rollover", "0 0 1 * *", at("2026-10-19 00:00"), at("2026-11-01 00:00")},
+		{"year rollover", "30 4 1 1 *", at("2026-10-19 00:00"), at("2027-01-01 04:30")},
+		{"leap day", "0 0 29 2 *", at("2026-10-19 00:00"), at("2028-02-29 00:00")},
+		// Both day fields restricted: either matches
+		{"day of month or week", "0 6 1 * 5", at("2026-10-19 00:00"), at("2026-10-23 06:00")},
+		// A day field starting with * is not a restriction: both must match
+		{"stepped day of month and week", "0 6 */2 * 5", at("2026-10-19 00:00"), at("2026-10-23 06:00")},
+		{"day of month and stepped week", "0 6 22 * */2", at("2026-10-19 00:00"), at("2026-10-22 06:00")},
+		{"never", "0 0 31 2 *", at("2026-10-19 00:00"), time.Time{}},
+		{"location kept", "0 6 * * *", time.Date(2026, 10, 19, 7, 0, 0, 0, london), time.Date(2026, 10, 20, 6, 0, 0, 0, london)},
+		{"across clocks going back", "0 6 * * *", time.Date(2026, 10, 24, 7, 0, 0, 0, london), time.Date(2026, 10, 25, 6, 0, 0, 0, london)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			got := c.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got, tt.want)
+			}
+			if !got.IsZero() && got.Location() != tt.from.Location() {
+				t.Errorf("Next returned a time in %s, want %s", got.Location(), tt.from.Location())
+			}
+		})
+	}
+}
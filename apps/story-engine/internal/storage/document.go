@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Object is a decoded JSON object that keeps its keys in file order, for
+// editing hand-maintained files without reshuffling them. Decoded values
+// are *Object, []any, string, json.Number, bool or nil; Set also takes any
+// value encoding/json can marshal.
+type Object struct {
+	keys   []string
+	values map[string]any
+}
+
+// NewObject creates an empty Object.
+func NewObject() *Object {
+	return &Object{values: make(map[string]any)}
+}
+
+// ReadObject reads the JSON object at path, preserving key order. Comments
+// and trailing commas are accepted as in ReadJSON.
+func ReadObject(path string) (*Object, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	clean, err := StripJSONC(data)
+	if err != nil {
+		return nil, parseError(path, data, err)
+	}
+	v, err := DecodeOrdered(clean)
+	if err != nil {
+		return nil, parseError(path, data, err)
+	}
+	obj, ok := v.(*Object)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse %s: expected a JSON object", path)
+	}
+	return obj, nil
+}
+
+// DecodeOrdered decodes a JSON value, representing objects as *Object.
+func DecodeOrdered(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeOrderedValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	return v, nil
+}
+
+func decodeOrderedValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := NewObject()
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			val, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", key, err)
+			}
+			obj.Set(key, val)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			val, err := decodeOrderedValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// Keys returns the object's keys in order.
+func (o *Object) Keys() []string {
+	return append([]string(nil), o.keys...)
+}
+
+// Get returns the value stored under key.
+func (o *Object) Get(key string) (any, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// Set stores v under key. New keys are appended; existing keys keep their
+// position.
+func (o *Object) Set(key string, v any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// Delete removes key.
+func (o *Object) Delete(key string) {
+	if _, ok := o.values[key]; !ok {
+		return
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Path returns the nested object at the given key path, creating missing
+// objects along the way. It returns nil if a key holds a non-object value.
+func (o *Object) Path(keys ...string) *Object {
+	cur := o
+	for _, key := range keys {
+		v, ok := cur.values[key]
+		if !ok {
+			next := NewObject()
+			cur.Set(key, next)
+			cur = next
+			continue
+		}
+		next, ok := v.(*Object)
+		if !ok {
+			return nil
+		}
+		cur = next
+	}
+	return cur
+}
+
+// Walk calls fn for every object in v, depth first, parents before children.
+func Walk(v any, fn func(*Object)) {
+	switch t := v.(type) {
+	case *Object:
+		fn(t)
+		for _, key := range t.keys {
+			Walk(t.values[key], fn)
+		}
+	case []any:
+		for _, item := range t {
+			Walk(item, fn)
+		}
+	}
+}
+
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := marshalNoEscape(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		val, err := marshalNoEscape(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalNoEscape encodes v without escaping <, > and &, matching WriteJSON.
+func marshalNoEscape(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// IsNoteKey reports whether key is an annotation for human editors, such as
+// "_note", "_template_note" or "_coordinates_note".
+func IsNoteKey(key string) bool {
+	return strings.HasPrefix(key, "_") && strings.HasSuffix(key, "_note")
+}
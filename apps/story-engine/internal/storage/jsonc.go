@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// StripJSONC turns JSON with comments into plain JSON: // and /* */
+// comments and trailing commas before } or ] are blanked out. Everything is
+// replaced byte for byte with spaces, keeping newlines, so decoder offsets
+// still point at the right line and column of the original file.
+func StripJSONC(data []byte) ([]byte, error) {
+	out := bytes.Clone(data)
+	// pendingComma is the offset of a comma that may turn out to be trailing
+	pendingComma := -1
+
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			pendingComma = -1
+			end, err := skipString(out, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			start := i
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; ; i++ {
+				if i+1 >= len(out) {
+					return nil, &jsoncError{offset: int64(start) + 1, msg: "unterminated comment"}
+				}
+				if out[i] == '*' && out[i+1] == '/' {
+					out[i], out[i+1] = ' ', ' '
+					i++
+					break
+				}
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+
+		case c == ',':
+			pendingComma = i
+
+		case c == '}' || c == ']':
+			if pendingComma >= 0 {
+				out[pendingComma] = ' '
+			}
+			pendingComma = -1
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			// whitespace does not end a trailing comma
+
+		default:
+			pendingComma = -1
+		}
+	}
+	return out, nil
+}
+
+// jsoncError is a comment or string that never ends.
+type jsoncError struct {
+	offset int64
+	msg    string
+}
+
+func (e *jsoncError) Error() string { return e.msg }
+
+// skipString returns the offset of the closing quote of the string that
+// starts at data[start].
+func skipString(data []byte, start int) (int, error) {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i, nil
+		case '\n':
+			return 0, &jsoncError{offset: int64(start) + 1, msg: "unterminated string"}
+		}
+	}
+	return 0, &jsoncError{offset: int64(start) + 1, msg: "unterminated string"}
+}
+
+// parseError names the file and, when the decoder reported an offset, the
+// line and column of a parse failure.
+func parseError(path string, data []byte, err error) error {
+	var offset int64 = -1
+	var jsoncErr *jsoncError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &jsoncErr):
+		offset = jsoncErr.offset
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	line, col := lineCol(data, offset)
+	return fmt.Errorf("failed to parse %s:%d:%d: %w", path, line, col, err)
+}
+
+// lineCol converts a byte offset into a 1-based line and column. The json
+// package reports the offset just past the offending byte.
+func lineCol(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n') - 1
+	return line, max(col, 1)
+}
+
+// decodeJSONC decodes JSON with comments and trailing commas into v.
+func decodeJSONC(path string, data []byte, v any) error {
+	clean, err := StripJSONC(data)
+	if err != nil {
+		return parseError(path, data, err)
+	}
+	if err := json.Unmarshal(clean, v); err != nil {
+		return parseError(path, data, err)
+	}
+	return nil
+}
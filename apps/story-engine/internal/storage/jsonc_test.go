@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{name: "plain", in: `{"a": [1, 2]}`, want: `{"a": [1, 2]}`},
+		{name: "line comment", in: "{\"a\": 1 // note\n}", want: `{"a": 1}`},
+		{name: "block comment", in: "{/* one\ntwo */\"a\": 1}", want: `{"a": 1}`},
+		{name: "trailing comma in object", in: `{"a": 1, "b": 2,}`, want: `{"a": 1, "b": 2}`},
+		{name: "trailing comma in array", in: "[1, 2,\n]", want: `[1, 2]`},
+		{name: "trailing comma before comment", in: "[1, 2, // last\n]", want: `[1, 2]`},
+		{name: "nested trailing commas", in: `{"a": [1, {"b": 2,},],}`, want: `{"a": [1, {"b": 2}]}`},
+		{name: "comment markers in strings", in: `{"url": "http://x//y", "s": "/* not */"}`, want: `{"url": "http://x//y", "s": "/* not */"}`},
+		{name: "escaped quote", in: `{"q": "say \"hi\" // still text",}`, want: `{"q": "say \"hi\" // still text"}`},
+		{name: "comma in string", in: `["a,]"]`, want: `["a,]"]`},
+		{name: "unterminated comment", in: `{"a": 1 /* never closed`, wantErr: "unterminated comment"},
+		{name: "unterminated string", in: "{\"a\": \"open\n}", wantErr: "unterminated string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripJSONC([]byte(tt.in))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("StripJSONC error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StripJSONC: %v", err)
+			}
+			if len(got) != len(tt.in) || strings.Count(string(got), "\n") != strings.Count(tt.in, "\n") {
+				t.Errorf("StripJSONC changed offsets: %q from %q", got, tt.in)
+			}
+
+			var gotValue, wantValue any
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatalf("stripped output is not JSON: %v\n%s", err, got)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("StripJSONC = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONCErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "syntax", in: "{\n  // comment\n  \"a\": 1\n  \"b\": 2\n}", want: "creature.json:4:"},
+		{name: "type", in: "{\n  \"name\": 7\n}", want: "creature.json:2:"},
+		{name: "unterminated comment", in: "{\n  \"a\": 1, /* open\n}", want: "creature.json:2:11: unterminated comment"},
+		{name: "unterminated string", in: "{\n\n  \"name\": \"Mira\n}", want: "creature.json:3:11: unterminated string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v struct {
+				Name string `json:"name"`
+			}
+			err := decodeJSONC("creature.json", []byte(tt.in), &v)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeJSONC error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
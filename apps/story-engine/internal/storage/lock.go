@@ -0,0 +1,10 @@
+package storage
+
+import "errors"
+
+// lockFile is the run lock in the data dir. A run holds it from start to
+// finish, so two runs of the same story never write its data at once.
+const lockFile = ".storygen.lock"
+
+// ErrLocked is returned by Lock while another run holds the lock.
+var ErrLocked = errors.New("another run of this story is in progress")
@@ -0,0 +1,30 @@
+//go:build unix
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Lock takes the data dir's run lock without waiting, returning ErrLocked
+// if another process or goroutine holds it. The lock is released by the
+// returned func or when the process exits.
+func (s *Store) Lock() (func(), error) {
+	path := s.DataPath(lockFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run lock: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w (%s)", ErrLocked, path)
+		}
+		return nil, fmt.Errorf("failed to take run lock %s: %w", path, err)
+	}
+	// Closing the file releases the lock
+	return func() { f.Close() }, nil
+}
@@ -0,0 +1,314 @@
+// Package storage handles file-based persistence for story data.
+//
+// All story state lives as JSON files under paths.data_dir. Store resolves
+// the layout from PathsConfig so callers never build data paths by hand.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/config"
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+const (
+	worldMapFile   = "world_map.json"
+	worldStateFile = "world_state.json"
+
+	// templateSuffix marks example files that are not part of the story.
+	templateSuffix = ".template.json"
+)
+
+// Store resolves and reads/writes story data files.
+type Store struct {
+	paths config.PathsConfig
+}
+
+// New creates a Store for the configured data layout.
+func New(paths config.PathsConfig) *Store {
+	return &Store{paths: paths}
+}
+
+// DataPath joins elem onto the data directory.
+func (s *Store) DataPath(elem ...string) string {
+	return filepath.Join(append([]string{s.paths.DataDir}, elem...)...)
+}
+
+// WorldMapPath returns the path of world_map.json.
+func (s *Store) WorldMapPath() string {
+	return s.DataPath(s.paths.WorldDir, worldMapFile)
+}
+
+// WorldStatePath returns the path of world_state.json.
+func (s *Store) WorldStatePath() string {
+	return s.DataPath(s.paths.WorldDir, worldStateFile)
+}
+
+// StoryBiblePath returns the path of the story bible.
+func (s *Store) StoryBiblePath() string {
+	return s.DataPath(s.paths.StoryBible)
+}
+
+// EntitiesPath returns the entities directory.
+func (s *Store) EntitiesPath() string {
+	return s.DataPath(s.paths.EntitiesDir)
+}
+
+// LoadStoryBible reads and decodes the story bible.
+func (s *Store) LoadStoryBible() (*models.StoryBible, error) {
+	var b models.StoryBible
+	if err := ReadJSON(s.StoryBiblePath(), &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// EntityFiles lists every entity file under the entities directory in sorted
+// order. Template files are skipped unless includeTemplates is set.
+func (s *Store) EntityFiles(includeTemplates bool) ([]string, error) {
+	var files []string
+	root := s.EntitiesPath()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		if IsTemplate(path) && !includeTemplates {
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities in %s: %w", root, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// IsTemplate reports whether path is an example file rather than story data.
+func IsTemplate(path string) bool {
+	return strings.HasSuffix(path, templateSuffix)
+}
+
+// TemplatePath returns the template a data file is created from, e.g.
+// story_bible.template.json for story_bible.json.
+func TemplatePath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + templateSuffix
+}
+
+// ChapterRecordPath returns the archive record of chapter n.
+func (s *Store) ChapterRecordPath(n int) string {
+	return s.DataPath(s.paths.ChaptersDir, fmt.Sprintf("chapter_%03d.json", n))
+}
+
+// LoadChapterRecord reads the archive record of chapter n.
+func (s *Store) LoadChapterRecord(n int) (*models.ChapterRecord, error) {
+	var r models.ChapterRecord
+	if err := ReadJSON(s.ChapterRecordPath(n), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// SaveChapterRecord writes a chapter's archive record, replacing any
+// earlier record of the same chapter.
+func (s *Store) SaveChapterRecord(r *models.ChapterRecord) error {
+	return WriteJSON(s.ChapterRecordPath(r.ChapterNumber), r)
+}
+
+// LoadEntity reads and decodes a single entity file.
+func LoadEntity(path string) (*models.Entity, error) {
+	var e models.Entity
+	if err := ReadJSON(path, &e); err != nil {
+		return nil, err
+	}
+	e.Path = path
+	return &e, nil
+}
+
+// LoadWorldMap reads and decodes world_map.json.
+func (s *Store) LoadWorldMap() (*models.WorldMap, error) {
+	var m models.WorldMap
+	if err := ReadJSON(s.WorldMapPath(), &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// LoadWorldState reads and decodes world_state.json.
+func (s *Store) LoadWorldState() (*models.WorldState, error) {
+	var st models.WorldState
+	if err := ReadJSON(s.WorldStatePath(), &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// SaveProximityAlerts replaces the proximity_alerts section of world_state.json.
+func (s *Store) SaveProximityAlerts(alerts []models.ProximityAlert) error {
+	if alerts == nil {
+		alerts = []models.ProximityAlert{}
+	}
+	return PatchJSON(s.WorldStatePath(), map[string]any{"proximity_alerts": alerts})
+}
+
+// SaveWorldMapDerived writes the derived distance_matrix and
+// cardinal_directions sections back to world_map.json. The rest of the
+// hand-maintained file is left as it is.
+func (s *Store) SaveWorldMapDerived(m *models.WorldMap) error {
+	return PatchJSON(s.WorldMapPath(), map[string]any{
+		"distance_matrix":     m.DistanceMatrix,
+		"cardinal_directions": m.CardinalDirections,
+	})
+}
+
+// ReadJSON decodes the JSON file at path into v. Data files are edited by
+// hand, so // and /* */ comments and trailing commas are accepted, and parse
+// errors give the line and column.
+func ReadJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return decodeJSONC(path, data, v)
+}
+
+// WriteJSON encodes v as indented JSON and atomically replaces the file at path.
+func WriteJSON(path string, v any) error {
+	data, err := EncodeJSON(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	return writeFileAtomic(path, data)
+}
+
+// EncodeJSON renders v the way data files are stored: four-space indent,
+// no HTML escaping and no trailing newline.
+func EncodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// FormatFile rewrites the JSON file at path in canonical form: comments and
+// trailing commas removed, keys kept in file order, and the indentation of
+// EncodeJSON. It reports whether the file was not already canonical; the
+// file is only written if write is set.
+func FormatFile(path string, write bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	clean, err := StripJSONC(data)
+	if err != nil {
+		return false, parseError(path, data, err)
+	}
+	v, err := DecodeOrdered(clean)
+	if err != nil {
+		return false, parseError(path, data, err)
+	}
+	out, err := EncodeJSON(v)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	if bytes.Equal(data, out) {
+		return false, nil
+	}
+	if write {
+		if err := writeFileAtomic(path, out); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// JSONFiles lists every .json file under the data directory in sorted order.
+func (s *Store) JSONFiles() ([]string, error) {
+	var files []string
+	root := s.DataPath()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".json" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", root, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// PatchJSON replaces the given top-level keys of the JSON object at path,
+// leaving every other field and the key order untouched. Use this to update
+// derived sections of hand-edited files without dropping fields the typed
+// models don't know about. Keys not already present are appended. Comments
+// in the file are not kept.
+func PatchJSON(path string, fields map[string]any) error {
+	doc, err := ReadObject(path)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		doc.Set(key, fields[key])
+	}
+	return WriteJSON(path, doc)
+}
+
+// writeFileAtomic writes data to a temp file in the same directory and renames
+// it over path, so readers never see a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions on %s: %w", path, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
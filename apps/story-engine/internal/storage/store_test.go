@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatchJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		fields  map[string]any
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "replaces in place and appends new keys in order",
+			in:     `{"b": 1.50, "a": {"x": 1}, "c": "keep"}`,
+			fields: map[string]any{"a": []int{1, 2}, "e": true, "d": nil},
+			want:   "{\n    \"b\": 1.50,\n    \"a\": [\n        1,\n        2\n    ],\n    \"c\": \"keep\",\n    \"d\": null,\n    \"e\": true\n}",
+		},
+		{
+			name:   "comments dropped, HTML not escaped",
+			in:     "{\n  // hand note\n  \"a\": \"<b>\",\n}",
+			fields: map[string]any{"b": "x & y"},
+			want:   "{\n    \"a\": \"<b>\",\n    \"b\": \"x & y\"\n}",
+		},
+		{
+			name:    "not an object",
+			in:      `[1, 2]`,
+			fields:  map[string]any{"a": 1},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "doc.json")
+			if err := os.WriteFile(path, []byte(tt.in), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := PatchJSON(path, tt.fields)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("PatchJSON succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PatchJSON: %v", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("file =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,386 @@
+// Package world answers spatial questions about the story world.
+//
+// It builds a travel graph from world_map.json so the planner can ask
+// "where can they go from here?" and "how long would that take?", and it
+// regenerates the derived distance_matrix and cardinal_directions sections
+// so they never drift from the connections they summarise.
+
+package world
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// Unreachable is the distance_matrix value for unknown or impassable routes.
+const Unreachable = -1
+
+const (
+	matrixNote     = "Quick reference for travel times between key locations (in days). -1 = unknown/impassable."
+	directionsNote = "For prose generation - how to describe travel between locations"
+)
+
+// Connection statuses that cannot be travelled without story intervention.
+var closedStatuses = map[string]bool{
+	"blocked":     true,
+	"impassable":  true,
+	"destroyed":   true,
+	"conditional": true,
+}
+
+// Edge is one direction of travel along a connection.
+type Edge struct {
+	ConnectionID string   `json:"connection_id"`
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	ToName       string   `json:"to_name"`
+	Days         float64  `json:"days"`
+	Estimated    bool     `json:"estimated,omitempty"`
+	Passable     bool     `json:"passable"`
+	Reason       string   `json:"reason,omitempty"`
+	Status       string   `json:"status"`
+	Difficulty   string   `json:"terrain_difficulty"`
+	Hazards      []string `json:"hazards,omitempty"`
+}
+
+// Path is a route between two locations.
+type Path struct {
+	Stops     []string `json:"stops"`
+	Legs      []Edge   `json:"legs"`
+	Days      float64  `json:"days"`
+	Estimated bool     `json:"estimated,omitempty"`
+}
+
+// Graph is a travel graph over the locations and connections of a world map.
+type Graph struct {
+	world *models.WorldMap
+	edges map[string][]Edge
+}
+
+// NewGraph builds a travel graph from the world map.
+//
+// Edges are weighted in days of travel. A known distance_days is that weight
+// as-is: it is the hand-measured travel time over the connection's terrain
+// (conn_002's 14 days through the forest is the figure the hand-kept matrix
+// used), so scaling it by travel_modifier again would count the terrain
+// twice. Connections with an unknown distance are weighted by grid distance
+// divided by the terrain travel_modifier of their endpoints, and marked
+// Estimated.
+func NewGraph(m *models.WorldMap) *Graph {
+	g := &Graph{world: m, edges: make(map[string][]Edge)}
+
+	for i := range m.Connections {
+		conn := &m.Connections[i]
+		g.addEdge(conn, conn.From, conn.To, conn.ToName)
+		if conn.Bidirectional.Known && conn.Bidirectional.Value {
+			g.addEdge(conn, conn.To, conn.From, conn.FromName)
+		}
+	}
+
+	for from := range g.edges {
+		sort.Slice(g.edges[from], func(i, j int) bool {
+			return g.edges[from][i].To < g.edges[from][j].To
+		})
+	}
+
+	return g
+}
+
+func (g *Graph) addEdge(conn *models.Connection, from, to, toName string) {
+	e := Edge{
+		ConnectionID: conn.ID,
+		From:         from,
+		To:           to,
+		ToName:       toName,
+		Status:       conn.Status,
+		Difficulty:   conn.TerrainDifficulty,
+		Hazards:      conn.Hazards,
+		Passable:     true,
+	}
+
+	dest := g.world.LocationByID(to)
+	switch {
+	case dest == nil:
+		e.Passable = false
+		e.Reason = fmt.Sprintf("%s is not on the map", to)
+	case closedStatuses[conn.Status]:
+		e.Passable = false
+		e.Reason = "route is " + conn.Status
+		if conn.AccessCondition != "" {
+			e.Reason += ": " + conn.AccessCondition
+		}
+	case dest.Traversable != nil && !*dest.Traversable:
+		e.Passable = false
+		e.Reason = dest.Name + " is not traversable"
+	}
+
+	if conn.DistanceDays.Known {
+		e.Days = conn.DistanceDays.Days
+	} else if days, ok := g.estimateDays(from, to); ok {
+		e.Days = days
+		e.Estimated = true
+	} else if e.Passable {
+		e.Passable = false
+		e.Reason = "travel time is " + orUnknown(conn.DistanceDays.Raw)
+	}
+
+	g.edges[from] = append(g.edges[from], e)
+}
+
+// estimateDays approximates travel time from grid distance and terrain.
+// It fails when either endpoint has a null travel_modifier (for example a
+// hidden settlement that cannot be reached by walking).
+func (g *Graph) estimateDays(from, to string) (float64, bool) {
+	a, b := g.world.LocationByID(from), g.world.LocationByID(to)
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	modA, okA := g.travelModifier(a)
+	modB, okB := g.travelModifier(b)
+	if !okA || !okB {
+		return 0, false
+	}
+
+	dist := math.Hypot(b.Position.X-a.Position.X, b.Position.Y-a.Position.Y)
+	return dist / ((modA + modB) / 2), true
+}
+
+// travelModifier returns the speed multiplier for a location, preferring the
+// location's own traversal_modifier over its terrain type. Unlisted terrain is
+// treated as clear ground.
+func (g *Graph) travelModifier(loc *models.Location) (float64, bool) {
+	if loc.TraversalModifier != nil {
+		return *loc.TraversalModifier, *loc.TraversalModifier > 0
+	}
+	terrain, ok := g.world.TerrainTypes[loc.Terrain]
+	if !ok {
+		return 1.0, true
+	}
+	if terrain.TravelModifier == nil || *terrain.TravelModifier <= 0 {
+		return 0, false
+	}
+	return *terrain.TravelModifier, true
+}
+
+// Exits answers "where can they go from here?": every route leaving the
+// location, including closed ones with the reason they are closed.
+func (g *Graph) Exits(from string) []Edge {
+	return append([]Edge(nil), g.edges[from]...)
+}
+
+// ShortestPath answers "how long would that take?" using Dijkstra over
+// passable routes, weighted by terrain as described on NewGraph. It returns
+// false if no passable route exists.
+func (g *Graph) ShortestPath(from, to string) (Path, bool) {
+	return g.shortestPath(from, to, false)
+}
+
+// shortestPath is ShortestPath, over known legs only if knownOnly is set.
+func (g *Graph) shortestPath(from, to string, knownOnly bool) (Path, bool) {
+	if g.world.LocationByID(from) == nil || g.world.LocationByID(to) == nil {
+		return Path{}, false
+	}
+	if from == to {
+		return Path{Stops: []string{from}}, true
+	}
+
+	dist := map[string]float64{from: 0}
+	prev := make(map[string]Edge)
+	visited := make(map[string]bool)
+	pq := &queue{{id: from}}
+
+	for pq.Len() > 0 {
+		cur := heap.Pop(pq).(item)
+		if visited[cur.id] {
+			continue
+		}
+		visited[cur.id] = true
+		if cur.id == to {
+			break
+		}
+		for _, e := range g.edges[cur.id] {
+			if !e.Passable || visited[e.To] || (knownOnly && e.Estimated) {
+				continue
+			}
+			d := cur.days + e.Days
+			if old, seen := dist[e.To]; !seen || d < old {
+				dist[e.To] = d
+				prev[e.To] = e
+				heap.Push(pq, item{id: e.To, days: d})
+			}
+		}
+	}
+
+	if !visited[to] {
+		return Path{}, false
+	}
+
+	var legs []Edge
+	for at := to; at != from; at = prev[at].From {
+		legs = append(legs, prev[at])
+	}
+
+	p := Path{Stops: []string{from}, Days: dist[to]}
+	for i := len(legs) - 1; i >= 0; i-- {
+		p.Legs = append(p.Legs, legs[i])
+		p.Stops = append(p.Stops, legs[i].To)
+		p.Estimated = p.Estimated || legs[i].Estimated
+	}
+	return p, true
+}
+
+// TravelTime returns the shortest travel time in days between two locations.
+func (g *Graph) TravelTime(from, to string) (float64, bool) {
+	p, ok := g.ShortestPath(from, to)
+	return p.Days, ok
+}
+
+// DistanceMatrix computes travel times between every pair of locations,
+// rounded to a tenth of a day. Only known distances are used: a pair reached
+// only through an estimated leg stays -1 (unknown), as does an unreachable
+// one. ShortestPath still gives the estimate.
+func (g *Graph) DistanceMatrix() models.DistanceMatrix {
+	ids := g.world.LocationIDs()
+	m := models.DistanceMatrix{Note: matrixNote, Rows: make(map[string]map[string]float64, len(ids))}
+
+	for _, from := range ids {
+		row := make(map[string]float64, len(ids)-1)
+		for _, to := range ids {
+			if from == to {
+				continue
+			}
+			if p, ok := g.shortestPath(from, to, true); ok {
+				row[to] = math.Round(p.Days*10) / 10
+			} else {
+				row[to] = Unreachable
+			}
+		}
+		m.Rows[from] = row
+	}
+
+	if g.world.DistanceMatrix.Note != "" {
+		m.Note = g.world.DistanceMatrix.Note
+	}
+	return m
+}
+
+// CardinalDirections returns a travel description for every direction of
+// every connection. Hand-written descriptions already on the map are kept;
+// missing ones are generated from coordinates and elevation, and entries for
+// connections that no longer exist are dropped.
+func (g *Graph) CardinalDirections() models.CardinalDirections {
+	existing := g.world.CardinalDirections
+	out := models.CardinalDirections{Note: directionsNote, Directions: make(map[string]string)}
+	if existing.Note != "" {
+		out.Note = existing.Note
+	}
+
+	for _, edges := range g.edges {
+		for _, e := range edges {
+			key := models.DirectionKey(e.From, e.To)
+			if text, ok := existing.Directions[key]; ok {
+				out.Directions[key] = text
+				continue
+			}
+			out.Directions[key] = g.describe(e.From, e.To)
+		}
+	}
+
+	return out
+}
+
+// describe generates a plain travel description such as
+// "east and upward toward The Mountain Pass".
+func (g *Graph) describe(from, to string) string {
+	a, b := g.world.LocationByID(from), g.world.LocationByID(to)
+	if a == nil || b == nil {
+		return "toward " + to
+	}
+
+	var parts []string
+	if dir := compass(b.Position.X-a.Position.X, b.Position.Y-a.Position.Y); dir != "" {
+		parts = append(parts, dir)
+	}
+	switch {
+	case b.Elevation > a.Elevation:
+		parts = append(parts, "and upward")
+	case b.Elevation < a.Elevation:
+		parts = append(parts, "and downward")
+	}
+	parts = append(parts, "toward "+b.Name)
+
+	return strings.Join(parts, " ")
+}
+
+// compassPoints are the 8 directions anticlockwise from east.
+var compassPoints = []string{"east", "north-east", "north", "north-west", "west", "south-west", "south", "south-east"}
+
+// compass converts a grid offset (y+ is north) into an 8-point direction.
+func compass(dx, dy float64) string {
+	if dx == 0 && dy == 0 {
+		return ""
+	}
+	angle := math.Atan2(dy, dx)
+	idx := int(math.Round(angle/(math.Pi/4))+8) % 8
+	return compassPoints[idx]
+}
+
+// headingVector converts a heading such as "north-east", "northeast" or
+// "NE" into a unit grid offset. It fails for "unknown" and anything else
+// that isn't a compass point.
+func headingVector(heading string) (dx, dy float64, ok bool) {
+	h := strings.ToLower(strings.TrimSpace(heading))
+	h = strings.NewReplacer(" ", "", "-", "", "_", "").Replace(h)
+	abbrevs := []string{"e", "ne", "n", "nw", "w", "sw", "s", "se"}
+	for i, point := range compassPoints {
+		if h != strings.ReplaceAll(point, "-", "") && h != abbrevs[i] {
+			continue
+		}
+		angle := float64(i) * math.Pi / 4
+		return math.Cos(angle), math.Sin(angle), true
+	}
+	return 0, 0, false
+}
+
+// Rebuild regenerates the derived distance_matrix and cardinal_directions
+// sections of the world map in place.
+func Rebuild(m *models.WorldMap) {
+	g := NewGraph(m)
+	matrix := g.DistanceMatrix()
+	directions := g.CardinalDirections()
+	m.DistanceMatrix = matrix
+	m.CardinalDirections = directions
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// queue is a min-heap of locations ordered by travel time, for Dijkstra.
+type item struct {
+	id   string
+	days float64
+}
+
+type queue []item
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q[i].days < q[j].days }
+func (q queue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)        { *q = append(*q, x.(item)) }
+func (q *queue) Pop() any {
+	old := *q
+	n := len(old)
+	it := old[n-1]
+	*q = old[:n-1]
+	return it
+}
@@ -0,0 +1,139 @@
+package world
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// testMap is a small world: a to c is 3 days through b, or 5 direct; b to
+// forest d is an estimated leg and d's road back to a is blocked; hidden e
+// has no travel time; and f is not traversable.
+func testMap() *models.WorldMap {
+	half, no := 0.5, false
+	both := models.FlexBool{Value: true, Known: true}
+	days := func(d float64) models.TravelDays { return models.TravelDays{Days: d, Known: true} }
+	unknown := models.TravelDays{Raw: "unknown"}
+
+	return &models.WorldMap{
+		Locations: []models.Location{
+			{ID: "a", Name: "Ashford", Position: models.Coordinates{X: 0, Y: 0}},
+			{ID: "b", Name: "Bramble", Position: models.Coordinates{X: 4, Y: 0}},
+			{ID: "c", Name: "Cairn", Position: models.Coordinates{X: 4, Y: 3}},
+			{ID: "d", Name: "Deepwood", Position: models.Coordinates{X: 10, Y: 0}, Terrain: "forest"},
+			{ID: "e", Name: "Elderhold", Position: models.Coordinates{X: 6, Y: 6}, Terrain: "hidden"},
+			{ID: "f", Name: "Fallen Keep", Position: models.Coordinates{X: 0, Y: -1}, Traversable: &no},
+		},
+		Connections: []models.Connection{
+			{ID: "ab", From: "a", To: "b", ToName: "Bramble", FromName: "Ashford", Bidirectional: both, DistanceDays: days(2), Status: "open"},
+			{ID: "bc", From: "b", To: "c", ToName: "Cairn", FromName: "Bramble", Bidirectional: both, DistanceDays: days(1), Status: "open"},
+			{ID: "ac", From: "a", To: "c", ToName: "Cairn", FromName: "Ashford", Bidirectional: both, DistanceDays: days(5), Status: "open"},
+			{ID: "bd", From: "b", To: "d", ToName: "Deepwood", FromName: "Bramble", Bidirectional: both, DistanceDays: unknown, Status: "open"},
+			{ID: "ce", From: "c", To: "e", ToName: "Elderhold", FromName: "Cairn", Bidirectional: both, DistanceDays: unknown, Status: "open"},
+			{ID: "af", From: "a", To: "f", ToName: "Fallen Keep", FromName: "Ashford", DistanceDays: days(1), Status: "open"},
+			{ID: "da", From: "d", To: "a", ToName: "Ashford", FromName: "Deepwood", DistanceDays: days(3), Status: "blocked", AccessCondition: "rockfall"},
+		},
+		TerrainTypes: map[string]models.TerrainType{
+			"forest": {TravelModifier: &half},
+			"hidden": {},
+		},
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	tests := []struct {
+		from, to      string
+		wantStops     []string
+		wantDays      float64
+		wantEstimated bool
+		wantOK        bool
+	}{
+		{from: "a", to: "c", wantStops: []string{"a", "b", "c"}, wantDays: 3, wantOK: true},
+		{from: "c", to: "a", wantStops: []string{"c", "b", "a"}, wantDays: 3, wantOK: true},
+		{from: "a", to: "a", wantStops: []string{"a"}, wantOK: true},
+		// 6 squares at the average of clear ground (1.0) and forest (0.5)
+		{from: "a", to: "d", wantStops: []string{"a", "b", "d"}, wantDays: 2 + 6/0.75, wantEstimated: true, wantOK: true},
+		// The direct road back is blocked
+		{from: "d", to: "a", wantStops: []string{"d", "b", "a"}, wantDays: 6/0.75 + 2, wantEstimated: true, wantOK: true},
+		{from: "a", to: "e"},
+		{from: "a", to: "f"},
+		{from: "a", to: "nowhere"},
+		{from: "nowhere", to: "a"},
+	}
+
+	g := NewGraph(testMap())
+	for _, tt := range tests {
+		t.Run(tt.from+"-"+tt.to, func(t *testing.T) {
+			p, ok := g.ShortestPath(tt.from, tt.to)
+			if ok != tt.wantOK {
+				t.Fatalf("ShortestPath ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !slices.Equal(p.Stops, tt.wantStops) {
+				t.Errorf("Stops = %v, want %v", p.Stops, tt.wantStops)
+			}
+			if math.Abs(p.Days-tt.wantDays) > 1e-9 {
+				t.Errorf("Days = %v, want %v", p.Days, tt.wantDays)
+			}
+			if p.Estimated != tt.wantEstimated {
+				t.Errorf("Estimated = %v, want %v", p.Estimated, tt.wantEstimated)
+			}
+			if len(p.Legs) != len(p.Stops)-1 {
+				t.Errorf("got %d legs for %d stops", len(p.Legs), len(p.Stops))
+			}
+		})
+	}
+}
+
+func TestExits(t *testing.T) {
+	g := NewGraph(testMap())
+	tests := []struct {
+		from, to   string
+		passable   bool
+		wantReason string
+	}{
+		{from: "a", to: "b", passable: true},
+		{from: "a", to: "f", wantReason: "Fallen Keep is not traversable"},
+		{from: "d", to: "a", wantReason: "route is blocked: rockfall"},
+		{from: "c", to: "e", wantReason: "travel time is unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"-"+tt.to, func(t *testing.T) {
+			i := slices.IndexFunc(g.Exits(tt.from), func(e Edge) bool { return e.To == tt.to })
+			if i < 0 {
+				t.Fatalf("no exit from %s to %s", tt.from, tt.to)
+			}
+			e := g.Exits(tt.from)[i]
+			if e.Passable != tt.passable || !strings.Contains(e.Reason, tt.wantReason) {
+				t.Errorf("exit = passable %v (%q), want passable %v (%q)", e.Passable, e.Reason, tt.passable, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestDistanceMatrix(t *testing.T) {
+	m := NewGraph(testMap()).DistanceMatrix()
+	tests := []struct {
+		from, to string
+		want     float64
+	}{
+		{"a", "c", 3},
+		{"c", "a", 3},
+		{"a", "b", 2},
+		// Only reachable over an estimated leg
+		{"a", "d", Unreachable},
+		{"d", "a", Unreachable},
+		{"a", "e", Unreachable},
+		{"a", "f", Unreachable},
+	}
+	for _, tt := range tests {
+		if got := m.Rows[tt.from][tt.to]; got != tt.want {
+			t.Errorf("distance_matrix %s -> %s = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package world
+
+import "github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+
+// PlannerContext is the spatial context handed to the Story Planner: where
+// each character can go next, and who is about to meet.
+type PlannerContext struct {
+	AsOfChapter     int                     `json:"as_of_chapter"`
+	Travel          []CharacterTravel       `json:"travel"`
+	ProximityAlerts []models.ProximityAlert `json:"proximity_alerts"`
+}
+
+// CharacterTravel is the route options for one character's current location.
+type CharacterTravel struct {
+	EntityID    string       `json:"entity_id"`
+	Name        string       `json:"name"`
+	Destination string       `json:"destination,omitempty"`
+	Routes      RouteOptions `json:"routes"`
+}
+
+// BuildPlannerContext answers the planner's spatial questions from the world
+// guide: where characters can go, how long it takes, and whether anyone is
+// about to meet within horizon chapters.
+func BuildPlannerContext(m *models.WorldMap, state *models.WorldState, horizon int) PlannerContext {
+	g := NewGraph(m)
+	ctx := PlannerContext{
+		AsOfChapter:     state.Meta.AsOfChapter,
+		ProximityAlerts: NewProximityEngine(m, horizon).Predict(state),
+	}
+
+	for _, c := range state.CharacterPositions {
+		if m.LocationByID(c.CurrentLocation.LocationID) == nil {
+			continue
+		}
+		ctx.Travel = append(ctx.Travel, CharacterTravel{
+			EntityID:    c.EntityID,
+			Name:        c.Name,
+			Destination: c.Destination,
+			Routes:      g.RouteOptions(c.CurrentLocation.LocationID),
+		})
+	}
+
+	return ctx
+}
@@ -0,0 +1,326 @@
+package world
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+// EncounterRadius is how close two entities must be, in grid units (about a
+// day's travel), to count as about to meet.
+const EncounterRadius = 1.0
+
+// HeadingPace is how far, in grid units per chapter, a character with a
+// heading but no destination is projected to travel.
+const HeadingPace = 1.0
+
+// Confidence levels for proximity predictions, lowest first.
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+var confidenceRank = map[string]int{ConfidenceLow: 0, ConfidenceMedium: 1, ConfidenceHigh: 2}
+var confidenceByRank = []string{ConfidenceLow, ConfidenceMedium, ConfidenceHigh}
+
+// Alert statuses produced by the engine.
+const (
+	StatusNearby          = "nearby"
+	StatusNearbyUncertain = "nearby_uncertain"
+	StatusConverging      = "converging"
+	StatusParallel        = "parallel_movement"
+	StatusInTerritory     = "in_territory"
+)
+
+// track is an entity's predicted position for each chapter in the horizon.
+// Index 0 is the current chapter.
+type track struct {
+	id         string
+	character  bool
+	following  string
+	distance   string
+	confidence string
+	points     []*models.Coordinates
+	locations  []string
+}
+
+// ProximityEngine predicts which entities will be co-located within the
+// next few chapters, from world_state positions and world_map geography.
+type ProximityEngine struct {
+	world   *models.WorldMap
+	horizon int
+}
+
+// NewProximityEngine creates an engine that looks horizon chapters ahead.
+func NewProximityEngine(m *models.WorldMap, horizon int) *ProximityEngine {
+	if horizon < 0 {
+		horizon = 0
+	}
+	return &ProximityEngine{world: m, horizon: horizon}
+}
+
+// Predict returns proximity alerts for the given world state, ordered by how
+// soon the encounter is expected. Hand-written narrative_tension and note
+// fields from alerts already in the state are carried over for the same
+// entity pair.
+func (p *ProximityEngine) Predict(state *models.WorldState) []models.ProximityAlert {
+	tracks := make(map[string]*track)
+	var order []string
+
+	for i := range state.CharacterPositions {
+		if t := p.characterTrack(&state.CharacterPositions[i]); t != nil {
+			tracks[t.id] = t
+			order = append(order, t.id)
+		}
+	}
+
+	// Followers are resolved after characters so they can copy their track.
+	var territorial []*models.CreaturePosition
+	for i := range state.CreaturePositions {
+		c := &state.CreaturePositions[i]
+		if len(c.Territory) > 0 && c.CurrentLocation.CoordinatesApprox == nil && c.Following == "" {
+			territorial = append(territorial, c)
+			continue
+		}
+		if t := p.creatureTrack(c, tracks); t != nil {
+			tracks[t.id] = t
+			order = append(order, t.id)
+		}
+	}
+
+	var alerts []models.ProximityAlert
+	for i, a := range order {
+		for _, b := range order[i+1:] {
+			if alert, ok := p.pairAlert(tracks[a], tracks[b]); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	for _, c := range territorial {
+		for _, id := range order {
+			if alert, ok := p.territoryAlert(c, tracks[id]); ok {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return *alerts[i].ChaptersUntil < *alerts[j].ChaptersUntil
+	})
+
+	return mergeAlerts(alerts, state.ProximityAlerts)
+}
+
+// characterTrack projects a character along their route to their
+// destination, or along their heading at HeadingPace if they have only a
+// heading, or keeps them in place if they have neither.
+func (p *ProximityEngine) characterTrack(c *models.CharacterPosition) *track {
+	start, conf := p.startPoint(c.CurrentLocation)
+	if start == nil {
+		return nil
+	}
+	if c.MovementStatus == "unknown" {
+		conf = ConfidenceLow
+	}
+
+	t := &track{id: c.EntityID, character: true, confidence: conf}
+	dest := p.world.LocationByID(c.Destination)
+	eta := 0
+	if c.ETAChapters != nil {
+		eta = *c.ETAChapters
+	}
+
+	if dest == nil && c.MovementStatus != "unknown" {
+		if dx, dy, ok := headingVector(c.Heading); ok {
+			// Only the direction is known, not how far they will go
+			t.confidence = lowest(conf, ConfidenceMedium)
+			for k := 0; k <= p.horizon; k++ {
+				step := float64(k) * HeadingPace
+				t.points = append(t.points, &models.Coordinates{X: start.X + dx*step, Y: start.Y + dy*step})
+				t.locations = append(t.locations, c.CurrentLocation.LocationID)
+			}
+			return t
+		}
+	}
+
+	for k := 0; k <= p.horizon; k++ {
+		if dest == nil || eta <= 0 || c.MovementStatus == "unknown" {
+			t.points = append(t.points, start)
+			t.locations = append(t.locations, c.CurrentLocation.LocationID)
+			continue
+		}
+		frac := math.Min(float64(k)/float64(eta), 1)
+		t.points = append(t.points, &models.Coordinates{
+			X: start.X + (dest.Position.X-start.X)*frac,
+			Y: start.Y + (dest.Position.Y-start.Y)*frac,
+		})
+		if k >= eta {
+			t.locations = append(t.locations, dest.ID)
+		} else {
+			t.locations = append(t.locations, c.CurrentLocation.LocationID)
+		}
+	}
+
+	return t
+}
+
+// creatureTrack places a creature on the track of whoever it follows, or
+// keeps it where it was last seen.
+func (p *ProximityEngine) creatureTrack(c *models.CreaturePosition, tracks map[string]*track) *track {
+	if leader, ok := tracks[c.Following]; ok && c.Following != "" {
+		return &track{
+			id:         c.EntityID,
+			following:  c.Following,
+			distance:   c.CurrentLocation.Distance,
+			confidence: leader.confidence,
+			points:     leader.points,
+			locations:  leader.locations,
+		}
+	}
+
+	start, conf := p.startPoint(c.CurrentLocation)
+	if start == nil {
+		return nil
+	}
+	t := &track{id: c.EntityID, confidence: conf}
+	for k := 0; k <= p.horizon; k++ {
+		t.points = append(t.points, start)
+		t.locations = append(t.locations, c.CurrentLocation.LocationID)
+	}
+	return t
+}
+
+// startPoint returns an entity's current coordinates and how much to trust
+// them. Entities without coordinates fall back to the centre of their
+// location at medium confidence at best.
+func (p *ProximityEngine) startPoint(loc models.PositionLocation) (*models.Coordinates, string) {
+	conf := ConfidenceHigh
+	switch loc.CoordinatesConfidence {
+	case "none":
+		return nil, ""
+	case ConfidenceLow, ConfidenceMedium:
+		conf = loc.CoordinatesConfidence
+	}
+
+	if loc.CoordinatesApprox != nil {
+		return loc.CoordinatesApprox, conf
+	}
+	if l := p.world.LocationByID(loc.LocationID); l != nil {
+		return &l.Position, lowest(conf, ConfidenceMedium)
+	}
+	return nil, ""
+}
+
+// pairAlert finds the earliest chapter two tracks come within EncounterRadius.
+func (p *ProximityEngine) pairAlert(a, b *track) (models.ProximityAlert, bool) {
+	if !a.character && !b.character {
+		return models.ProximityAlert{}, false
+	}
+	if !a.character {
+		a, b = b, a
+	}
+
+	for k := 0; k <= p.horizon; k++ {
+		dist := math.Hypot(a.points[k].X-b.points[k].X, a.points[k].Y-b.points[k].Y)
+		if dist > EncounterRadius {
+			continue
+		}
+
+		conf := decay(lowest(a.confidence, b.confidence), k)
+		alert := models.ProximityAlert{
+			Entities:         []string{a.id, b.id},
+			DistanceEstimate: fmt.Sprintf("~%.1f grid units (~%.1f days on clear terrain)", dist, dist),
+			Confidence:       conf,
+			ChaptersUntil:    &k,
+			LocationID:       a.locations[k],
+		}
+
+		switch {
+		case b.following == a.id || a.following == b.id:
+			alert.Status = StatusParallel
+			if b.distance != "" {
+				alert.DistanceEstimate = b.distance
+			}
+		case k > 0:
+			alert.Status = StatusConverging
+		case conf == ConfidenceLow:
+			alert.Status = StatusNearbyUncertain
+		default:
+			alert.Status = StatusNearby
+		}
+		return alert, true
+	}
+
+	return models.ProximityAlert{}, false
+}
+
+// territoryAlert flags a character entering or staying in the territory of
+// a creature group that has no single position, such as a monster type.
+func (p *ProximityEngine) territoryAlert(c *models.CreaturePosition, t *track) (models.ProximityAlert, bool) {
+	if !t.character {
+		return models.ProximityAlert{}, false
+	}
+
+	for k := 0; k <= p.horizon; k++ {
+		for _, loc := range c.Territory {
+			if t.locations[k] != loc {
+				continue
+			}
+			return models.ProximityAlert{
+				Entities:         []string{t.id, c.EntityID},
+				Status:           StatusInTerritory,
+				DistanceEstimate: "within territory (" + orUnknown(c.CurrentLocation.Distribution) + ")",
+				Confidence:       decay(lowest(t.confidence, ConfidenceMedium), k),
+				ChaptersUntil:    &k,
+				LocationID:       loc,
+			}, true
+		}
+	}
+
+	return models.ProximityAlert{}, false
+}
+
+// mergeAlerts keeps narrative fields written by the position updater for
+// alerts that are still predicted.
+func mergeAlerts(predicted, existing []models.ProximityAlert) []models.ProximityAlert {
+	prev := make(map[string]models.ProximityAlert, len(existing))
+	for _, a := range existing {
+		prev[pairKey(a.Entities)] = a
+	}
+
+	for i := range predicted {
+		old, ok := prev[pairKey(predicted[i].Entities)]
+		if !ok {
+			continue
+		}
+		predicted[i].NarrativeTension = old.NarrativeTension
+		predicted[i].Note = old.Note
+	}
+	return predicted
+}
+
+func pairKey(ids []string) string {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, "|")
+}
+
+// lowest returns the lower of two confidence levels.
+func lowest(a, b string) string {
+	if confidenceRank[a] < confidenceRank[b] {
+		return a
+	}
+	return b
+}
+
+// decay lowers confidence by one level for predictions two or more chapters out.
+func decay(conf string, chapters int) string {
+	if chapters < 2 {
+		return conf
+	}
+	return confidenceByRank[max(confidenceRank[conf]-1, 0)]
+}
@@ -0,0 +1,114 @@
+package world
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jamiesage/micro-saas-apps/apps/story-engine/pkg/models"
+)
+
+func TestHeadingVector(t *testing.T) {
+	tests := []struct {
+		heading string
+		dx, dy  float64
+		ok      bool
+	}{
+		{"east", 1, 0, true},
+		{"North", 0, 1, true},
+		{"north-east", math.Sqrt2 / 2, math.Sqrt2 / 2, true},
+		{"northeast", math.Sqrt2 / 2, math.Sqrt2 / 2, true},
+		{"SW", -math.Sqrt2 / 2, -math.Sqrt2 / 2, true},
+		{"south west", -math.Sqrt2 / 2, -math.Sqrt2 / 2, true},
+		{"unknown", 0, 0, false},
+		{"", 0, 0, false},
+		{"toward the coast", 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.heading, func(t *testing.T) {
+			dx, dy, ok := headingVector(tt.heading)
+			if ok != tt.ok || math.Abs(dx-tt.dx) > 1e-9 || math.Abs(dy-tt.dy) > 1e-9 {
+				t.Errorf("headingVector(%q) = %.3f, %.3f, %v, want %.3f, %.3f, %v", tt.heading, dx, dy, ok, tt.dx, tt.dy, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCharacterTrack(t *testing.T) {
+	m := &models.WorldMap{Locations: []models.Location{
+		{ID: "loc_a", Position: models.Coordinates{X: 0, Y: 0}},
+		{ID: "loc_b", Position: models.Coordinates{X: 4, Y: 0}},
+	}}
+	eta := 2
+	at := func(x, y float64) models.PositionLocation {
+		return models.PositionLocation{LocationID: "loc_a", CoordinatesApprox: &models.Coordinates{X: x, Y: y}}
+	}
+
+	tests := []struct {
+		name     string
+		pos      models.CharacterPosition
+		wantLast models.Coordinates
+		wantConf string
+	}{
+		{
+			name:     "destination",
+			pos:      models.CharacterPosition{CurrentLocation: at(0, 0), Heading: "north", Destination: "loc_b", ETAChapters: &eta},
+			wantLast: models.Coordinates{X: 4, Y: 0},
+			wantConf: ConfidenceHigh,
+		},
+		{
+			name:     "heading only",
+			pos:      models.CharacterPosition{CurrentLocation: at(1, 1), Heading: "north"},
+			wantLast: models.Coordinates{X: 1, Y: 1 + 3*HeadingPace},
+			wantConf: ConfidenceMedium,
+		},
+		{
+			name:     "unknown heading",
+			pos:      models.CharacterPosition{CurrentLocation: at(1, 1), Heading: "unknown"},
+			wantLast: models.Coordinates{X: 1, Y: 1},
+			wantConf: ConfidenceHigh,
+		},
+		{
+			name:     "unknown movement",
+			pos:      models.CharacterPosition{CurrentLocation: at(1, 1), Heading: "east", MovementStatus: "unknown"},
+			wantLast: models.Coordinates{X: 1, Y: 1},
+			wantConf: ConfidenceLow,
+		},
+	}
+
+	p := NewProximityEngine(m, 3)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := p.characterTrack(&tt.pos)
+			if tr == nil {
+				t.Fatal("characterTrack returned nil")
+			}
+			if len(tr.points) != 4 {
+				t.Fatalf("got %d points, want one per chapter of the horizon and now", len(tr.points))
+			}
+			last := tr.points[len(tr.points)-1]
+			if math.Abs(last.X-tt.wantLast.X) > 1e-9 || math.Abs(last.Y-tt.wantLast.Y) > 1e-9 {
+				t.Errorf("last point = %+v, want %+v", *last, tt.wantLast)
+			}
+			if tr.confidence != tt.wantConf {
+				t.Errorf("confidence = %s, want %s", tr.confidence, tt.wantConf)
+			}
+		})
+	}
+}
+
+func TestPredictHeadingEncounter(t *testing.T) {
+	m := &models.WorldMap{Locations: []models.Location{{ID: "loc_a"}}}
+	state := &models.WorldState{CharacterPositions: []models.CharacterPosition{
+		{EntityID: "char_a", CurrentLocation: models.PositionLocation{LocationID: "loc_a", CoordinatesApprox: &models.Coordinates{X: 0, Y: 0}}, Heading: "east"},
+		{EntityID: "char_b", CurrentLocation: models.PositionLocation{LocationID: "loc_a", CoordinatesApprox: &models.Coordinates{X: 3, Y: 0}}, MovementStatus: "stationary"},
+	}}
+
+	alerts := NewProximityEngine(m, 3).Predict(state)
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1: %+v", len(alerts), alerts)
+	}
+	a := alerts[0]
+	if a.Status != StatusConverging || *a.ChaptersUntil != 2 {
+		t.Errorf("alert = %s in %d chapters, want %s in 2", a.Status, *a.ChaptersUntil, StatusConverging)
+	}
+}
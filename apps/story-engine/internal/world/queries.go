@@ -0,0 +1,49 @@
+package world
+
+// Destination is a reachable location and the quickest way to get there.
+type Destination struct {
+	To        string   `json:"to"`
+	Name      string   `json:"name"`
+	Days      float64  `json:"days"`
+	Via       []string `json:"via,omitempty"`
+	Estimated bool     `json:"estimated,omitempty"`
+}
+
+// RouteOptions is the planner's view of travel from one location: the
+// routes leaving it, and how long it takes to reach everywhere else.
+type RouteOptions struct {
+	From         string        `json:"from"`
+	FromName     string        `json:"from_name"`
+	Exits        []Edge        `json:"exits"`
+	Destinations []Destination `json:"destinations"`
+	Unreachable  []string      `json:"unreachable,omitempty"`
+}
+
+// RouteOptions answers the planner's travel questions for a location.
+func (g *Graph) RouteOptions(from string) RouteOptions {
+	opts := RouteOptions{From: from, Exits: g.Exits(from)}
+	if loc := g.world.LocationByID(from); loc != nil {
+		opts.FromName = loc.Name
+	}
+
+	for _, to := range g.world.LocationIDs() {
+		if to == from {
+			continue
+		}
+		p, ok := g.ShortestPath(from, to)
+		if !ok {
+			opts.Unreachable = append(opts.Unreachable, to)
+			continue
+		}
+		d := Destination{To: to, Days: p.Days, Estimated: p.Estimated}
+		if loc := g.world.LocationByID(to); loc != nil {
+			d.Name = loc.Name
+		}
+		if len(p.Stops) > 2 {
+			d.Via = p.Stops[1 : len(p.Stops)-1]
+		}
+		opts.Destinations = append(opts.Destinations, d)
+	}
+
+	return opts
+}
@@ -0,0 +1,61 @@
+// Chapter data structures.
+
+package models
+
+// Chapter is a written chapter, as returned by the Story Writer.
+// See config/prompts/03_story_writer.md for the output format.
+type Chapter struct {
+	ChapterNumber         int      `json:"chapter_number"`
+	Title                 string   `json:"title"`
+	Text                  string   `json:"text"`
+	CharacterCount        int      `json:"character_count"`
+	EmotionalBeatAchieved string   `json:"emotional_beat_achieved,omitempty"`
+	StrongVerbsUsed       []string `json:"strong_verbs_used,omitempty"`
+	SensoryDetails        []string `json:"sensory_details,omitempty"`
+	EnvironmentAction     string   `json:"environment_action,omitempty"`
+	HistoricalSeedUsed    string   `json:"historical_seed_used,omitempty"`
+	POVCharacter          string   `json:"pov_character,omitempty"`
+	OpeningType           string   `json:"opening_type,omitempty"`
+	TransitionType        string   `json:"transition_type,omitempty"`
+}
+
+// ChapterRecord is a chapter's entry in the archive (paths.chapters_dir),
+// kept as context for later chapters.
+type ChapterRecord struct {
+	ChapterNumber     int      `json:"chapter_number"`
+	Title             string   `json:"title,omitempty"`
+	Summary           string   `json:"summary,omitempty"`
+	EmotionalBeat     string   `json:"emotional_beat,omitempty"`
+	QuestionsRaised   []string `json:"questions_raised,omitempty"`
+	QuestionsAnswered []string `json:"questions_answered,omitempty"`
+	NegativeSpace     string   `json:"negative_space,omitempty"`
+	KeyObject         string   `json:"key_object,omitempty"`
+	FullText          string   `json:"full_text"`
+	// Provenance is what produced the chapter. Chapters archived before
+	// it was recorded have none.
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// Provenance records the prompts and agent settings a chapter was
+// generated with, so a change in quality can be traced to the edit that
+// caused it.
+type Provenance struct {
+	// Prompts maps every file in the prompts directory, partials
+	// included, to the SHA-256 of its contents.
+	Prompts map[string]string `json:"prompts"`
+	// Agents are the configured agents by name.
+	Agents map[string]AgentProvenance `json:"agents"`
+}
+
+// AgentProvenance is the configuration an agent ran with.
+type AgentProvenance struct {
+	Prompt string `json:"prompt"`
+	// PromptHash is the SHA-256 of the rendered system prompt, so it also
+	// changes when an included partial or a template variable does.
+	PromptHash string `json:"prompt_hash"`
+	Model      string `json:"model"`
+	// Temperature and ThinkingBudget are as sent: temperature is not used
+	// with extended thinking.
+	Temperature    float64 `json:"temperature,omitempty"`
+	ThinkingBudget int     `json:"thinking_budget,omitempty"`
+}
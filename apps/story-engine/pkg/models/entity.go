@@ -0,0 +1,146 @@
+// Entity file data structures.
+// Characters, locations, objects and creatures live as one JSON file each
+// under data/entities/<kind>/. See the *.template.json files for the full
+// shape; only the fields the engine reasons about are typed here.
+//
+// Pattern: fields with "current" + "history"/"evolution" overwrite current
+// and append to history. History is never deleted.
+
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Entity types as recorded in the "type" field.
+const (
+	TypeCharacter = "character"
+	TypeLocation  = "location"
+	TypeObject    = "object"
+	TypeCreature  = "creature"
+)
+
+type Entity struct {
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Type     string         `json:"type"`
+	OneLiner string         `json:"one_liner"`
+	Status   EntityStatus   `json:"status"`
+	Location EntityLocation `json:"location"`
+	Visual   *Visual        `json:"visual,omitempty"`
+
+	CreatedInChapter      int  `json:"created_in_chapter"`
+	LastAppearedChapter   *int `json:"last_appeared_chapter,omitempty"`
+	LastFeaturedChapter   *int `json:"last_featured_chapter,omitempty"`
+	LastAppearanceChapter *int `json:"last_appearance_chapter,omitempty"`
+
+	KeyEvents []ChapterEvent `json:"key_events,omitempty"`
+
+	// Path is the file the entity was loaded from.
+	Path string `json:"-"`
+}
+
+type EntityStatus struct {
+	Current string         `json:"current"`
+	History []StatusChange `json:"history"`
+}
+
+type StatusChange struct {
+	Chapter int    `json:"chapter"`
+	Status  string `json:"status"`
+	Note    string `json:"note,omitempty"`
+}
+
+// EntityLocation is where an entity is. Characters record current as a
+// location id; creatures and objects record an object with more detail.
+type EntityLocation struct {
+	Current json.RawMessage  `json:"current"`
+	History []LocationChange `json:"history"`
+}
+
+type LocationChange struct {
+	Chapter  int    `json:"chapter"`
+	Location string `json:"location,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Carrier  string `json:"carrier,omitempty"`
+}
+
+// EntityPlacement is the object form of location.current used by creatures
+// and objects.
+type EntityPlacement struct {
+	LocationID   string `json:"location_id,omitempty"`
+	LocationName string `json:"location_name,omitempty"`
+	Type         string `json:"type,omitempty"`
+	CarrierID    string `json:"carrier_id,omitempty"`
+	CarrierName  string `json:"carrier_name,omitempty"`
+	Specific     string `json:"specific,omitempty"`
+}
+
+type ChapterEvent struct {
+	Chapter int    `json:"chapter"`
+	Event   string `json:"event"`
+}
+
+type Visual struct {
+	Palette           string         `json:"palette"`
+	Mood              string         `json:"mood"`
+	SignatureElements []string       `json:"signature_elements"`
+	ReferenceImages   []string       `json:"reference_images,omitempty"`
+	Evolution         []VisualChange `json:"visual_evolution,omitempty"`
+}
+
+type VisualChange struct {
+	ChapterRange string `json:"chapter_range"`
+	Notes        string `json:"notes"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// Placement decodes location.current. A plain string is treated as a
+// location id.
+func (l EntityLocation) Placement() EntityPlacement {
+	var id string
+	if err := json.Unmarshal(l.Current, &id); err == nil {
+		return EntityPlacement{LocationID: id}
+	}
+	var p EntityPlacement
+	_ = json.Unmarshal(l.Current, &p)
+	return p
+}
+
+// LastAppeared returns the chapter the entity was last on the page. The field
+// name differs by entity type.
+func (e *Entity) LastAppeared() (int, bool) {
+	for _, ch := range []*int{e.LastAppearedChapter, e.LastFeaturedChapter, e.LastAppearanceChapter} {
+		if ch != nil {
+			return *ch, true
+		}
+	}
+	return 0, false
+}
+
+// IsDead reports whether the entity's current status is a permanent end.
+func (e *Entity) IsDead() bool {
+	return IsDeadStatus(e.Status.Current)
+}
+
+// DiedInChapter returns the first chapter the entity's status history records
+// a death, if any.
+func (e *Entity) DiedInChapter() (int, bool) {
+	for _, h := range e.Status.History {
+		if IsDeadStatus(h.Status) {
+			return h.Chapter, true
+		}
+	}
+	return 0, false
+}
+
+// IsDeadStatus reports whether a status value means the entity has died.
+func IsDeadStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case "dead", "deceased", "killed":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Story bible data structures.
+// The story bible holds universe rules and long-running context for the
+// story. See data/story_bible.template.json for the full shape.
+
+package models
+
+import "encoding/json"
+
+type StoryBible struct {
+	Meta        StoryMeta       `json:"meta"`
+	Universe    Universe        `json:"universe"`
+	Premise     json.RawMessage `json:"premise,omitempty"`
+	Constraints Constraints     `json:"constraints"`
+	EntityIndex EntityIndex     `json:"entity_index"`
+}
+
+type StoryMeta struct {
+	StoryTitle  string   `json:"story_title"`
+	Tagline     string   `json:"tagline"`
+	Genre       []string `json:"genre"`
+	Tone        []string `json:"tone"`
+	CreatedAt   string   `json:"created_at"`
+	LastUpdated string   `json:"last_updated"`
+}
+
+type Universe struct {
+	Name              string         `json:"name"`
+	Era               string         `json:"era"`
+	GeographyOverview string         `json:"geography_overview"`
+	Rules             map[string]any `json:"rules"`
+}
+
+type Constraints struct {
+	NeverDo  []string `json:"never_do"`
+	AlwaysDo []string `json:"always_do"`
+}
+
+type EntityIndex struct {
+	Characters []IndexEntry `json:"characters"`
+	Locations  []IndexEntry `json:"locations"`
+	Objects    []IndexEntry `json:"objects"`
+	Creatures  []IndexEntry `json:"creatures"`
+}
+
+type IndexEntry struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Role   string `json:"role,omitempty"`
+	Class  string `json:"class,omitempty"`
+}
+
+// IndexStatus returns the status recorded in the entity index for id.
+func (b *StoryBible) IndexStatus(id string) (string, bool) {
+	for _, group := range [][]IndexEntry{b.EntityIndex.Characters, b.EntityIndex.Locations, b.EntityIndex.Objects, b.EntityIndex.Creatures} {
+		for _, e := range group {
+			if e.ID == id {
+				return e.Status, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,367 @@
+// World tracking data structures.
+// These mirror data/world/world_map.json (static geography) and
+// data/world/world_state.json (current positions).
+// See data/world/DOC_WORLD_TRACKING_SYSTEM_GUIDE.md for how they fit together.
+
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// WorldMap is the world topology: locations, connections and terrain.
+// It is the source of truth for geography.
+type WorldMap struct {
+	Description        string                 `json:"_description,omitempty"`
+	Meta               WorldMapMeta           `json:"meta"`
+	Regions            []Region               `json:"regions"`
+	Locations          []Location             `json:"locations"`
+	Connections        []Connection           `json:"connections"`
+	DistanceMatrix     DistanceMatrix         `json:"distance_matrix"`
+	TerrainTypes       map[string]TerrainType `json:"terrain_types"`
+	CardinalDirections CardinalDirections     `json:"cardinal_directions"`
+}
+
+type WorldMapMeta struct {
+	WorldName        string `json:"world_name"`
+	CoordinateSystem string `json:"coordinate_system"`
+	OriginNote       string `json:"origin_note"`
+	LastUpdated      string `json:"last_updated"`
+}
+
+type Region struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	TerrainType string   `json:"terrain_type"`
+	Locations   []string `json:"locations"`
+	Bounds      Bounds   `json:"bounds"`
+}
+
+type Bounds struct {
+	XMin float64 `json:"x_min"`
+	XMax float64 `json:"x_max"`
+	YMin float64 `json:"y_min"`
+	YMax float64 `json:"y_max"`
+}
+
+// Coordinates is a point on the relative world grid.
+// Each unit is roughly one day of travel on clear terrain.
+type Coordinates struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Location struct {
+	ID                string      `json:"id"`
+	Name              string      `json:"name"`
+	Type              string      `json:"type"`
+	Position          Coordinates `json:"position"`
+	Elevation         float64     `json:"elevation"`
+	Terrain           string      `json:"terrain"`
+	Discovered        bool        `json:"discovered"`
+	DiscoveredChapter *int        `json:"discovered_chapter"`
+	Traversable       *bool       `json:"traversable,omitempty"`
+	TraversalModifier *float64    `json:"traversal_modifier,omitempty"`
+	Access            string      `json:"access,omitempty"`
+	Notes             string      `json:"notes,omitempty"`
+}
+
+type Connection struct {
+	ID                string     `json:"id"`
+	From              string     `json:"from"`
+	To                string     `json:"to"`
+	FromName          string     `json:"from_name"`
+	ToName            string     `json:"to_name"`
+	Type              string     `json:"type"`
+	Bidirectional     FlexBool   `json:"bidirectional"`
+	DistanceDays      TravelDays `json:"distance_days"`
+	TerrainDifficulty string     `json:"terrain_difficulty"`
+	Status            string     `json:"status"`
+	Hazards           []string   `json:"hazards,omitempty"`
+	AccessCondition   string     `json:"access_condition,omitempty"`
+	Notes             string     `json:"notes,omitempty"`
+	DiscoveredChapter *int       `json:"discovered_chapter"`
+}
+
+type TerrainType struct {
+	TravelModifier *float64 `json:"travel_modifier"`
+	DangerLevel    string   `json:"danger_level"`
+	Description    string   `json:"description"`
+}
+
+// FlexBool is a boolean that may also be recorded as a string such as "unknown".
+type FlexBool struct {
+	Value bool
+	Known bool
+	Raw   string
+}
+
+func (b *FlexBool) UnmarshalJSON(data []byte) error {
+	*b = FlexBool{}
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &b.Value); err == nil {
+		b.Known = true
+		return nil
+	}
+	if err := json.Unmarshal(data, &b.Raw); err != nil {
+		return fmt.Errorf("expected bool or string, got %s", data)
+	}
+	return nil
+}
+
+func (b FlexBool) MarshalJSON() ([]byte, error) {
+	if b.Known {
+		return json.Marshal(b.Value)
+	}
+	if b.Raw == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(b.Raw)
+}
+
+// TravelDays is a distance in days that may also be recorded as a string
+// such as "unknown" or "variable" when the story has not pinned it down.
+type TravelDays struct {
+	Days  float64
+	Known bool
+	Raw   string
+}
+
+func (d *TravelDays) UnmarshalJSON(data []byte) error {
+	*d = TravelDays{}
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &d.Days); err == nil {
+		d.Known = true
+		return nil
+	}
+	if err := json.Unmarshal(data, &d.Raw); err != nil {
+		return fmt.Errorf("expected number or string, got %s", data)
+	}
+	return nil
+}
+
+func (d TravelDays) MarshalJSON() ([]byte, error) {
+	if d.Known {
+		return json.Marshal(d.Days)
+	}
+	if d.Raw == "" {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.Raw)
+}
+
+// DistanceMatrix is the quick-reference travel time table, in days.
+// -1 means unknown or impassable.
+type DistanceMatrix struct {
+	Note string
+	Rows map[string]map[string]float64
+}
+
+func (m *DistanceMatrix) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m.Rows = make(map[string]map[string]float64, len(raw))
+	for key, val := range raw {
+		if key == "_note" {
+			if err := json.Unmarshal(val, &m.Note); err != nil {
+				return fmt.Errorf("distance_matrix._note: %w", err)
+			}
+			continue
+		}
+		var row map[string]float64
+		if err := json.Unmarshal(val, &row); err != nil {
+			return fmt.Errorf("distance_matrix.%s: %w", key, err)
+		}
+		m.Rows[key] = row
+	}
+	return nil
+}
+
+func (m DistanceMatrix) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(m.Rows)+1)
+	if m.Note != "" {
+		out["_note"] = m.Note
+	}
+	for key, row := range m.Rows {
+		out[key] = row
+	}
+	return json.Marshal(out)
+}
+
+// CardinalDirections holds prose descriptions of travel between locations,
+// keyed as "<from>_to_<to>".
+type CardinalDirections struct {
+	Note       string
+	Directions map[string]string
+}
+
+// DirectionKey returns the cardinal_directions key for travel from one location to another.
+func DirectionKey(from, to string) string {
+	return from + "_to_" + to
+}
+
+func (c *CardinalDirections) UnmarshalJSON(data []byte) error {
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Note = raw["_note"]
+	delete(raw, "_note")
+	c.Directions = raw
+	return nil
+}
+
+func (c CardinalDirections) MarshalJSON() ([]byte, error) {
+	out := make(map[string]string, len(c.Directions)+1)
+	if c.Note != "" {
+		out["_note"] = c.Note
+	}
+	for key, val := range c.Directions {
+		out[key] = val
+	}
+	return json.Marshal(out)
+}
+
+// LocationByID returns the location with the given id, or nil if it is not on the map.
+func (w *WorldMap) LocationByID(id string) *Location {
+	for i := range w.Locations {
+		if w.Locations[i].ID == id {
+			return &w.Locations[i]
+		}
+	}
+	return nil
+}
+
+// LocationIDs returns all location ids on the map in sorted order.
+func (w *WorldMap) LocationIDs() []string {
+	ids := make([]string, 0, len(w.Locations))
+	for _, loc := range w.Locations {
+		ids = append(ids, loc.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// WorldState is where every mobile entity is right now. It is updated after
+// each chapter and is the source of truth for current positions.
+type WorldState struct {
+	Description        string                     `json:"_description,omitempty"`
+	Meta               WorldStateMeta             `json:"meta"`
+	CharacterPositions []CharacterPosition        `json:"character_positions"`
+	CreaturePositions  []CreaturePosition         `json:"creature_positions"`
+	ObjectPositions    []ObjectPosition           `json:"object_positions"`
+	RecentMovements    []Movement                 `json:"recent_movements"`
+	LocationOccupancy  map[string]json.RawMessage `json:"location_occupancy"`
+	ProximityAlerts    []ProximityAlert           `json:"proximity_alerts"`
+	TravelInProgress   []Travel                   `json:"travel_in_progress"`
+}
+
+type WorldStateMeta struct {
+	AsOfChapter int    `json:"as_of_chapter"`
+	LastUpdated string `json:"last_updated"`
+}
+
+// PositionLocation describes where an entity is within the map.
+type PositionLocation struct {
+	LocationID            string       `json:"location_id"`
+	LocationName          string       `json:"location_name"`
+	SubLocation           string       `json:"sub_location,omitempty"`
+	CoordinatesApprox     *Coordinates `json:"coordinates_approx,omitempty"`
+	CoordinatesConfidence string       `json:"coordinates_confidence,omitempty"`
+	Terrain               string       `json:"terrain,omitempty"`
+	RelativeTo            string       `json:"relative_to,omitempty"`
+	Distance              string       `json:"distance,omitempty"`
+	Distribution          string       `json:"distribution,omitempty"`
+}
+
+type CharacterPosition struct {
+	EntityID                      string           `json:"entity_id"`
+	Name                          string           `json:"name"`
+	CurrentLocation               PositionLocation `json:"current_location"`
+	MovementStatus                string           `json:"movement_status"`
+	MovementNote                  string           `json:"movement_note,omitempty"`
+	Heading                       string           `json:"heading,omitempty"`
+	Destination                   string           `json:"destination,omitempty"`
+	ETAChapters                   *int             `json:"eta_chapters,omitempty"`
+	LastMovedChapter              *int             `json:"last_moved_chapter,omitempty"`
+	LastConfirmedChapter          *int             `json:"last_confirmed_chapter,omitempty"`
+	EnteredCurrentLocationChapter *int             `json:"entered_current_location_chapter,omitempty"`
+	NarrativeNote                 string           `json:"narrative_note,omitempty"`
+}
+
+type CreaturePosition struct {
+	EntityID               string           `json:"entity_id"`
+	Name                   string           `json:"name"`
+	CreatureClass          string           `json:"creature_class"`
+	CurrentLocation        PositionLocation `json:"current_location"`
+	MovementStatus         string           `json:"movement_status"`
+	MovementNote           string           `json:"movement_note,omitempty"`
+	Following              string           `json:"following,omitempty"`
+	Territory              []string         `json:"territory,omitempty"`
+	LastSeenChapter        *int             `json:"last_seen_chapter,omitempty"`
+	LastInteractionChapter *int             `json:"last_interaction_chapter,omitempty"`
+}
+
+type ObjectPosition struct {
+	EntityID          string `json:"entity_id"`
+	Name              string `json:"name"`
+	PositionType      string `json:"position_type"`
+	CarrierID         string `json:"carrier_id,omitempty"`
+	CarrierName       string `json:"carrier_name,omitempty"`
+	LocationID        string `json:"location_id,omitempty"`
+	SpecificLocation  string `json:"specific_location,omitempty"`
+	CurrentHolderID   string `json:"current_holder_id,omitempty"`
+	CurrentHolderName string `json:"current_holder_name,omitempty"`
+}
+
+// Movement records an entity moving during a chapter. From and To are usually
+// coordinates but may be a string such as "unknown".
+type Movement struct {
+	Chapter  int             `json:"chapter"`
+	EntityID string          `json:"entity_id"`
+	Event    string          `json:"event"`
+	From     json.RawMessage `json:"from,omitempty"`
+	To       json.RawMessage `json:"to,omitempty"`
+}
+
+// ProximityAlert flags entities that are together or about to meet.
+type ProximityAlert struct {
+	Entities         []string `json:"entities"`
+	Status           string   `json:"status"`
+	DistanceEstimate string   `json:"distance_estimate,omitempty"`
+	NarrativeTension string   `json:"narrative_tension,omitempty"`
+	Note             string   `json:"note,omitempty"`
+	Confidence       string   `json:"confidence,omitempty"`
+	ChaptersUntil    *int     `json:"chapters_until,omitempty"`
+	LocationID       string   `json:"location_id,omitempty"`
+}
+
+type Travel struct {
+	EntityID                string   `json:"entity_id"`
+	Route                   string   `json:"route"`
+	From                    string   `json:"from"`
+	To                      string   `json:"to"`
+	StartedChapter          int      `json:"started_chapter"`
+	ProgressPercent         float64  `json:"progress_percent"`
+	EstimatedArrivalChapter int      `json:"estimated_arrival_chapter"`
+	Complications           []string `json:"complications,omitempty"`
+}
+
+// CharacterByID returns the position of a character, or nil if it is not tracked.
+func (s *WorldState) CharacterByID(id string) *CharacterPosition {
+	for i := range s.CharacterPositions {
+		if s.CharacterPositions[i].EntityID == id {
+			return &s.CharacterPositions[i]
+		}
+	}
+	return nil
+}